@@ -2,21 +2,25 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/GeoNet/kit/weft"
-	"github.com/GeoNet/nema-mar-portal/internal/email"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
 	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
-	"github.com/GeoNet/nema-mar-portal/internal/pdf"
 	"github.com/GeoNet/nema-mar-portal/internal/valid"
 )
 
-// apiEventsHandler returns a JSON list of distinct event titles from the last 7 days.
+// apiEventsHandler returns a JSON list of the events from the last 7 days,
+// with their IDs and latest titles.
 func apiEventsHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
 	if _, err := weft.CheckQueryValid(r, []string{"GET"}, []string{}, []string{"days"}, valid.Query); err != nil {
 		return err
@@ -34,7 +38,7 @@ func apiEventsHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
 
 // apiEATHandler returns a single EAT as JSON.
 func apiEATHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
-	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{}, []string{"id", "event_title"}, valid.Query)
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{}, []string{"id", "event"}, valid.Query)
 	if err != nil {
 		return err
 	}
@@ -45,16 +49,17 @@ func apiEATHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
 		var id int
 		fmt.Sscanf(idStr, "%d", &id)
 		eat, err = fsClient.GetEAT(id)
-	} else if title := q.Get("event_title"); title != "" {
-		eat, err = fsClient.GetLatestVersion(title)
+	} else if event := q.Get("event"); event != "" {
+		eventID, _ := strconv.Atoi(event)
+		eat, err = fsClient.GetLatestVersion(eventID)
 	} else {
-		return weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("id or event_title required")}
+		return weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("id or event required")}
 	}
 
 	if err != nil {
 		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
 	}
-	if eat == nil {
+	if eat == nil || !eat.IsPublished() {
 		return weft.StatusError{Code: http.StatusNotFound, Err: errors.New("EAT not found")}
 	}
 
@@ -64,18 +69,27 @@ func apiEATHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
 
 // publishRequest is the JSON payload for the publish endpoint.
 type publishRequest struct {
-	Mode              string            `json:"mode"` // "new_event" or "new_version"
-	Location          string            `json:"location"`
-	EventDate         string            `json:"event_date"` // ISO datetime-local format
-	Magnitude         float32           `json:"magnitude"`
-	EarthquakeURL     string            `json:"earthquake_url"`
-	EventComments     string            `json:"event_comments"`
-	BeachMarineThreat bool              `json:"beach_marine_threat"`
-	LandThreat        bool              `json:"land_threat"`
-	Status            string            `json:"status"`
-	TEPActivated      bool              `json:"tep_activated"`
-	Attachments       []fastschema.File `json:"attachments"`
-	ExistingEATID     int               `json:"existing_eat_id"`
+	Mode              string   `json:"mode"`      // "new_event" or "new_version"
+	PublicID          string   `json:"public_id"` // GeoNet public ID of a new event, if it was picked from the quake feed
+	Location          string   `json:"location"`
+	EventDate         string   `json:"event_date"` // ISO datetime-local format
+	Magnitude         float32  `json:"magnitude"`
+	Latitude          *float64 `json:"latitude"`  // epicentre, null if not known
+	Longitude         *float64 `json:"longitude"` // epicentre, null if not known
+	Depth             *float64 `json:"depth"`     // km, null if not known
+	EarthquakeURL     string   `json:"earthquake_url"`
+	EventComments     string   `json:"event_comments"`
+	BeachMarineThreat bool     `json:"beach_marine_threat"`
+	LandThreat        bool     `json:"land_threat"`
+	Status            string   `json:"status"`
+	CancelReason      string   `json:"cancel_reason"` // required to cancel
+	TEPActivated      bool     `json:"tep_activated"`
+	Exercise          bool     `json:"exercise"`          // for a new event, versions follow the event
+	Attachments       []int    `json:"attachments"`       // IDs of uploaded files, looked up in FastSchema
+	EmailAttachments  []int    `json:"email_attachments"` // IDs of the attachments to send with the email
+	ExistingEATID     int      `json:"existing_eat_id"`
+	BaseVersion       int      `json:"base_version"` // version the edit is based on, 0 for a new event
+	DraftID           int      `json:"draft_id"`     // autosaved draft to discard once submitted
 }
 
 // publishResponse is the JSON response from the publish endpoint.
@@ -83,10 +97,12 @@ type publishResponse struct {
 	Success bool   `json:"success"`
 	ID      int    `json:"id,omitempty"`
 	Version int    `json:"version,omitempty"`
+	State   string `json:"state,omitempty"`
 	Error   string `json:"error,omitempty"`
 }
 
-// apiPublishHandler saves an EAT, generates a PDF, and sends email.
+// apiPublishHandler submits an EAT for approval. Nothing is sent until an
+// approver accepts it via apiApproveHandler.
 func apiPublishHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
 	if err := weft.CheckQuery(r, []string{"POST"}, []string{}, []string{}); err != nil {
 		return err
@@ -104,52 +120,93 @@ func apiPublishHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
 	if req.EventDate == "" {
 		return writePublishError(b, h, "event_date is required")
 	}
-	if req.Status != "preliminary" && req.Status != "confirmed" {
-		return writePublishError(b, h, "status must be 'preliminary' or 'confirmed'")
+	switch req.Status {
+	case fastschema.StatusPreliminary, fastschema.StatusConfirmed:
+	case fastschema.StatusCancelled:
+		if req.Mode != "new_version" {
+			return writePublishError(b, h, "only a published EAT can be cancelled")
+		}
+		if strings.TrimSpace(req.CancelReason) == "" {
+			return writePublishError(b, h, "a reason is required to cancel an EAT")
+		}
+	default:
+		return writePublishError(b, h, "status must be 'preliminary', 'confirmed' or 'cancelled'")
 	}
 
 	eventDate, err := time.Parse("2006-01-02T15:04", req.EventDate)
 	if err != nil {
 		return writePublishError(b, h, "invalid event_date format")
 	}
+	if msg := checkEpicentre(req.Latitude, req.Longitude, req.Depth); msg != "" {
+		return writePublishError(b, h, msg)
+	}
+	attachments, err := uploadedFiles(req.Attachments)
+	if err != nil {
+		return writePublishError(b, h, err.Error())
+	}
 
 	eat := &fastschema.EAT{
 		Location:          req.Location,
 		EventDate:         eventDate,
 		Magnitude:         req.Magnitude,
+		Latitude:          req.Latitude,
+		Longitude:         req.Longitude,
+		Depth:             req.Depth,
 		EarthquakeURL:     req.EarthquakeURL,
 		EventComments:     req.EventComments,
 		BeachMarineThreat: req.BeachMarineThreat,
 		LandThreat:        req.LandThreat,
 		Status:            req.Status,
 		TEPActivated:      req.TEPActivated,
-		Attachments:       req.Attachments,
+		Attachments:       attachments,
+		EmailAttachments:  emailedIDs(attachments, req.EmailAttachments),
+	}
+	if eat.IsCancelled() {
+		eat.CancelReason = strings.TrimSpace(req.CancelReason)
 	}
 
+	// The title is derived from each version's fields, so it follows
+	// revisions of the magnitude or location. The event stays the same.
+	eat.EventTitle = fastschema.FormatEventTitle(eat.Magnitude, eat.Location, eat.EventDate)
+
 	if req.Mode == "new_event" {
-		eat.EventTitle = fastschema.FormatEventTitle(eat.Magnitude, eat.Location, eat.EventDate)
-		eat.Version = 1
+		event, err := findOrCreateEvent(req.PublicID)
+		if err != nil {
+			return writePublishError(b, h, "failed to save event: "+err.Error())
+		}
+		eat.EventID = event.ID
+		eat.Exercise = req.Exercise || exerciseMode
 	} else {
-		// New version: look up existing event title and increment version
-		if req.ExistingEATID > 0 {
-			existing, err := fsClient.GetEAT(req.ExistingEATID)
-			if err != nil {
-				return writePublishError(b, h, "failed to look up existing EAT")
-			}
-			eat.EventTitle = existing.EventTitle
-		} else {
-			eat.EventTitle = fastschema.FormatEventTitle(eat.Magnitude, eat.Location, eat.EventDate)
+		if req.ExistingEATID <= 0 {
+			return writePublishError(b, h, "existing_eat_id is required for a new version")
 		}
-
-		latest, err := fsClient.GetLatestVersion(eat.EventTitle)
+		existing, err := fsClient.GetEAT(req.ExistingEATID)
 		if err != nil {
-			return writePublishError(b, h, "failed to look up latest version")
+			return writePublishError(b, h, "failed to look up existing EAT")
 		}
-		if latest != nil {
-			eat.Version = latest.Version + 1
-		} else {
-			eat.Version = 1
+		if existing.EventID == 0 {
+			return writePublishError(b, h, "the existing EAT has no event")
 		}
+		if existing.IsCancelled() && eat.IsCancelled() {
+			return writePublishError(b, h, "this EAT has already been cancelled")
+		}
+		eat.EventID = existing.EventID
+		eat.BaseVersion = req.BaseVersion
+		// An event is an exercise or not from its first version on.
+		eat.Exercise = existing.Exercise || exerciseMode
+	}
+
+	// Refuse edits based on a version that has since been superseded, so one
+	// operator can't silently overwrite another's update.
+	if err := checkBaseVersion(eat.EventID, eat.BaseVersion); err != nil {
+		return err
+	}
+
+	// The EAT is held for a second user to approve. Its version number is
+	// allocated on approval so rejected submissions don't leave gaps.
+	eat.State = fastschema.StatePendingApproval
+	if u := auth.UserFrom(r.Context()); u != nil {
+		eat.SubmittedBy = u.Name
 	}
 
 	// Save to FastSchema
@@ -158,19 +215,11 @@ func apiPublishHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
 		return writePublishError(b, h, "failed to save EAT: "+err.Error())
 	}
 
-	// Generate PDF
-	pdfBytes, err := pdf.GenerateEATPDF(created)
-	if err != nil {
-		log.Printf("warning: PDF generation failed: %v", err)
-	}
-
-	// Send email
-	emailCfg, err := email.ConfigFromEnv()
-	if err != nil {
-		log.Printf("warning: email not configured: %v", err)
-	} else if pdfBytes != nil {
-		if err := email.SendEATEmail(emailCfg, created, pdfBytes); err != nil {
-			log.Printf("warning: email send failed: %v", err)
+	if req.DraftID > 0 && eat.SubmittedBy != "" {
+		if draft, err := ownedDraft(req.DraftID, eat.SubmittedBy); err == nil {
+			if err := fsClient.DeleteDraft(draft.ID); err != nil {
+				log.Printf("warning: failed to delete draft %d: %v", draft.ID, err)
+			}
 		}
 	}
 
@@ -178,10 +227,121 @@ func apiPublishHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
 	return json.NewEncoder(b).Encode(publishResponse{
 		Success: true,
 		ID:      created.ID,
-		Version: created.Version,
+		State:   created.State,
 	})
 }
 
+// uploadedFiles returns the FastSchema records of the uploaded files with
+// the given IDs. The records are looked up rather than taken from the
+// request, as their URLs are later fetched by the server.
+func uploadedFiles(ids []int) ([]fastschema.File, error) {
+	var files []fastschema.File
+	for _, id := range ids {
+		f, err := fsClient.GetFile(id)
+		if err != nil {
+			log.Printf("warning: failed to look up attachment %d: %v", id, err)
+			return nil, fmt.Errorf("attachment %d not found", id)
+		}
+		files = append(files, *f)
+	}
+	return files, nil
+}
+
+// emailedIDs returns the IDs in emailed that are of one of attachments.
+func emailedIDs(attachments []fastschema.File, emailed []int) []int {
+	var ids []int
+	for _, id := range emailed {
+		for _, f := range attachments {
+			if f.ID == id {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// distributeEAT notifies dashboards of a newly published EAT and queues its
+// email for delivery.
+func distributeEAT(eat *fastschema.EAT) {
+	publishBroadcaster.Publish(eat)
+	enqueueDeliveries(eat)
+}
+
+// checkBaseVersion returns a 409 conflict if base is not the latest published
+// version of the event.
+func checkBaseVersion(eventID, base int) error {
+	latest, err := fsClient.GetLatestVersion(eventID)
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+
+	switch {
+	case latest == nil && base == 0:
+		return nil
+	case latest == nil:
+		return weft.StatusError{Code: http.StatusConflict, Err: fmt.Errorf("event %d has no published version %d", eventID, base)}
+	case base == 0:
+		return weft.StatusError{Code: http.StatusConflict, Err: fmt.Errorf("%s has already been published, create a new version instead", latest.EventTitle)}
+	case latest.Version != base:
+		return weft.StatusError{Code: http.StatusConflict, Err: fmt.Errorf("%s version %d has been published since this edit of version %d was started, reload it and try again",
+			latest.EventTitle, latest.Version, base)}
+	}
+
+	return nil
+}
+
+// findOrCreateEvent returns the event with publicID, creating it if need be.
+// Events entered by hand rather than picked from the quake feed get a
+// generated public ID.
+func findOrCreateEvent(publicID string) (*fastschema.Event, error) {
+	if publicID == "" {
+		id, err := newPublicID()
+		if err != nil {
+			return nil, err
+		}
+		return fsClient.CreateEvent(&fastschema.Event{PublicID: id})
+	}
+
+	event, err := fsClient.FindEvent(publicID)
+	if err != nil || event != nil {
+		return event, err
+	}
+
+	event, err = fsClient.CreateEvent(&fastschema.Event{PublicID: publicID})
+	if errors.Is(err, fastschema.ErrConflict) {
+		// Created by a concurrent submission.
+		return fsClient.FindEvent(publicID)
+	}
+	return event, err
+}
+
+// newPublicID returns a random public ID for an event that isn't in the quake
+// feed.
+func newPublicID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "eat-" + hex.EncodeToString(b), nil
+}
+
+// checkEpicentre returns why the epicentre coordinates are invalid, or "" if
+// they are valid. They are optional but latitude and longitude go together.
+func checkEpicentre(lat, lon, depth *float64) string {
+	switch {
+	case (lat == nil) != (lon == nil):
+		return "latitude and longitude must be given together"
+	case lat != nil && !(*lat >= -90 && *lat <= 90):
+		return "latitude must be -90 to 90"
+	case lon != nil && !(*lon >= -180 && *lon <= 180):
+		return "longitude must be -180 to 180"
+	case depth != nil && !(*depth >= 0 && *depth <= 1000):
+		return "depth must be 0 to 1000 km"
+	}
+	return ""
+}
+
 func writePublishError(b *bytes.Buffer, h http.Header, msg string) error {
 	h.Set("Content-Type", "application/json")
 	return json.NewEncoder(b).Encode(publishResponse{Error: msg})
@@ -219,3 +379,76 @@ func apiUploadHandler(r *http.Request, w http.ResponseWriter) (int64, error) {
 	n, err := w.Write(respBytes)
 	return int64(n), err
 }
+
+const (
+	streamRetry     = 3 * time.Second  // client reconnect delay
+	streamKeepAlive = 15 * time.Second // comment interval to keep proxies from idling out
+)
+
+// apiStreamHandler streams publish notifications to dashboards as Server-Sent Events.
+// Clients reconnecting with a Last-Event-ID header are replayed any events they missed.
+func apiStreamHandler(r *http.Request, w http.ResponseWriter) (int64, error) {
+	if err := weft.CheckQuery(r, []string{"GET"}, []string{}, []string{}); err != nil {
+		return 0, err
+	}
+
+	var lastID uint64
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid Last-Event-ID: %s", s)}
+		}
+		lastID = id
+	}
+
+	events, replay, cancel := publishBroadcaster.Subscribe(lastID)
+	defer cancel()
+
+	// The stream is long lived; don't let the server's WriteTimeout cut it off.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Once the header is written errors can't be reported to the client, so
+	// write failures (usually a closed connection) just end the stream.
+	var written int64
+	write := func(format string, args ...interface{}) bool {
+		n, err := fmt.Fprintf(w, format, args...)
+		written += int64(n)
+		if err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !write("retry: %d\n\n", streamRetry.Milliseconds()) {
+		return written, nil
+	}
+	for _, e := range replay {
+		if !write("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Name, e.Data) {
+			return written, nil
+		}
+	}
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return written, nil
+		case e := <-events:
+			if !write("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Name, e.Data) {
+				return written, nil
+			}
+		case <-ticker.C:
+			if !write(": keepalive\n\n") {
+				return written, nil
+			}
+		}
+	}
+}
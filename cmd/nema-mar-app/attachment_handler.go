@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/valid"
+)
+
+// maxAttachmentSize is the largest attachment served.
+const maxAttachmentSize = 32 << 20
+
+// attachmentHandler serves an attachment of a published EAT version. Emails
+// link here for attachments too large to send, as recipients can't reach the
+// FastSchema sidecar the file is stored on.
+func attachmentHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{"event", "version", "file"}, []string{}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	eventID, _ := strconv.Atoi(q.Get("event"))
+	version, _ := strconv.Atoi(q.Get("version"))
+	fileID, _ := strconv.Atoi(q.Get("file"))
+
+	eat, err := fsClient.GetVersion(eventID, version)
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	if eat == nil {
+		return weft.StatusError{Code: http.StatusNotFound, Err: errors.New("EAT version not found")}
+	}
+
+	// Only the version's own attachments are served, never an arbitrary file.
+	var file *fastschema.File
+	for i := range eat.Attachments {
+		if eat.Attachments[i].ID == fileID {
+			file = &eat.Attachments[i]
+		}
+	}
+	if file == nil {
+		return weft.StatusError{Code: http.StatusNotFound, Err: errors.New("attachment not found")}
+	}
+
+	data, err := fsClient.DownloadFile(*file, maxAttachmentSize)
+	if err != nil {
+		return weft.StatusError{Code: http.StatusBadGateway, Err: err}
+	}
+
+	contentType := file.Type
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	b.Write(data)
+	return nil
+}
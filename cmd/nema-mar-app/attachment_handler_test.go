@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/GeoNet/kit/weft/wefttest"
+)
+
+func TestAttachmentRoutes(t *testing.T) {
+	routes := wefttest.Requests{
+		{ID: wefttest.L(), URL: "/dashboard/attachment?event=1&version=2&file=7", User: testReader, Password: testReaderPassword, Content: "image/png"},
+		{ID: wefttest.L(), URL: "/dashboard/attachment?event=1&version=2&file=8", User: testReader, Password: testReaderPassword, Status: http.StatusNotFound},
+		{ID: wefttest.L(), URL: "/dashboard/attachment?event=2&version=1&file=7", User: testReader, Password: testReaderPassword, Status: http.StatusNotFound},
+		{ID: wefttest.L(), URL: "/dashboard/attachment?event=1&version=2", User: testReader, Password: testReaderPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/dashboard/attachment?event=1&version=2&file=7", Status: http.StatusUnauthorized},
+	}
+
+	if err := routes.DoAll(ts.URL); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAttachment(t *testing.T) {
+	if body := get(t, "/dashboard/attachment?event=1&version=2&file=7"); body != "file:map.png" {
+		t.Errorf("expected the file from FastSchema, got %q", body)
+	}
+}
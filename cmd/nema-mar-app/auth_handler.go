@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
+	"github.com/GeoNet/nema-mar-portal/internal/oidc"
+)
+
+// oidcLogin holds the OpenID Connect login configuration. It is nil when
+// OIDC login is disabled.
+var oidcLogin *oidcSettings
+
+type oidcSettings struct {
+	provider    *oidc.Provider
+	sessions    *auth.Sessions
+	groupsClaim string
+	groupRoles  map[string][]auth.Role
+}
+
+const (
+	loginStateCookie = "nema_mar_login"
+	loginStateTTL    = 10 * time.Minute
+)
+
+// loginState is kept in a short lived signed cookie between the redirect to
+// the identity provider and the callback.
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to"`
+}
+
+// authLoginHandler starts an authorization code login with the identity provider.
+func authLoginHandler(r *http.Request, w http.ResponseWriter) (int64, error) {
+	if oidcLogin == nil {
+		return 0, weft.StatusError{Code: http.StatusNotFound}
+	}
+	if err := weft.CheckQuery(r, []string{"GET"}, []string{}, []string{"return_to"}); err != nil {
+		return 0, err
+	}
+
+	state, err := oidc.RandomString(16)
+	if err != nil {
+		return 0, weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	nonce, err := oidc.RandomString(16)
+	if err != nil {
+		return 0, weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return 0, weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+
+	sealed, err := oidcLogin.sessions.Seal(loginState{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		ReturnTo: safeReturnTo(r.URL.Query().Get("return_to")),
+	}, loginStateTTL)
+	if err != nil {
+		return 0, weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginStateCookie,
+		Value:    sealed,
+		Path:     "/auth/",
+		MaxAge:   int(loginStateTTL.Seconds()),
+		Secure:   oidcLogin.sessions.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, oidcLogin.provider.AuthCodeURL(callbackURL(r), state, nonce, challenge), http.StatusFound)
+
+	return 0, nil
+}
+
+// authCallbackHandler completes the login: it exchanges the code, verifies the
+// ID token, maps the user's groups to roles and starts a session.
+func authCallbackHandler(r *http.Request, w http.ResponseWriter) (int64, error) {
+	if oidcLogin == nil {
+		return 0, weft.StatusError{Code: http.StatusNotFound}
+	}
+	// Providers add their own parameters to the callback, e.g. iss and session_state.
+	if err := weft.CheckQuery(r, []string{"GET"}, []string{},
+		[]string{"code", "state", "error", "error_description", "iss", "session_state", "scope"}); err != nil {
+		return 0, err
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		return 0, weft.StatusError{Code: http.StatusUnauthorized, Err: fmt.Errorf("login failed: %s %s", e, q.Get("error_description"))}
+	}
+
+	c, err := r.Cookie(loginStateCookie)
+	if err != nil {
+		return 0, weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("login expired, please try again")}
+	}
+	var ls loginState
+	if err := oidcLogin.sessions.Open(c.Value, &ls); err != nil {
+		return 0, weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("login expired, please try again")}
+	}
+	if q.Get("state") == "" || q.Get("state") != ls.State {
+		return 0, weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("login state mismatch")}
+	}
+	if q.Get("code") == "" {
+		return 0, weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("missing code")}
+	}
+
+	rawIDToken, err := oidcLogin.provider.Exchange(r.Context(), callbackURL(r), q.Get("code"), ls.Verifier)
+	if err != nil {
+		return 0, weft.StatusError{Code: http.StatusUnauthorized, Err: err}
+	}
+	token, err := oidcLogin.provider.Verify(r.Context(), rawIDToken, ls.Nonce)
+	if err != nil {
+		return 0, weft.StatusError{Code: http.StatusUnauthorized, Err: err}
+	}
+
+	roles := auth.RolesForGroups(oidcLogin.groupRoles, token.Strings(oidcLogin.groupsClaim))
+	if len(roles) == 0 {
+		return 0, weft.StatusError{Code: http.StatusForbidden, Err: fmt.Errorf("user %s has no roles", token.Username())}
+	}
+
+	if err := oidcLogin.sessions.Start(w, &auth.User{Name: token.Username(), Roles: roles}); err != nil {
+		return 0, weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginStateCookie, Path: "/auth/", MaxAge: -1})
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, ls.ReturnTo, http.StatusFound)
+
+	return 0, nil
+}
+
+// authLogoutHandler ends the user's session.
+func authLogoutHandler(r *http.Request, w http.ResponseWriter) (int64, error) {
+	if oidcLogin == nil {
+		return 0, weft.StatusError{Code: http.StatusNotFound}
+	}
+	if err := weft.CheckQuery(r, []string{"GET", "POST"}, []string{}, []string{}); err != nil {
+		return 0, err
+	}
+
+	oidcLogin.sessions.End(w)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	n, err := io.WriteString(w, "You have been logged out.\n")
+	return int64(n), err
+}
+
+// callbackURL is the redirect URI registered with the identity provider.
+func callbackURL(r *http.Request) string {
+	return baseURL(r) + "/auth/callback"
+}
+
+// safeReturnTo only allows local paths, so the login can't be used as an open redirect.
+func safeReturnTo(s string) string {
+	if !strings.HasPrefix(s, "/") || strings.HasPrefix(s, "//") || strings.HasPrefix(s, "/\\") {
+		return "/dashboard"
+	}
+	return s
+}
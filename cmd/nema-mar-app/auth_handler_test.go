@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"testing"
+
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
+	"github.com/GeoNet/nema-mar-portal/internal/oidc"
+	"github.com/GeoNet/nema-mar-portal/internal/oidc/oidctest"
+)
+
+// withOIDC enables OIDC login against idp for the duration of a test.
+func withOIDC(t *testing.T, idp *oidctest.IdP) {
+	t.Helper()
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := auth.NewSessions([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions.Secure = false
+
+	groupRoles, err := auth.ParseGroupRoles("ngmc=editor,nema=reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevLogin, prevAuth := oidcLogin, authenticator
+	oidcLogin = &oidcSettings{
+		provider:    provider,
+		sessions:    sessions,
+		groupsClaim: "groups",
+		groupRoles:  groupRoles,
+	}
+	authenticator = auth.Multi{sessions, prevAuth}
+	t.Cleanup(func() {
+		oidcLogin, authenticator = prevLogin, prevAuth
+	})
+}
+
+func browserGet(t *testing.T, c *http.Client, url string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/html")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := oidctest.NewIdP("nema-mar-app", "secret")
+	defer idp.Close()
+	idp.Groups = []string{"nema"}
+	withOIDC(t, idp)
+
+	jar, _ := cookiejar.New(nil)
+	c := &http.Client{Jar: jar}
+
+	// An unauthenticated browser is sent through the IdP and back to the dashboard.
+	resp := browserGet(t, c, ts.URL+"/dashboard")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after login, got %d", resp.StatusCode)
+	}
+	if resp.Request.URL.Path != "/dashboard" {
+		t.Errorf("expected to return to /dashboard, ended at %s", resp.Request.URL)
+	}
+
+	// The session grants reader, but not editor, rights.
+	resp = browserGet(t, c, ts.URL+"/gha-portal")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for reader on portal, got %d", resp.StatusCode)
+	}
+
+	// Basic auth users still work alongside OIDC.
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/gha-portal", nil)
+	req.SetBasicAuth(testEditor, testEditorPassword)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for basic auth editor, got %d", resp.StatusCode)
+	}
+
+	// After logout the session no longer works.
+	resp = browserGet(t, c, ts.URL+"/auth/logout")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from logout, got %d", resp.StatusCode)
+	}
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp = browserGet(t, c, ts.URL+"/dashboard")
+	if resp.StatusCode != http.StatusSeeOther || !strings.HasPrefix(resp.Header.Get("Location"), "/auth/login") {
+		t.Errorf("expected redirect to login after logout, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestOIDCLoginNoRoles(t *testing.T) {
+	idp := oidctest.NewIdP("nema-mar-app", "secret")
+	defer idp.Close()
+	idp.Groups = []string{"unmapped"}
+	withOIDC(t, idp)
+
+	jar, _ := cookiejar.New(nil)
+	resp := browserGet(t, &http.Client{Jar: jar}, ts.URL+"/dashboard")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for user without roles, got %d", resp.StatusCode)
+	}
+}
+
+func TestOIDCCallbackStateMismatch(t *testing.T) {
+	idp := oidctest.NewIdP("nema-mar-app", "secret")
+	defer idp.Close()
+	withOIDC(t, idp)
+
+	jar, _ := cookiejar.New(nil)
+	c := &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	// Start a login to get the state cookie, then call back with a forged state.
+	resp := browserGet(t, c, ts.URL+"/auth/login")
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected redirect to IdP, got %d", resp.StatusCode)
+	}
+
+	resp = browserGet(t, c, ts.URL+"/auth/callback?code=abc&state=forged")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for state mismatch, got %d", resp.StatusCode)
+	}
+}
+
+func TestSafeReturnTo(t *testing.T) {
+	tests := map[string]string{
+		"/dashboard?version=2": "/dashboard?version=2",
+		"":                     "/dashboard",
+		"https://evil.com":     "/dashboard",
+		"//evil.com":           "/dashboard",
+		"/\\evil.com":          "/dashboard",
+	}
+	for in, want := range tests {
+		if got := safeReturnTo(in); got != want {
+			t.Errorf("safeReturnTo(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+const (
+	broadcastHistory = 50 // events kept for Last-Event-ID replay
+	broadcastBuffer  = 16 // per-subscriber channel buffer
+)
+
+// streamEvent is a publish notification delivered to dashboard streams.
+type streamEvent struct {
+	ID   uint64
+	Name string
+	Data []byte
+}
+
+// publishNotice is the JSON payload of a "publish" stream event.
+type publishNotice struct {
+	ID         int    `json:"id"`
+	EventID    int    `json:"event_id"`
+	EventTitle string `json:"event_title"`
+	Version    int    `json:"version"`
+	Status     string `json:"status"`
+	Exercise   bool   `json:"exercise"`
+}
+
+// broadcaster fans out publish notifications to every open dashboard stream.
+// Recent events are kept so reconnecting clients can replay what they missed.
+type broadcaster struct {
+	mu      sync.Mutex
+	lastID  uint64
+	history []streamEvent
+	subs    map[chan streamEvent]struct{}
+}
+
+var publishBroadcaster = newBroadcaster()
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{
+		subs: make(map[chan streamEvent]struct{}),
+	}
+}
+
+// Publish notifies all subscribers that eat has been published.
+func (b *broadcaster) Publish(eat *fastschema.EAT) {
+	data, err := json.Marshal(publishNotice{
+		ID:         eat.ID,
+		EventID:    eat.EventID,
+		EventTitle: eat.EventTitle,
+		Version:    eat.Version,
+		Status:     eat.Status,
+		Exercise:   eat.Exercise,
+	})
+	if err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e := streamEvent{ID: b.lastID, Name: "publish", Data: data}
+
+	b.history = append(b.history, e)
+	if len(b.history) > broadcastHistory {
+		b.history = b.history[len(b.history)-broadcastHistory:]
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// Slow subscriber; drop rather than block the publisher.
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. Events in the history with an ID
+// greater than lastID are returned for replay. The returned cancel func must
+// be called to unsubscribe.
+func (b *broadcaster) Subscribe(lastID uint64) (<-chan streamEvent, []streamEvent, func()) {
+	ch := make(chan streamEvent, broadcastBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []streamEvent
+	if lastID > 0 {
+		for _, e := range b.history {
+			if e.ID > lastID {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	b.subs[ch] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, ch)
+	}
+
+	return ch, replay, cancel
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestBroadcasterReplay(t *testing.T) {
+	b := newBroadcaster()
+
+	b.Publish(&fastschema.EAT{ID: 1, EventTitle: "M5.0-Wellington-2026-01-01", Version: 1})
+	b.Publish(&fastschema.EAT{ID: 2, EventTitle: "M5.0-Wellington-2026-01-01", Version: 2})
+	b.Publish(&fastschema.EAT{ID: 3, EventTitle: "M5.0-Wellington-2026-01-01", Version: 3})
+
+	_, replay, cancel := b.Subscribe(1)
+	defer cancel()
+
+	if len(replay) != 2 {
+		t.Fatalf("expected 2 replayed events, got %d", len(replay))
+	}
+	if replay[0].ID != 2 || replay[1].ID != 3 {
+		t.Errorf("unexpected replay IDs: %d, %d", replay[0].ID, replay[1].ID)
+	}
+
+	_, replay, cancel2 := b.Subscribe(0)
+	defer cancel2()
+	if len(replay) != 0 {
+		t.Errorf("expected no replay for a new subscriber, got %d", len(replay))
+	}
+}
+
+func TestBroadcasterLive(t *testing.T) {
+	b := newBroadcaster()
+
+	events, _, cancel := b.Subscribe(0)
+	b.Publish(&fastschema.EAT{ID: 7, EventTitle: "M6.0-Kaikoura-2026-02-01", Version: 1})
+
+	select {
+	case e := <-events:
+		if e.Name != "publish" {
+			t.Errorf("expected publish event, got %s", e.Name)
+		}
+		if !strings.Contains(string(e.Data), `"event_title":"M6.0-Kaikoura-2026-02-01"`) {
+			t.Errorf("unexpected data: %s", e.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	cancel()
+	b.Publish(&fastschema.EAT{ID: 8, EventTitle: "M6.0-Kaikoura-2026-02-01", Version: 2})
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event after cancel: %d", e.ID)
+	default:
+	}
+}
+
+func TestStream(t *testing.T) {
+	publishBroadcaster.Publish(&fastschema.EAT{ID: 10, EventTitle: "M5.5-Napier-2026-03-01", Version: 1})
+	lastID := publishBroadcaster.lastID
+	publishBroadcaster.Publish(&fastschema.EAT{ID: 11, EventTitle: "M5.5-Napier-2026-03-01", Version: 2})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(testReader, testReaderPassword)
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(lastID, 10))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	readData := func() string {
+		for sc.Scan() {
+			if line := sc.Text(); strings.HasPrefix(line, "data: ") {
+				return line
+			}
+		}
+		t.Fatalf("stream ended: %v", sc.Err())
+		return ""
+	}
+
+	// The missed version 2 is replayed first.
+	if line := readData(); !strings.Contains(line, `"version":2`) {
+		t.Errorf("expected replay of version 2, got %s", line)
+	}
+
+	publishBroadcaster.Publish(&fastschema.EAT{ID: 12, EventTitle: "M5.5-Napier-2026-03-01", Version: 3})
+	if line := readData(); !strings.Contains(line, `"version":3`) {
+		t.Errorf("expected live version 3, got %s", line)
+	}
+}
+
+func TestStreamBadLastEventID(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(testReader, testReaderPassword)
+	req.Header.Set("Last-Event-ID", "abc")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestPublishCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"valid", `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"cancelled","cancel_reason":"Issued for the wrong event."}`, ""},
+		{"no reason", `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"cancelled","cancel_reason":"  "}`, "a reason is required to cancel an EAT"},
+		{"new event", `{"mode":"new_event","location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"cancelled","cancel_reason":"Test"}`, "only a published EAT can be cancelled"},
+		{"bad status", `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"withdrawn"}`, "status must be 'preliminary', 'confirmed' or 'cancelled'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := submit(t, tt.body)
+			defer resp.Body.Close()
+
+			var pr publishResponse
+			if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+				t.Fatal(err)
+			}
+			if pr.Error != tt.err {
+				t.Errorf("expected error %q, got %q", tt.err, pr.Error)
+			}
+			if pr.Success != (tt.err == "") {
+				t.Errorf("expected success %t, got %+v", tt.err == "", pr)
+			}
+		})
+	}
+}
+
+func TestDashboardCancelled(t *testing.T) {
+	eat := mockEATs["1"]
+	eat.Version = 2
+	eat.Status = fastschema.StatusCancelled
+	eat.CancelReason = "Issued for the wrong event."
+
+	var b bytes.Buffer
+	if err := dashboardTemplate.ExecuteTemplate(&b, "base", Page{CurrentEAT: &eat, LatestVersion: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `<strong style="font-size:1.6em;">CANCELLED</strong>`) ||
+		!strings.Contains(b.String(), "Reason: Issued for the wrong event.") {
+		t.Errorf("expected the cancelled banner and reason, got:\n%s", b.String())
+	}
+
+	eat.Status = fastschema.StatusConfirmed
+	b.Reset()
+	if err := dashboardTemplate.ExecuteTemplate(&b, "base", Page{CurrentEAT: &eat, LatestVersion: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "cancelled-banner") {
+		t.Error("expected no cancelled banner on a confirmed EAT")
+	}
+}
+
+func TestReviewCancelled(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, ts.URL+"/gha-portal/review?id=52", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBasicAuth(testApprover, testApproverPassword)
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(body), "Reason for Cancellation") || !strings.Contains(string(body), "Duplicate of event 2.") {
+		t.Errorf("expected the cancellation reason for review, got:\n%s", body)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/cap"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/valid"
+)
+
+var capOptions = cap.Options{
+	Sender:     cap.DefaultSender,
+	SenderName: cap.DefaultSenderName,
+}
+
+// apiCAPHandler returns a single EAT as a CAP 1.2 alert.
+func apiCAPHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{}, []string{"id", "event", "version"}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	var eat *fastschema.EAT
+
+	switch {
+	case q.Get("id") != "":
+		id, _ := strconv.Atoi(q.Get("id"))
+		eat, err = fsClient.GetEAT(id)
+	case q.Get("event") != "" && q.Get("version") != "":
+		eventID, _ := strconv.Atoi(q.Get("event"))
+		version, _ := strconv.Atoi(q.Get("version"))
+		eat, err = fsClient.GetVersion(eventID, version)
+	case q.Get("event") != "":
+		eventID, _ := strconv.Atoi(q.Get("event"))
+		eat, err = fsClient.GetLatestVersion(eventID)
+	default:
+		return weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("id or event required")}
+	}
+
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	// Pending and rejected EATs are only for the review pages, never to be
+	// exported as alerts.
+	if eat == nil || !eat.IsPublished() {
+		return weft.StatusError{Code: http.StatusNotFound, Err: errors.New("EAT not found")}
+	}
+
+	var previous []fastschema.EAT
+	if eat.Version > 1 && eat.EventID > 0 {
+		previous, err = fsClient.ListVersions(eat.EventID)
+		if err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+	}
+
+	out, err := cap.FromEAT(eat, previous, capOptions).Marshal()
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+
+	h.Set("Content-Type", cap.ContentType)
+	_, err = b.Write(out)
+	return err
+}
@@ -2,59 +2,73 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
 	"github.com/GeoNet/nema-mar-portal/internal/valid"
 )
 
 // dashboardHandler serves the read-only dashboard page.
 func dashboardHandler(r *http.Request, h http.Header, b *bytes.Buffer, nonce string) error {
-	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{}, []string{"event_title", "version"}, valid.Query)
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{}, []string{"event", "version", "exercise"}, valid.Query)
 	if err != nil {
 		return err
 	}
 
-	page := Page{Nonce: nonce}
+	page := Page{Nonce: nonce, User: auth.UserFrom(r.Context()), ShowExercises: showExercises(q)}
 
-	eventTitle := q.Get("event_title")
+	eventID, _ := strconv.Atoi(q.Get("event"))
 	versionStr := q.Get("version")
 
-	if eventTitle != "" && versionStr != "" {
+	if eventID > 0 && versionStr != "" {
 		// Show specific version
 		version, _ := strconv.Atoi(versionStr)
-		_ = version
-		// Get the specific version by listing with filter
-		eat, err := fsClient.GetLatestVersion(eventTitle)
+		eat, err := fsClient.GetVersion(eventID, version)
 		if err != nil {
 			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
 		}
+		if eat == nil {
+			return weft.StatusError{Code: http.StatusNotFound, Err: errors.New("EAT version not found")}
+		}
 		page.CurrentEAT = eat
+
+		latest, err := fsClient.GetLatestVersion(eventID)
+		if err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+		if latest != nil {
+			page.LatestVersion = latest.Version
+		}
 	} else {
-		// Show the latest EAT
+		// Show the latest EAT, of a real event unless exercises are shown
 		events, err := fsClient.ListDistinctEvents(7)
 		if err != nil {
 			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
 		}
-		if len(events) > 0 {
-			eat, err := fsClient.GetLatestVersion(events[0])
+		for _, e := range events {
+			if e.Exercise && !page.ShowExercises {
+				continue
+			}
+			eat, err := fsClient.GetLatestVersion(e.ID)
 			if err != nil {
 				return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
 			}
 			page.CurrentEAT = eat
+			if eat != nil {
+				page.LatestVersion = eat.Version
+			}
+			break
 		}
 	}
 
 	// Load version history if we have a current EAT
 	if page.CurrentEAT != nil {
-		eats, err := fsClient.ListEATs(page.CurrentEAT.EventDate.AddDate(0, 0, -1))
+		versions, err := fsClient.ListVersions(page.CurrentEAT.EventID)
 		if err == nil {
-			for _, e := range eats {
-				if e.EventTitle == page.CurrentEAT.EventTitle {
-					page.Versions = append(page.Versions, e)
-				}
-			}
+			page.Versions = versions
 		}
 	}
 
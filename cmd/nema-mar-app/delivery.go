@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
+	"github.com/GeoNet/nema-mar-portal/internal/email"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/outbox"
+	"github.com/GeoNet/nema-mar-portal/internal/pdf"
+	"github.com/GeoNet/nema-mar-portal/internal/valid"
+)
+
+var (
+	emailConfig  email.Config
+	outboxWorker *outbox.Worker // nil when email isn't configured
+	pdfLayout    *pdf.Layout    // branding of the emailed PDF, from PDF_LAYOUT_FILE
+)
+
+// enqueueDeliveries adds a delivery job to the outbox for each recipient the
+// routing rules send eat to, and wakes the worker to send them.
+func enqueueDeliveries(eat *fastschema.EAT) {
+	if outboxWorker == nil {
+		log.Printf("warning: email not configured, %s version %d not emailed", eat.EventTitle, eat.Version)
+		return
+	}
+
+	recipients := recipientsFor(eat)
+	if len(recipients) == 0 {
+		log.Printf("warning: no recipient groups match %s version %d, not emailed", eat.EventTitle, eat.Version)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, rcpt := range recipients {
+		_, err := fsClient.CreateDelivery(&fastschema.Delivery{
+			EATID:         eat.ID,
+			EventTitle:    eat.EventTitle,
+			Version:       eat.Version,
+			Recipient:     rcpt,
+			Status:        fastschema.DeliveryPending,
+			NextAttemptAt: now,
+		})
+		if err != nil {
+			log.Printf("warning: failed to enqueue email of EAT %d to %s: %v", eat.ID, rcpt, err)
+		}
+	}
+
+	outboxWorker.Wake()
+}
+
+// recipientsFor returns the addresses eat is emailed to. A cancellation goes
+// to everyone the event's earlier versions were sent to, so it reaches the
+// recipients of the advisory it withdraws even if its threats route
+// differently. It falls back to the routing rules if there are none.
+func recipientsFor(eat *fastschema.EAT) []string {
+	if !eat.IsCancelled() {
+		return emailConfig.RecipientsFor(eat)
+	}
+	if recipients := previousRecipients(eat); len(recipients) > 0 {
+		return recipients
+	}
+	return emailConfig.RecipientsFor(eat)
+}
+
+// previousRecipients returns everyone the other versions of eat's event were
+// emailed to.
+func previousRecipients(eat *fastschema.EAT) []string {
+	versions, err := fsClient.ListVersions(eat.EventID)
+	if err != nil {
+		log.Printf("warning: failed to list versions of event %d, routing its cancellation: %v", eat.EventID, err)
+		return nil
+	}
+
+	var recipients []string
+	seen := make(map[string]bool)
+	for _, v := range versions {
+		if v.ID == eat.ID {
+			continue
+		}
+		deliveries, err := fsClient.ListDeliveries(v.ID)
+		if err != nil {
+			log.Printf("warning: failed to list deliveries of EAT %d: %v", v.ID, err)
+			continue
+		}
+		for _, d := range deliveries {
+			if k := strings.ToLower(d.Recipient); !seen[k] {
+				seen[k] = true
+				recipients = append(recipients, d.Recipient)
+			}
+		}
+	}
+
+	return recipients
+}
+
+// deliverEAT sends the email for one outbox job. Failing to build the
+// message is treated as a deferral so the job is retried.
+func deliverEAT(d *fastschema.Delivery) email.Result {
+	parts, err := messageParts.get(d.EATID, func() (*emailParts, error) { return buildEmailParts(d.EATID) })
+	if err != nil {
+		return email.Result{Recipient: d.Recipient, Outcome: email.Deferred, Message: err.Error()}
+	}
+
+	to := []string{d.Recipient}
+	msg, err := email.Message(emailConfig, parts.eat, parts.pdf, parts.attachments, parts.changes, to)
+	if err != nil {
+		return email.Result{Recipient: d.Recipient, Outcome: email.Deferred, Message: fmt.Sprintf("build message: %v", err)}
+	}
+	return email.Send(emailConfig, to, msg)[0]
+}
+
+// emailParts are the parts of an EAT version's email shared by all its
+// recipients.
+type emailParts struct {
+	eat         *fastschema.EAT
+	pdf         []byte
+	attachments []email.Attachment
+	changes     []string
+}
+
+// buildEmailParts fetches the EAT, generates its PDF and fetches the
+// attachments sent with it.
+func buildEmailParts(eatID int) (*emailParts, error) {
+	eat, err := fsClient.GetEAT(eatID)
+	if err != nil {
+		return nil, fmt.Errorf("get EAT %d: %w", eatID, err)
+	}
+	if eat == nil {
+		return nil, fmt.Errorf("get EAT %d: not found", eatID)
+	}
+
+	changes := changesSincePrevious(eat)
+
+	pdfBytes, err := pdf.GenerateEATPDF(eat, pdf.Options{Layout: pdfLayout, Fetch: fsClient.DownloadFile, Changes: changes})
+	if err != nil {
+		return nil, fmt.Errorf("generate PDF: %w", err)
+	}
+
+	return &emailParts{eat: eat, pdf: pdfBytes, attachments: emailAttachments(eat), changes: changes}, nil
+}
+
+// maxCachedEmails is the number of EATs whose email parts are kept.
+const maxCachedEmails = 3
+
+// messageParts caches the email parts of the most recently delivered EATs.
+// A published EAT doesn't change, so its parts are built once for all its
+// recipients and retries, and each job only addresses and signs the
+// message. It also means every recipient gets the same attachments, even if
+// fetching one failed the first time and it was linked instead.
+var messageParts = &partsCache{}
+
+// partsCache holds emailParts by EAT ID, evicting the least recently used.
+type partsCache struct {
+	mu      sync.Mutex
+	entries []*partsEntry // least recently used first
+}
+
+type partsEntry struct {
+	eatID int
+	ready chan struct{} // closed once parts or err is set
+	parts *emailParts
+	err   error
+}
+
+// get returns the parts for eatID, calling build if they aren't cached. A
+// failed build isn't cached, so the next attempt tries again.
+func (c *partsCache) get(eatID int, build func() (*emailParts, error)) (*emailParts, error) {
+	c.mu.Lock()
+	for i, e := range c.entries {
+		if e.eatID == eatID {
+			c.entries = append(append(c.entries[:i:i], c.entries[i+1:]...), e)
+			c.mu.Unlock()
+			<-e.ready
+			return e.parts, e.err
+		}
+	}
+
+	e := &partsEntry{eatID: eatID, ready: make(chan struct{})}
+	c.entries = append(c.entries, e)
+	if len(c.entries) > maxCachedEmails {
+		c.entries = append([]*partsEntry(nil), c.entries[len(c.entries)-maxCachedEmails:]...)
+	}
+	c.mu.Unlock()
+
+	e.parts, e.err = build()
+	if e.err != nil {
+		c.remove(e)
+	}
+	close(e.ready)
+
+	return e.parts, e.err
+}
+
+func (c *partsCache) remove(e *partsEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, o := range c.entries {
+		if o == e {
+			c.entries = append(c.entries[:i:i], c.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// emailAttachments fetches the attachments selected to be sent with eat's
+// email from the object store, up to the configured size cap. Attachments
+// that don't fit or can't be fetched are linked instead.
+func emailAttachments(eat *fastschema.EAT) []email.Attachment {
+	return email.FetchAttachments(eat.EmailedAttachments(), emailConfig.MaxAttachmentSize, func(f fastschema.File, max int64) ([]byte, error) {
+		b, err := fsClient.DownloadFile(f, max)
+		if err != nil && !errors.Is(err, fastschema.ErrTooLarge) {
+			log.Printf("warning: linking attachment %s of EAT %d: %v", f.Name, eat.ID, err)
+		}
+		return b, err
+	})
+}
+
+// deliveriesPageHandler shows operators the email delivery status of an EAT.
+func deliveriesPageHandler(r *http.Request, h http.Header, b *bytes.Buffer, nonce string) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{"id"}, []string{}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	id, _ := strconv.Atoi(q.Get("id"))
+	eat, err := fsClient.GetEAT(id)
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	if eat == nil || !eat.IsPublished() {
+		return weft.StatusError{Code: http.StatusNotFound, Err: errors.New("EAT not found")}
+	}
+
+	deliveries, err := fsClient.ListDeliveries(eat.ID)
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+
+	page := Page{
+		Nonce:      nonce,
+		User:       auth.UserFrom(r.Context()),
+		CurrentEAT: eat,
+		Deliveries: deliveries,
+	}
+
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	return deliveriesTemplate.ExecuteTemplate(b, "base", page)
+}
+
+// resendRequest is the JSON payload for the resend endpoint.
+type resendRequest struct {
+	EATID     int    `json:"eat_id"`
+	Recipient string `json:"recipient"` // empty to resend to every recipient not yet sent
+}
+
+// resendResponse is the JSON response from the resend endpoint.
+type resendResponse struct {
+	Success bool `json:"success"`
+	Resent  int  `json:"resent"`
+}
+
+// apiResendHandler queues an EAT version's email to be sent again, either to
+// one of its recipients or to all those whose delivery failed or is still
+// being retried.
+func apiResendHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	if err := weft.CheckQuery(r, []string{"POST"}, []string{}, []string{}); err != nil {
+		return err
+	}
+
+	if outboxWorker == nil {
+		return weft.StatusError{Code: http.StatusServiceUnavailable, Err: errors.New("email is not configured")}
+	}
+
+	var req resendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid JSON: %w", err)}
+	}
+
+	user := auth.UserFrom(r.Context())
+	if user == nil {
+		return weft.StatusError{Code: http.StatusUnauthorized, Err: errors.New("unknown user")}
+	}
+
+	deliveries, err := fsClient.ListDeliveries(req.EATID)
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+
+	// Only addresses the EAT was originally sent to can be resent to, so the
+	// portal can't be used to mail arbitrary addresses.
+	var resend []fastschema.Delivery
+	for _, d := range deliveries {
+		switch {
+		case req.Recipient != "" && strings.EqualFold(d.Recipient, req.Recipient):
+			resend = append(resend, d)
+		case req.Recipient == "" && d.Status != fastschema.DeliverySent:
+			resend = append(resend, d)
+		}
+	}
+	if req.Recipient != "" && len(resend) == 0 {
+		return weft.StatusError{Code: http.StatusNotFound, Err: fmt.Errorf("EAT %d was not sent to %s", req.EATID, req.Recipient)}
+	}
+
+	now := time.Now().UTC()
+	for i := range resend {
+		outbox.Resend(&resend[i], user.Name, now)
+		if err := fsClient.UpdateDelivery(&resend[i]); err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+	}
+	outboxWorker.Wake()
+
+	h.Set("Content-Type", "application/json")
+	return json.NewEncoder(b).Encode(resendResponse{Success: true, Resent: len(resend)})
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/GeoNet/kit/weft/wefttest"
+	"github.com/GeoNet/nema-mar-portal/internal/email"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/outbox"
+)
+
+func TestDeliveriesPage(t *testing.T) {
+	routes := wefttest.Requests{
+		{ID: wefttest.L(), URL: "/gha-portal/deliveries?id=1", User: testEditor, Password: testEditorPassword, Content: "text/html; charset=utf-8"},
+		{ID: wefttest.L(), URL: "/gha-portal/deliveries", User: testEditor, Password: testEditorPassword, Status: http.StatusBadRequest},
+		// Pending EATs haven't been emailed
+		{ID: wefttest.L(), URL: "/gha-portal/deliveries?id=51", User: testEditor, Password: testEditorPassword, Status: http.StatusNotFound},
+	}
+	if err := routes.DoAll(ts.URL); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEnqueueDeliveries(t *testing.T) {
+	oldCfg, oldWorker := emailConfig, outboxWorker
+	defer func() { emailConfig, outboxWorker = oldCfg, oldWorker }()
+
+	emailConfig = email.Config{Recipients: []string{"a@example.com", "b@example.com"}}
+	outboxWorker = outbox.NewWorker(fsClient, deliverEAT)
+
+	enqueueDeliveries(&fastschema.EAT{ID: 1, EventTitle: "M5.0-Wellington-2026-01-01", Version: 1})
+
+	for _, want := range emailConfig.Recipients {
+		d := <-createdDeliveries
+		if d.Recipient != want || d.EATID != 1 || d.Status != fastschema.DeliveryPending {
+			t.Errorf("unexpected delivery for %s: %+v", want, d)
+		}
+		if d.NextAttemptAt.IsZero() {
+			t.Errorf("delivery to %s not scheduled", want)
+		}
+	}
+}
+
+func TestRecipientsForCancelled(t *testing.T) {
+	oldCfg := emailConfig
+	defer func() { emailConfig = oldCfg }()
+
+	emailConfig = email.Config{Recipients: []string{"routed@example.com"}}
+
+	// Event 1's version 1 was sent to the mock deliveries.
+	eat := &fastschema.EAT{ID: 99, EventID: 1, Version: 2, Status: fastschema.StatusCancelled}
+	got := strings.Join(recipientsFor(eat), ",")
+	if want := "ok@example.com,down@example.com,bad@example.com"; got != want {
+		t.Errorf("expected the original recipients %s, got %s", want, got)
+	}
+
+	// An event with no earlier deliveries falls back to routing.
+	eat.EventID = 2
+	if got := strings.Join(recipientsFor(eat), ","); got != "routed@example.com" {
+		t.Errorf("expected the routed recipients, got %s", got)
+	}
+
+	// Other EATs are routed.
+	eat.EventID, eat.Status = 1, fastschema.StatusConfirmed
+	if got := strings.Join(recipientsFor(eat), ","); got != "routed@example.com" {
+		t.Errorf("expected the routed recipients, got %s", got)
+	}
+}
+
+func TestResend(t *testing.T) {
+	oldWorker := outboxWorker
+	defer func() { outboxWorker = oldWorker }()
+
+	outboxWorker = nil
+	routes := wefttest.Requests{
+		{ID: wefttest.L(), URL: "/api/resend", Method: "POST", PostBody: []byte(`{"eat_id":1}`), User: testEditor, Password: testEditorPassword, Status: http.StatusServiceUnavailable},
+	}
+	if err := routes.DoAll(ts.URL); err != nil {
+		t.Error(err)
+	}
+
+	outboxWorker = outbox.NewWorker(fsClient, deliverEAT)
+
+	tests := []struct {
+		name   string
+		body   string
+		code   int
+		resent int
+	}{
+		{"one recipient", `{"eat_id":1,"recipient":"ok@example.com"}`, http.StatusOK, 1},
+		{"all failed", `{"eat_id":1}`, http.StatusOK, 2},
+		{"not a recipient", `{"eat_id":1,"recipient":"someone@example.com"}`, http.StatusNotFound, 0},
+		{"bad JSON", `{`, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodPost, ts.URL+"/api/resend", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.SetBasicAuth(testEditor, testEditorPassword)
+
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.code {
+				t.Fatalf("expected %d, got %d", tt.code, resp.StatusCode)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var rr resendResponse
+			if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+				t.Fatal(err)
+			}
+			if rr.Resent != tt.resent {
+				t.Errorf("expected %d resent, got %d", tt.resent, rr.Resent)
+			}
+		})
+	}
+}
+
+func TestPreviewEmailGroups(t *testing.T) {
+	oldCfg, oldWorker := emailConfig, outboxWorker
+	defer func() { emailConfig, outboxWorker = oldCfg, oldWorker }()
+
+	routing, err := email.ParseRouting([]byte(`{"groups":[
+		{"name":"duty-officers","recipients":["duty@example.com"]},
+		{"name":"marine","recipients":["harbours@example.com"],"when":{"beach_marine_threat":true}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preview := func(form url.Values) string {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodPost, ts.URL+"/gha-portal/preview", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth(testEditor, testEditorPassword)
+		res, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", res.StatusCode, b)
+		}
+		return string(b)
+	}
+
+	outboxWorker = nil
+	if body := preview(url.Values{"location": {"Wellington"}}); !strings.Contains(body, "Email is not configured") {
+		t.Error("expected preview to say email is not configured")
+	}
+
+	emailConfig = email.Config{Routing: routing}
+	outboxWorker = outbox.NewWorker(fsClient, deliverEAT)
+
+	body := preview(url.Values{"location": {"Wellington"}})
+	if !strings.Contains(body, "duty-officers (1 recipient)") || strings.Contains(body, "marine (") {
+		t.Error("expected only duty-officers group without a marine threat")
+	}
+
+	body = preview(url.Values{"location": {"Wellington"}, "beach_marine_threat": {"on"}})
+	if !strings.Contains(body, "duty-officers (1 recipient)") || !strings.Contains(body, "marine (1 recipient)") {
+		t.Error("expected duty-officers and marine groups with a marine threat")
+	}
+
+	// A cancellation of event 1 goes to the recipients of its version 1,
+	// whatever the routing.
+	body = preview(url.Values{"location": {"Wellington"}, "status": {fastschema.StatusCancelled}, "existing_eat_id": {"1"}})
+	if !strings.Contains(body, "all previous recipients of the event (3 recipients)") || strings.Contains(body, "duty-officers (") {
+		t.Error("expected the cancellation to go to all previous recipients")
+	}
+}
+
+func TestDeliverEAT(t *testing.T) {
+	oldCfg := emailConfig
+	defer func() { emailConfig = oldCfg }()
+
+	dir := t.TempDir()
+	emailConfig = email.Config{FromAddr: "eat@example.com", Transport: &email.File{Dir: dir}}
+
+	res := deliverEAT(&fastschema.Delivery{EATID: 1, Recipient: "ok@example.com"})
+	if res.Outcome != email.Accepted {
+		t.Fatalf("expected accepted, got %+v", res)
+	}
+
+	b, err := os.ReadFile(res.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(b)
+	for _, want := range []string{
+		"Delivered-To: ok@example.com\r\n",
+		"To: ok@example.com\r\n",
+		"Subject: EAT: M5.0-Wellington-2026-01-01 (Version 1)",
+		"Content-Type: application/pdf",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected sent message to contain %q", want)
+		}
+	}
+}
+
+func TestDeliverEATAttachments(t *testing.T) {
+	oldCfg := emailConfig
+	defer func() { emailConfig = oldCfg }()
+
+	dir := t.TempDir()
+	emailConfig = email.Config{FromAddr: "eat@example.com", PublicURL: "https://portal.example.com", Transport: &email.File{Dir: dir}, MaxAttachmentSize: 1 << 20}
+
+	res := deliverEAT(&fastschema.Delivery{EATID: 2, Recipient: "ok@example.com"})
+	if res.Outcome != email.Accepted {
+		t.Fatalf("expected accepted, got %+v", res)
+	}
+
+	b, err := os.ReadFile(res.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(b)
+
+	// map.png fits under the cap, bulletin.pdf is linked through the
+	// dashboard, and notes.txt wasn't selected.
+	if !strings.Contains(msg, "filename=map.png") || !strings.Contains(msg, base64.StdEncoding.EncodeToString([]byte("file:map.png"))) {
+		t.Error("expected map.png to be attached")
+	}
+	if strings.Contains(msg, "filename=bulletin.pdf") || !strings.Contains(msg, "bulletin.pdf: https://portal.example.com/dashboard/attachment?event=") || strings.Contains(msg, "/files/bulletin.pdf") {
+		t.Error("expected bulletin.pdf to be linked")
+	}
+	if strings.Contains(msg, "notes.txt") {
+		t.Error("expected notes.txt not to be emailed")
+	}
+}
+
+func TestPartsCache(t *testing.T) {
+	var c partsCache
+	builds := make(map[int]int)
+	build := func(id int) func() (*emailParts, error) {
+		return func() (*emailParts, error) {
+			builds[id]++
+			return &emailParts{eat: &fastschema.EAT{ID: id}}, nil
+		}
+	}
+
+	for range 3 {
+		p, err := c.get(1, build(1))
+		if err != nil || p.eat.ID != 1 {
+			t.Fatalf("unexpected parts %+v, %v", p, err)
+		}
+	}
+	if builds[1] != 1 {
+		t.Errorf("expected the parts of EAT 1 built once, got %d", builds[1])
+	}
+
+	// A failed build is retried.
+	if _, err := c.get(2, func() (*emailParts, error) { return nil, errors.New("fastschema unavailable") }); err == nil {
+		t.Error("expected the build error")
+	}
+	if p, err := c.get(2, build(2)); err != nil || p.eat.ID != 2 {
+		t.Errorf("expected EAT 2 built on retry, got %+v, %v", p, err)
+	}
+
+	// EAT 1 was used least recently, so is evicted first.
+	for id := 3; id <= maxCachedEmails+1; id++ {
+		c.get(id, build(id))
+	}
+	c.get(2, build(2))
+	c.get(1, build(1))
+	if builds[1] != 2 || builds[2] != 1 {
+		t.Errorf("expected only EAT 1 rebuilt, got %v", builds)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
+	"github.com/GeoNet/nema-mar-portal/internal/diff"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/valid"
+)
+
+// apiDiffHandler returns the changes between two versions of an event as
+// JSON.
+func apiDiffHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{"event", "from", "to"}, []string{}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	_, d, err := versionDiff(q)
+	if err != nil {
+		return err
+	}
+
+	h.Set("Content-Type", "application/json")
+	return json.NewEncoder(b).Encode(d)
+}
+
+// dashboardDiffHandler serves a side by side comparison of two versions of
+// an event.
+func dashboardDiffHandler(r *http.Request, h http.Header, b *bytes.Buffer, nonce string) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{"event", "from", "to"}, []string{}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	to, d, err := versionDiff(q)
+	if err != nil {
+		return err
+	}
+
+	page := Page{
+		Nonce:      nonce,
+		User:       auth.UserFrom(r.Context()),
+		CurrentEAT: to,
+		Diff:       d,
+	}
+
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	return diffTemplate.ExecuteTemplate(b, "base", page)
+}
+
+// versionDiff returns the to version of the event in q and its changes from
+// the from version.
+func versionDiff(q url.Values) (*fastschema.EAT, *diff.Diff, error) {
+	eventID, _ := strconv.Atoi(q.Get("event"))
+	fromVersion, _ := strconv.Atoi(q.Get("from"))
+	toVersion, _ := strconv.Atoi(q.Get("to"))
+
+	from, err := fsClient.GetVersion(eventID, fromVersion)
+	if err != nil {
+		return nil, nil, weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	to, err := fsClient.GetVersion(eventID, toVersion)
+	if err != nil {
+		return nil, nil, weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	if from == nil || to == nil {
+		return nil, nil, weft.StatusError{Code: http.StatusNotFound, Err: fmt.Errorf("event %d has no published version %d or %d", eventID, fromVersion, toVersion)}
+	}
+
+	return to, diff.Compare(from, to), nil
+}
+
+// diffURL returns the page comparing eat with the event's previous version,
+// or "" for a first version.
+func diffURL(eat fastschema.EAT) string {
+	if eat.Version <= 1 {
+		return ""
+	}
+	return fmt.Sprintf("/dashboard/diff?event=%d&from=%d&to=%d", eat.EventID, eat.Version-1, eat.Version)
+}
+
+// changesSincePrevious summarises what eat changed from the event's previous
+// version, for the email and PDF. It's empty for a first version, or if the
+// previous version can't be read.
+func changesSincePrevious(eat *fastschema.EAT) []string {
+	if eat.Version <= 1 || eat.EventID == 0 {
+		return nil
+	}
+
+	previous, err := fsClient.GetVersion(eat.EventID, eat.Version-1)
+	if err != nil {
+		log.Printf("warning: failed to get version %d of event %d to compare: %v", eat.Version-1, eat.EventID, err)
+		return nil
+	}
+	if previous == nil {
+		return nil
+	}
+
+	return diff.Compare(previous, eat).Summary()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GeoNet/kit/weft/wefttest"
+	"github.com/GeoNet/nema-mar-portal/internal/diff"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	routes := wefttest.Requests{
+		{ID: wefttest.L(), URL: "/api/eat/diff?event=1&from=1&to=2", User: testReader, Password: testReaderPassword, Content: "application/json"},
+		{ID: wefttest.L(), URL: "/api/eat/diff?event=2&from=1&to=2", User: testReader, Password: testReaderPassword, Status: http.StatusNotFound},
+		{ID: wefttest.L(), URL: "/api/eat/diff?event=1&from=1", User: testReader, Password: testReaderPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/api/eat/diff?event=1&from=0&to=2", User: testReader, Password: testReaderPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/api/eat/diff?event=1&from=1&to=2", Status: http.StatusUnauthorized},
+		{ID: wefttest.L(), URL: "/dashboard/diff?event=1&from=1&to=2", User: testReader, Password: testReaderPassword, Content: "text/html; charset=utf-8"},
+		{ID: wefttest.L(), URL: "/dashboard/diff?event=2&from=1&to=2", User: testReader, Password: testReaderPassword, Status: http.StatusNotFound},
+	}
+
+	if err := routes.DoAll(ts.URL); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAPIDiff(t *testing.T) {
+	var d diff.Diff
+	if err := json.Unmarshal([]byte(get(t, "/api/eat/diff?event=1&from=1&to=2")), &d); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.EventID != 1 || d.From != 1 || d.To != 2 {
+		t.Errorf("unexpected versions %+v", d)
+	}
+	var changed []string
+	for _, f := range d.Changed() {
+		changed = append(changed, f.Name+": "+f.From+" → "+f.To)
+	}
+	if got := strings.Join(changed, ", "); got != "status: preliminary → confirmed, land_threat: No → Yes" {
+		t.Errorf("unexpected changes %s", got)
+	}
+	if len(d.Comments) != 1 || d.Comments[0].Op != diff.Insert {
+		t.Errorf("expected the comments to be added, got %+v", d.Comments)
+	}
+}
+
+func TestDashboardDiff(t *testing.T) {
+	body := get(t, "/dashboard/diff?event=1&from=1&to=2")
+	for _, want := range []string{
+		"Changes from Version 1 to Version 2",
+		`<tr class="changed"`,
+		"<td>preliminary</td>\n        <td>confirmed</td>",
+		`<ins style="background:#d4edda;">Stay away from the coast.</ins>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the page to contain %q", want)
+		}
+	}
+
+	if body := get(t, "/dashboard?event=1&version=2"); !strings.Contains(body, `<a href="/dashboard/diff?event=1&amp;from=1&amp;to=2">changes from the previous version</a>`) {
+		t.Error("expected the dashboard to link to the changes from the previous version")
+	}
+
+	if body := get(t, "/dashboard/diff?event=1&from=1&to=1"); !strings.Contains(body, "No changes between these versions.") {
+		t.Error("expected no changes between a version and itself")
+	}
+}
+
+func TestDiffURL(t *testing.T) {
+	if u := diffURL(mockPublished); u != "" {
+		t.Errorf("expected no diff for a first version, got %s", u)
+	}
+	if u := diffURL(mockRevised); u != "/dashboard/diff?event=1&from=1&to=2" {
+		t.Errorf("unexpected diff URL %s", u)
+	}
+}
+
+func TestChangesSincePrevious(t *testing.T) {
+	if c := changesSincePrevious(&mockPublished); c != nil {
+		t.Errorf("expected no changes for a first version, got %q", c)
+	}
+
+	got := strings.Join(changesSincePrevious(&mockRevised), "\n")
+	want := "Status: preliminary → confirmed\nLand Threat: No → Yes\nEvent comments revised\nAttachment added: map.png"
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/valid"
+)
+
+// maxDraftSize bounds an autosaved editor form. Attachments are stored as
+// references to uploaded files so drafts stay small.
+const maxDraftSize = 1 << 20
+
+// draftRequest is the JSON payload for creating or updating a draft.
+type draftRequest struct {
+	EventTitle string          `json:"event_title"`
+	Form       json.RawMessage `json:"form"`
+}
+
+// apiDraftsHandler lets editors autosave and resume their unsubmitted EAT
+// editor forms. Drafts are private to the user who saved them.
+//
+//	GET    /api/drafts        list the user's drafts
+//	GET    /api/drafts?id=N   get a draft
+//	POST   /api/drafts        create a draft
+//	PUT    /api/drafts?id=N   update a draft
+//	DELETE /api/drafts?id=N   discard a draft
+func apiDraftsHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET", "POST", "PUT", "DELETE"}, []string{}, []string{"id"}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	user := auth.UserFrom(r.Context())
+	if user == nil {
+		return weft.StatusError{Code: http.StatusUnauthorized, Err: errors.New("unknown user")}
+	}
+
+	var draft *fastschema.Draft
+	if idStr := q.Get("id"); idStr != "" {
+		id, _ := strconv.Atoi(idStr)
+		if draft, err = ownedDraft(id, user.Name); err != nil {
+			return err
+		}
+	} else if r.Method == "PUT" || r.Method == "DELETE" {
+		return weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("id required")}
+	}
+
+	h.Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "GET":
+		if draft != nil {
+			return json.NewEncoder(b).Encode(draft)
+		}
+		drafts, err := fsClient.ListDrafts(user.Name)
+		if err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+		if drafts == nil {
+			drafts = []fastschema.Draft{}
+		}
+		return json.NewEncoder(b).Encode(drafts)
+
+	case "DELETE":
+		if err := fsClient.DeleteDraft(draft.ID); err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+		return json.NewEncoder(b).Encode(draft)
+
+	default: // POST or PUT
+		if r.Method == "POST" && draft != nil {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("use PUT to update a draft")}
+		}
+
+		var req draftRequest
+		if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxDraftSize)).Decode(&req); err != nil {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid JSON: %w", err)}
+		}
+		if len(req.Form) == 0 || req.Form[0] != '{' {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("form must be a JSON object")}
+		}
+
+		if draft == nil {
+			draft = &fastschema.Draft{Owner: user.Name}
+		}
+		draft.EventTitle = req.EventTitle
+		draft.Form = req.Form
+
+		saved, err := fsClient.SaveDraft(draft)
+		if err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+		return json.NewEncoder(b).Encode(saved)
+	}
+}
+
+// ownedDraft returns the draft with the given id if it belongs to owner.
+// Other users' drafts are reported as not found.
+func ownedDraft(id int, owner string) (*fastschema.Draft, error) {
+	draft, err := fsClient.GetDraft(id)
+	if err != nil {
+		return nil, weft.StatusError{Code: http.StatusNotFound, Err: errors.New("draft not found")}
+	}
+	if draft.Owner != owner {
+		return nil, weft.StatusError{Code: http.StatusNotFound, Err: errors.New("draft not found")}
+	}
+	return draft, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/GeoNet/kit/weft/wefttest"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestDraftRoutes(t *testing.T) {
+	routes := wefttest.Requests{
+		{ID: wefttest.L(), URL: "/api/drafts", User: testEditor, Password: testEditorPassword, Content: "application/json"},
+		{ID: wefttest.L(), URL: "/api/drafts?id=5", User: testEditor, Password: testEditorPassword, Content: "application/json"},
+		{ID: wefttest.L(), URL: "/api/drafts?id=5", Method: "DELETE", User: testEditor, Password: testEditorPassword, Content: "application/json"},
+		// Drafts are private to their owner
+		{ID: wefttest.L(), URL: "/api/drafts?id=6", User: testEditor, Password: testEditorPassword, Status: http.StatusNotFound},
+		{ID: wefttest.L(), URL: "/api/drafts?id=6", Method: "DELETE", User: testEditor, Password: testEditorPassword, Status: http.StatusNotFound},
+		{ID: wefttest.L(), URL: "/api/drafts?id=404", User: testEditor, Password: testEditorPassword, Status: http.StatusNotFound},
+		{ID: wefttest.L(), URL: "/api/drafts", Method: "DELETE", User: testEditor, Password: testEditorPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/api/drafts?id=abc", User: testEditor, Password: testEditorPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/api/drafts", User: testReader, Password: testReaderPassword, Status: http.StatusForbidden},
+	}
+	if err := routes.DoAll(ts.URL); err != nil {
+		t.Error(err)
+	}
+}
+
+// saveDraft sends a draft to /api/drafts as the test editor.
+func saveDraft(t *testing.T, method, url, body string) (int, fastschema.Draft) {
+	t.Helper()
+
+	r, err := http.NewRequest(method, ts.URL+url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBasicAuth(testEditor, testEditorPassword)
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var d fastschema.Draft
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return resp.StatusCode, d
+}
+
+func TestSaveDraft(t *testing.T) {
+	code, d := saveDraft(t, "POST", "/api/drafts", `{"event_title":"M4.2-Napier-2026-02-01","form":{"mode":"new_event","location":"Napier"}}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 creating draft, got %d", code)
+	}
+	if d.ID != 98 || d.Owner != testEditor {
+		t.Errorf("unexpected draft: %+v", d)
+	}
+
+	code, d = saveDraft(t, "PUT", "/api/drafts?id=5", `{"event_title":"M5.0-Wellington-2026-01-01","form":{"mode":"new_version","event_comments":"Updated"}}`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 updating draft, got %d", code)
+	}
+	if !bytes.Contains(d.Form, []byte("Updated")) {
+		t.Errorf("form not updated: %s", d.Form)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		code   int
+	}{
+		{"not an object", "POST", "/api/drafts", `{"form":"text"}`, http.StatusBadRequest},
+		{"missing form", "POST", "/api/drafts", `{}`, http.StatusBadRequest},
+		{"post with id", "POST", "/api/drafts?id=5", `{"form":{}}`, http.StatusBadRequest},
+		{"put without id", "PUT", "/api/drafts", `{"form":{}}`, http.StatusBadRequest},
+		{"other owner", "PUT", "/api/drafts?id=6", `{"form":{}}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := saveDraft(t, tt.method, tt.url, tt.body); code != tt.code {
+				t.Errorf("expected %d, got %d", tt.code, code)
+			}
+		})
+	}
+}
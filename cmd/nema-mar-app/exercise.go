@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// exerciseMode marks every EAT submitted to this server as an exercise, for
+// drills on a training instance. It's set by EXERCISE_MODE.
+var exerciseMode bool
+
+// showExercises reports whether exercise EATs are shown on the dashboard and
+// in the feeds. They are left out unless asked for with exercise=true, so a
+// drill can't be taken for a real advisory, except in exercise mode where
+// every EAT is an exercise.
+func showExercises(q url.Values) bool {
+	show, _ := strconv.ParseBool(q.Get("exercise"))
+	return exerciseMode || show
+}
+
+// withoutExercises returns the EATs that aren't exercises.
+func withoutExercises(eats []fastschema.EAT) []fastschema.EAT {
+	var real []fastschema.EAT
+	for _, e := range eats {
+		if !e.Exercise {
+			real = append(real, e)
+		}
+	}
+	return real
+}
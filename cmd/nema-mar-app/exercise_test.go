@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// get returns the body of a page read by the test reader.
+func get(t *testing.T, path string) string {
+	t.Helper()
+
+	r, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBasicAuth(testReader, testReaderPassword)
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s: expected 200, got %d", path, resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestDashboardExercises(t *testing.T) {
+	body := get(t, "/dashboard")
+	if !strings.Contains(body, "<h2>M5.0-Wellington-2026-01-01</h2>") || strings.Contains(body, "Hikurangi") {
+		t.Error("expected the latest real event, not the exercise")
+	}
+	if strings.Contains(body, "This advisory is a drill") {
+		t.Error("expected no exercise banner")
+	}
+
+	body = get(t, "/dashboard?exercise=true")
+	if !strings.Contains(body, "<h2>M7.8-Hikurangi-2026-01-02</h2>") || !strings.Contains(body, "EXERCISE EXERCISE EXERCISE") {
+		t.Error("expected the exercise with its banner when exercises are shown")
+	}
+
+	// A version of an exercise linked to directly is shown, marked.
+	if body := get(t, "/dashboard?event=3&version=1"); !strings.Contains(body, "This advisory is a drill") {
+		t.Error("expected the exercise banner on a linked exercise")
+	}
+}
+
+func TestFeedExercises(t *testing.T) {
+	for _, path := range []string{"/feed/atom", "/feed/rss"} {
+		if body := get(t, path); !strings.Contains(body, "Wellington") || strings.Contains(body, "Hikurangi") {
+			t.Errorf("%s: expected the exercise to be left out", path)
+		}
+		if body := get(t, path+"?exercise=true"); !strings.Contains(body, "Hikurangi") {
+			t.Errorf("%s: expected the exercise when asked for", path)
+		}
+	}
+}
+
+func TestExerciseMode(t *testing.T) {
+	exerciseMode = true
+	defer func() { exerciseMode = false }()
+
+	body := get(t, "/dashboard")
+	if !strings.Contains(body, "EXERCISE MODE") {
+		t.Error("expected the exercise mode banner")
+	}
+	if !strings.Contains(body, "<h2>M7.8-Hikurangi-2026-01-02</h2>") {
+		t.Error("expected exercises on the dashboard in exercise mode")
+	}
+}
+
+func TestPublishExercise(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     bool
+		body     string
+		exercise bool
+	}{
+		{"new exercise", false, `{"mode":"new_event","location":"Hikurangi","event_date":"2026-01-02T00:00","magnitude":7.8,"status":"confirmed","exercise":true}`, true},
+		{"new event", false, `{"mode":"new_event","location":"Hikurangi","event_date":"2026-01-02T00:00","magnitude":7.8,"status":"confirmed"}`, false},
+		{"exercise mode", true, `{"mode":"new_event","location":"Hikurangi","event_date":"2026-01-02T00:00","magnitude":7.8,"status":"confirmed"}`, true},
+		{"version of an exercise", false, `{"mode":"new_version","existing_eat_id":3,"base_version":1,"location":"Hikurangi","event_date":"2026-01-02T00:00","magnitude":7.8,"status":"confirmed"}`, true},
+		{"version of a real event", false, `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"confirmed","exercise":true}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exerciseMode = tt.mode
+			defer func() { exerciseMode = false }()
+			for len(createdEATs) > 0 {
+				<-createdEATs
+			}
+
+			resp := submit(t, tt.body)
+			resp.Body.Close()
+
+			select {
+			case eat := <-createdEATs:
+				if eat.Exercise != tt.exercise {
+					t.Errorf("expected exercise %t, got %t", tt.exercise, eat.Exercise)
+				}
+			default:
+				t.Fatal("expected an EAT to be created")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/feed"
+	"github.com/GeoNet/nema-mar-portal/internal/valid"
+)
+
+const feedDays = 30 // default window of EATs included in the feeds
+
+// feedAtomHandler serves an Atom feed of published EATs.
+func feedAtomHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	return serveFeed(r, h, b, feed.Atom, feed.AtomContentType)
+}
+
+// feedRSSHandler serves an RSS 2.0 feed of published EATs.
+func feedRSSHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	return serveFeed(r, h, b, feed.RSS, feed.RSSContentType)
+}
+
+func serveFeed(r *http.Request, h http.Header, b *bytes.Buffer,
+	build func([]fastschema.EAT, feed.Options) ([]byte, error), contentType string) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{}, []string{"days", "exercise"}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	days := feedDays
+	if d := q.Get("days"); d != "" {
+		days, _ = strconv.Atoi(d)
+	}
+
+	eats, err := fsClient.ListEATs(time.Now().UTC().AddDate(0, 0, -days))
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	if !showExercises(q) {
+		eats = withoutExercises(eats)
+	}
+
+	base := baseURL(r)
+	out, err := build(eats, feed.Options{BaseURL: base, SelfURL: base + r.URL.RequestURI()})
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+
+	// The body may be gzipped by weft so the ETag is weak. There's no
+	// Last-Modified: EATs leave the feed as they age out of the window or
+	// are filtered out, which no entry time reflects, so only the ETag
+	// tells when the feed changed.
+	etag := fmt.Sprintf(`W/"%x"`, sha256.Sum256(out))
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "max-age=60")
+
+	if notModified(r, etag) {
+		return weft.StatusError{Code: http.StatusNotModified}
+	}
+
+	h.Set("Content-Type", contentType)
+	_, err = b.Write(out)
+	return err
+}
+
+// notModified evaluates If-None-Match. If-Modified-Since is ignored, as the
+// feeds have no Last-Modified to compare it with.
+func notModified(r *http.Request, etag string) bool {
+	for _, t := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || (t != "" && strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
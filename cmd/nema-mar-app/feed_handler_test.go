@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestFeedConditionalGet(t *testing.T) {
+	for _, path := range []string{"/feed/atom", "/feed/rss"} {
+		t.Run(path, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+			req.SetBasicAuth(testReader, testReaderPassword)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+			etag := resp.Header.Get("ETag")
+			if etag == "" {
+				t.Fatal("expected ETag header")
+			}
+
+			req, _ = http.NewRequest(http.MethodGet, ts.URL+path, nil)
+			req.SetBasicAuth(testReader, testReaderPassword)
+			req.Header.Set("If-None-Match", etag)
+			resp, err = http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusNotModified {
+				t.Errorf("expected 304 for matching If-None-Match, got %d", resp.StatusCode)
+			}
+
+			req, _ = http.NewRequest(http.MethodGet, ts.URL+path, nil)
+			req.SetBasicAuth(testReader, testReaderPassword)
+			req.Header.Set("If-None-Match", `W/"stale"`)
+			resp, err = http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("expected 200 for stale If-None-Match, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   bool
+	}{
+		{"no conditions", nil, false},
+		{"etag match", map[string]string{"If-None-Match": `W/"abc"`}, true},
+		{"strong etag match", map[string]string{"If-None-Match": `"abc"`}, true},
+		{"etag list", map[string]string{"If-None-Match": `W/"x", W/"abc"`}, true},
+		{"etag mismatch", map[string]string{"If-None-Match": `W/"x"`}, false},
+		{"any etag", map[string]string{"If-None-Match": "*"}, true},
+		{"modified since ignored", map[string]string{"If-Modified-Since": time.Now().UTC().Format(http.TimeFormat)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/feed/atom", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if got := notModified(r, `W/"abc"`); got != tt.want {
+				t.Errorf("notModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// An EAT ageing out of the window changes the feed without any entry being
+// newer, so a client revalidating must still get the new feed.
+func TestFeedWindow(t *testing.T) {
+	now := time.Now().UTC()
+	published := now.Add(-time.Hour)
+	store := useEATStore(t,
+		fastschema.EAT{ID: 1, EventID: 1, EventTitle: "M5.0-Wellington", EventDate: now.AddDate(0, 0, -2),
+			Version: 1, State: fastschema.StatePublished, ReviewedAt: &published},
+		fastschema.EAT{ID: 2, EventID: 2, EventTitle: "M4.5-Seddon", EventDate: now.AddDate(0, 0, -29),
+			Version: 1, State: fastschema.StatePublished, CreatedAt: now.AddDate(0, 0, -29)},
+	)
+
+	fetch := func(header map[string]string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/feed/atom", nil)
+		req.SetBasicAuth(testReader, testReaderPassword)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp, string(b)
+	}
+
+	resp, body := fetch(nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "M4.5-Seddon") {
+		t.Fatalf("expected both EATs in the feed, got %d:\n%s", resp.StatusCode, body)
+	}
+	etag, date := resp.Header.Get("ETag"), resp.Header.Get("Date")
+
+	// Event 2 ages out of the 30 day window.
+	store.mu.Lock()
+	e := store.eats[2]
+	e.EventDate = now.AddDate(0, 0, -31)
+	store.eats[2] = e
+	store.mu.Unlock()
+
+	resp, body = fetch(map[string]string{"If-None-Match": etag, "If-Modified-Since": date})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 once the feed changed, got %d", resp.StatusCode)
+	}
+	if strings.Contains(body, "M4.5-Seddon") || !strings.Contains(body, "M5.0-Wellington") {
+		t.Errorf("expected only event 1 in the feed:\n%s", body)
+	}
+
+	resp, _ = fetch(map[string]string{"If-Modified-Since": date})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected If-Modified-Since alone to be ignored, got %d", resp.StatusCode)
+	}
+}
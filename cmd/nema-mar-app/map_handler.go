@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/format"
+	"github.com/GeoNet/nema-mar-portal/internal/maprender"
+	"github.com/GeoNet/nema-mar-portal/internal/valid"
+)
+
+// mapHandler serves an epicentre map for the dashboard and preview, as SVG or
+// PNG by the extension of the request path. The map is drawn from the query
+// so unsaved previews can show one too.
+func mapHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{"lat", "lon"}, []string{"mag"}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	lat, _ := strconv.ParseFloat(q.Get("lat"), 64)
+	lon, _ := strconv.ParseFloat(q.Get("lon"), 64)
+	epicentre := maprender.Point{Lat: lat, Lon: lon}
+
+	var opts maprender.Options
+	if mag := q.Get("mag"); mag != "" {
+		m, _ := strconv.ParseFloat(mag, 32)
+		opts.Label = "M" + format.Magnitude(float32(m))
+	}
+
+	var img []byte
+	if path.Ext(r.URL.Path) == ".png" {
+		img, err = maprender.PNG(epicentre, opts)
+		h.Set("Content-Type", "image/png")
+	} else {
+		img, err = maprender.SVG(epicentre, opts)
+		h.Set("Content-Type", "image/svg+xml")
+	}
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+
+	b.Write(img)
+	return nil
+}
+
+// mapURL returns the path of the SVG epicentre map of eat, which must have
+// an epicentre.
+func mapURL(eat *fastschema.EAT) string {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(*eat.Latitude, 'f', -1, 64))
+	q.Set("lon", strconv.FormatFloat(*eat.Longitude, 'f', -1, 64))
+	q.Set("mag", format.Magnitude(eat.Magnitude))
+	return "/dashboard/map.svg?" + q.Encode()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GeoNet/kit/weft/wefttest"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestMapRoutes(t *testing.T) {
+	routes := wefttest.Requests{
+		{ID: wefttest.L(), URL: "/dashboard/map.svg?lat=-41.29&lon=174.78&mag=5.0", User: testReader, Password: testReaderPassword, Content: "image/svg+xml"},
+		{ID: wefttest.L(), URL: "/dashboard/map.png?lat=-29.5&lon=-177.9", User: testReader, Password: testReaderPassword, Content: "image/png"},
+		{ID: wefttest.L(), URL: "/dashboard/map.svg?lat=-41.29", User: testReader, Password: testReaderPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/dashboard/map.svg?lat=south&lon=174.78", User: testReader, Password: testReaderPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/dashboard/map.svg?lat=-141.29&lon=174.78", User: testReader, Password: testReaderPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/dashboard/map.svg?lat=-41.29&lon=174.78", Status: http.StatusUnauthorized},
+	}
+	if err := routes.DoAll(ts.URL); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapURL(t *testing.T) {
+	lat, lon := -41.29, 174.78
+	eat := &fastschema.EAT{Magnitude: 5, Latitude: &lat, Longitude: &lon}
+
+	if got, want := mapURL(eat), "/dashboard/map.svg?lat=-41.29&lon=174.78&mag=5.0"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestDashboardNoEpicentre(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, ts.URL+"/dashboard", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBasicAuth(testReader, testReaderPassword)
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(body), "Epicentre not known.") {
+		t.Error("expected the dashboard to say the epicentre isn't known")
+	}
+	if strings.Contains(string(body), "/dashboard/map.svg") {
+		t.Error("expected no map without an epicentre")
+	}
+}
+
+func TestPublishEpicentre(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"valid", `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"confirmed","latitude":-41.29,"longitude":174.78,"depth":33}`, ""},
+		{"latitude only", `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"confirmed","latitude":-41.29}`, "latitude and longitude must be given together"},
+		{"bad longitude", `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"confirmed","latitude":-41.29,"longitude":194.78}`, "longitude must be -180 to 180"},
+		{"bad depth", `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"confirmed","latitude":-41.29,"longitude":174.78,"depth":-5}`, "depth must be 0 to 1000 km"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := submit(t, tt.body)
+			defer resp.Body.Close()
+
+			var pr publishResponse
+			if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+				t.Fatal(err)
+			}
+			if pr.Error != tt.err {
+				t.Errorf("expected error %q, got %q", tt.err, pr.Error)
+			}
+			if pr.Success != (tt.err == "") {
+				t.Errorf("expected success %t, got %+v", tt.err == "", pr)
+			}
+		})
+	}
+}
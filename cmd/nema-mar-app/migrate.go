@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// legacyPublicIDPrefix starts the public IDs of events made for EATs saved
+// before events were introduced.
+const legacyPublicIDPrefix = "legacy-"
+
+// migrateLegacyEATs gives each EAT saved before events were introduced the
+// event of its title, as the title is what identified an event then. The
+// event's public ID is derived from the title, so the migration can be rerun
+// or run by several instances at once. It returns how many EATs were given
+// an event.
+func migrateLegacyEATs() (int, error) {
+	events := make(map[string]*fastschema.Event)
+	migrated := make(map[int]bool)
+
+	// Each batch is the next EATs still without an event.
+	for {
+		eats, err := fsClient.ListUnassignedEATs()
+		if err != nil {
+			return len(migrated), err
+		}
+
+		progress := false
+		for _, eat := range eats {
+			if migrated[eat.ID] {
+				continue
+			}
+
+			event, ok := events[eat.EventTitle]
+			if !ok {
+				event, err = findOrCreateEvent(legacyPublicIDPrefix + eat.EventTitle)
+				if err != nil {
+					return len(migrated), fmt.Errorf("event for %s: %w", eat.EventTitle, err)
+				}
+				events[eat.EventTitle] = event
+			}
+
+			if err := fsClient.AssignEvent(eat.ID, event.ID); err != nil {
+				return len(migrated), fmt.Errorf("assign EAT %d to event %d: %w", eat.ID, event.ID, err)
+			}
+			migrated[eat.ID] = true
+			progress = true
+		}
+
+		if !progress {
+			return len(migrated), nil
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// legacyStore is a fake of FastSchema holding EATs saved before events were
+// introduced.
+type legacyStore struct {
+	mu     sync.Mutex
+	eats   map[int]fastschema.EAT
+	events []fastschema.Event
+}
+
+func (s *legacyStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/api/content/eat" && r.Method == http.MethodGet:
+		var resp fastschema.ListResponse
+		if strings.Contains(r.URL.Query().Get("filter"), `"event_id":{"$null":true}`) {
+			for id := 1; id <= len(s.eats); id++ {
+				if e := s.eats[id]; e.EventID == 0 && len(resp.Data.Items) < 2 {
+					resp.Data.Items = append(resp.Data.Items, e)
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+
+	case strings.HasPrefix(r.URL.Path, "/api/content/eat/") && r.Method == http.MethodPut:
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/content/eat/"))
+		var update struct {
+			Event struct {
+				ID int `json:"id"`
+			} `json:"event"`
+		}
+		json.NewDecoder(r.Body).Decode(&update)
+		e := s.eats[id]
+		e.EventID = update.Event.ID
+		s.eats[id] = e
+		json.NewEncoder(w).Encode(fastschema.SingleResponse{Data: e})
+
+	case r.URL.Path == "/api/content/event" && r.Method == http.MethodGet:
+		var resp fastschema.EventListResponse
+		for _, e := range s.events {
+			if strings.Contains(r.URL.Query().Get("filter"), strconv.Quote(e.PublicID)) {
+				resp.Data.Items = append(resp.Data.Items, e)
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+
+	case r.URL.Path == "/api/content/event" && r.Method == http.MethodPost:
+		var e fastschema.Event
+		json.NewDecoder(r.Body).Decode(&e)
+		e.ID = 100 + len(s.events)
+		s.events = append(s.events, e)
+		json.NewEncoder(w).Encode(fastschema.EventResponse{Data: e})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestMigrateLegacyEATs(t *testing.T) {
+	store := &legacyStore{
+		eats: map[int]fastschema.EAT{
+			1: {ID: 1, EventTitle: "M5.0-Wellington-2026-01-01", Version: 1},
+			2: {ID: 2, EventTitle: "M6.2-Kaikoura-2026-02-01", Version: 1},
+			3: {ID: 3, EventTitle: "M5.0-Wellington-2026-01-01", Version: 2},
+			4: {ID: 4, EventID: 7, EventTitle: "M5.0-Wellington-2026-01-01", Version: 1},
+		},
+		// Kaikoura's event was made by an earlier, interrupted run.
+		events: []fastschema.Event{{ID: 50, PublicID: "legacy-M6.2-Kaikoura-2026-02-01"}},
+	}
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	old := fsClient
+	fsClient = fastschema.NewClient(server.URL)
+	defer func() { fsClient = old }()
+
+	n, err := migrateLegacyEATs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 EATs migrated, got %d", n)
+	}
+
+	// Versions with the same title share an event, and EATs that already
+	// have one keep it.
+	wellington := store.eats[1].EventID
+	for id, want := range map[int]int{1: wellington, 2: 50, 3: wellington, 4: 7} {
+		if got := store.eats[id].EventID; got != want || got == 0 {
+			t.Errorf("EAT %d: expected event %d, got %d", id, want, got)
+		}
+	}
+	if len(store.events) != 2 || store.events[1].PublicID != "legacy-M5.0-Wellington-2026-01-01" {
+		t.Errorf("unexpected events %+v", store.events)
+	}
+
+	if n, err := migrateLegacyEATs(); err != nil || n != 0 {
+		t.Errorf("expected nothing left to migrate, got %d, %v", n, err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
 	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
 )
 
@@ -17,7 +18,7 @@ func portalPageHandler(r *http.Request, h http.Header, b *bytes.Buffer, nonce st
 		return err
 	}
 
-	page := Page{Nonce: nonce}
+	page := Page{Nonce: nonce, User: auth.UserFrom(r.Context())}
 
 	// Load recent events for dropdown
 	events, err := fsClient.ListDistinctEvents(7)
@@ -27,6 +28,14 @@ func portalPageHandler(r *http.Request, h http.Header, b *bytes.Buffer, nonce st
 	}
 	page.Events = events
 
+	// Offer to resume any autosaved drafts
+	if page.User != nil {
+		drafts, err := fsClient.ListDrafts(page.User.Name)
+		if err == nil {
+			page.Drafts = drafts
+		}
+	}
+
 	h.Set("Content-Type", "text/html; charset=utf-8")
 	return editorTemplate.ExecuteTemplate(b, "base", page)
 }
@@ -54,6 +63,12 @@ func portalPreviewHandler(r *http.Request, h http.Header, b *bytes.Buffer, nonce
 		LandThreat:        r.FormValue("land_threat") == "on",
 		Status:            r.FormValue("status"),
 		TEPActivated:      r.FormValue("tep_activated") == "on",
+		Exercise:          r.FormValue("exercise") == "on" || exerciseMode,
+	}
+
+	lat, lon, depth := formFloat(r, "latitude"), formFloat(r, "longitude"), formFloat(r, "depth")
+	if checkEpicentre(lat, lon, depth) == "" {
+		eat.Latitude, eat.Longitude, eat.Depth = lat, lon, depth
 	}
 
 	eat.EventTitle = fastschema.FormatEventTitle(eat.Magnitude, eat.Location, eat.EventDate)
@@ -68,9 +83,37 @@ func portalPreviewHandler(r *http.Request, h http.Header, b *bytes.Buffer, nonce
 
 	page := Page{
 		Nonce:      nonce,
+		User:       auth.UserFrom(r.Context()),
 		CurrentEAT: eat,
 	}
 
+	if outboxWorker != nil && emailConfig.Routing != nil {
+		page.EmailEnabled = true
+		// A cancellation goes to the recipients of the version it
+		// withdraws, as recipientsFor sends it.
+		if eat.IsCancelled() {
+			if id, err := strconv.Atoi(r.FormValue("existing_eat_id")); err == nil && id > 0 {
+				if existing, err := fsClient.GetEAT(id); err == nil {
+					eat.EventID = existing.EventID
+					page.EmailPrevious = previousRecipients(eat)
+				}
+			}
+		}
+		if routing := emailConfig.RoutingFor(eat); routing != nil && page.EmailPrevious == nil {
+			page.EmailGroups = routing.Route(eat)
+		}
+	}
+
 	h.Set("Content-Type", "text/html; charset=utf-8")
 	return previewTemplate.ExecuteTemplate(b, "base", page)
 }
+
+// formFloat returns the number in an optional form field, nil if it's empty
+// or not a number.
+func formFloat(r *http.Request, name string) *float64 {
+	f, err := strconv.ParseFloat(r.FormValue(name), 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// eatStore is a stateful fake of the FastSchema EAT content API. Like the
+// real schema it rejects a version_key already used by another record.
+type eatStore struct {
+	mu   sync.Mutex
+	eats map[int]fastschema.EAT
+}
+
+func (s *eatStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/api/content/eat" && r.Method == http.MethodGet:
+		var filter map[string]map[string]interface{}
+		json.Unmarshal([]byte(r.URL.Query().Get("filter")), &filter)
+
+		var items []fastschema.EAT
+		if since, ok := filter["event_date"]["$gte"].(string); ok {
+			// The feeds' listing of EATs in a window.
+			from, _ := time.Parse(time.RFC3339, since)
+			for _, e := range s.eats {
+				if !e.EventDate.Before(from) && e.State == filter["state"]["$eq"] {
+					items = append(items, e)
+				}
+			}
+			sort.Slice(items, func(i, j int) bool { return items[i].EventDate.After(items[j].EventDate) })
+			json.NewEncoder(w).Encode(fastschema.ListResponse{Data: fastschema.ListData{Items: items}})
+			return
+		}
+		for _, e := range s.eats {
+			if float64(e.EventID) == filter["event_id"]["$eq"] && e.State == filter["state"]["$eq"] {
+				items = append(items, e)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Version > items[j].Version })
+		if len(items) > 1 {
+			items = items[:1]
+		}
+		json.NewEncoder(w).Encode(fastschema.ListResponse{Data: fastschema.ListData{Items: items}})
+
+	case strings.HasPrefix(r.URL.Path, "/api/content/eat/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/content/eat/"))
+		e, ok := s.eats[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			var update fastschema.EAT
+			json.NewDecoder(r.Body).Decode(&update)
+			for other, o := range s.eats {
+				if other != id && update.VersionKey != "" && o.VersionKey == update.VersionKey {
+					w.WriteHeader(http.StatusInternalServerError)
+					w.Write([]byte(`{"error":"duplicate key value violates unique constraint \"eats_version_key_key\""}`))
+					return
+				}
+			}
+			e.State, e.Version, e.VersionKey = update.State, update.Version, update.VersionKey
+			s.eats[id] = e
+		}
+
+		json.NewEncoder(w).Encode(fastschema.SingleResponse{Data: e})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// useEATStore points the app at a fake FastSchema holding eats for the
+// duration of the test.
+func useEATStore(t *testing.T, eats ...fastschema.EAT) *eatStore {
+	t.Helper()
+
+	store := &eatStore{eats: make(map[int]fastschema.EAT)}
+	for _, e := range eats {
+		store.eats[e.ID] = e
+	}
+
+	server := httptest.NewServer(store)
+	old := fsClient
+	fsClient = fastschema.NewClient(server.URL)
+	t.Cleanup(func() {
+		fsClient = old
+		server.Close()
+	})
+
+	return store
+}
+
+const (
+	raceEvent = 3
+	raceTitle = "M6.1-Seddon-2026-04-01"
+)
+
+func TestApproveConcurrent(t *testing.T) {
+	const n = 8
+
+	eats := []fastschema.EAT{
+		{ID: 1, EventID: raceEvent, EventTitle: raceTitle, Version: 1, State: fastschema.StatePublished, VersionKey: fastschema.VersionKey(raceEvent, 1)},
+	}
+	for i := 0; i < n; i++ {
+		eats = append(eats, fastschema.EAT{ID: 100 + i, EventID: raceEvent, EventTitle: raceTitle, BaseVersion: 1,
+			State: fastschema.StatePendingApproval, SubmittedBy: testEditor})
+	}
+	store := useEATStore(t, eats...)
+
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i], _ = approve(t, approveRequest{ID: 100 + i, Decision: "approve"})
+		}(i)
+	}
+	wg.Wait()
+
+	var ok, conflict int
+	for _, c := range codes {
+		switch c {
+		case http.StatusOK:
+			ok++
+		case http.StatusConflict:
+			conflict++
+		default:
+			t.Errorf("unexpected status %d", c)
+		}
+	}
+	if ok != 1 || conflict != n-1 {
+		t.Errorf("expected 1 approval and %d conflicts, got %d and %d", n-1, ok, conflict)
+	}
+
+	seen := make(map[int]int)
+	for _, e := range store.eats {
+		if e.State == fastschema.StatePublished {
+			seen[e.Version]++
+		}
+	}
+	if seen[1] != 1 || seen[2] != 1 || len(seen) != 2 {
+		t.Errorf("expected one each of versions 1 and 2 published, got %v", seen)
+	}
+}
+
+// TestApproveUniqueVersionKey covers another instance having claimed the next
+// version between this instance's base version check and its update.
+func TestApproveUniqueVersionKey(t *testing.T) {
+	useEATStore(t,
+		fastschema.EAT{ID: 1, EventID: raceEvent, EventTitle: raceTitle, Version: 1, State: fastschema.StatePublished, VersionKey: fastschema.VersionKey(raceEvent, 1)},
+		// Mid-publish elsewhere: the key is taken but the state not yet visible.
+		fastschema.EAT{ID: 2, EventID: raceEvent, EventTitle: raceTitle, BaseVersion: 1, State: fastschema.StatePendingApproval, VersionKey: fastschema.VersionKey(raceEvent, 2)},
+		fastschema.EAT{ID: 3, EventID: raceEvent, EventTitle: raceTitle, BaseVersion: 1, State: fastschema.StatePendingApproval, SubmittedBy: testEditor},
+	)
+
+	if code, _ := approve(t, approveRequest{ID: 3, Decision: "approve"}); code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", code)
+	}
+}
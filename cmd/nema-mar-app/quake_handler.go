@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/format"
+	"github.com/GeoNet/nema-mar-portal/internal/quakefeed"
+	"github.com/GeoNet/nema-mar-portal/internal/valid"
+)
+
+// quakeClient reads recent quakes from the GeoNet quake API, or the one at
+// QUAKE_FEED_URL.
+var quakeClient = quakefeed.NewClient(quakefeed.DefaultURL)
+
+// defaultMMI is the least intensity of the quakes offered to prefill a new
+// event: weak shaking and above.
+const defaultMMI = 3
+
+// quakeChoice is a recent quake offered in the editor, with the EAT fields it
+// fills in.
+type quakeChoice struct {
+	PublicID      string  `json:"public_id"`
+	Label         string  `json:"label"`
+	Location      string  `json:"location"`
+	EventDate     string  `json:"event_date"` // datetime-local format, as the editor sends it
+	Magnitude     float32 `json:"magnitude"`
+	Latitude      float64 `json:"latitude"`
+	Longitude     float64 `json:"longitude"`
+	Depth         float64 `json:"depth"`
+	EarthquakeURL string  `json:"earthquake_url"`
+}
+
+// apiQuakesHandler returns recent quakes from the quake feed as JSON, for the
+// editor to prefill a new event.
+func apiQuakesHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{}, []string{"mmi"}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	mmi := defaultMMI
+	if s := q.Get("mmi"); s != "" {
+		mmi, _ = strconv.Atoi(s)
+	}
+
+	quakes, err := quakeClient.Recent(mmi)
+	if err != nil {
+		return weft.StatusError{Code: http.StatusBadGateway, Err: err}
+	}
+
+	choices := make([]quakeChoice, 0, len(quakes))
+	for _, qk := range quakes {
+		// The editor and event title use magnitudes to one decimal place.
+		mag := float32(math.Round(qk.Magnitude*10) / 10)
+		choices = append(choices, quakeChoice{
+			PublicID:      qk.PublicID,
+			Label:         "M" + format.Magnitude(mag) + " " + qk.Locality + ", " + format.Date(qk.Time),
+			Location:      qk.Place(),
+			EventDate:     format.DateInput(qk.Time),
+			Magnitude:     mag,
+			Latitude:      qk.Latitude,
+			Longitude:     qk.Longitude,
+			Depth:         qk.Depth,
+			EarthquakeURL: qk.URL(),
+		})
+	}
+
+	h.Set("Content-Type", "application/json")
+	return json.NewEncoder(b).Encode(choices)
+}
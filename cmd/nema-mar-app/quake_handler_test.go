@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/GeoNet/kit/weft/wefttest"
+)
+
+func TestQuakes(t *testing.T) {
+	r := wefttest.Request{ID: wefttest.L(), URL: "/api/quakes", User: testEditor, Password: testEditorPassword, Content: "application/json"}
+	b, err := r.Do(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var choices []quakeChoice
+	if err := json.Unmarshal(b, &choices); err != nil {
+		t.Fatal(err)
+	}
+	if len(choices) != 1 {
+		t.Fatalf("expected 1 quake, got %d", len(choices))
+	}
+
+	want := quakeChoice{
+		PublicID:      "2026p038412",
+		Label:         "M5.4 10 km north-west of Wellington, 2026-01-15 10:30 UTC",
+		Location:      "Wellington",
+		EventDate:     "2026-01-15T10:30",
+		Magnitude:     5.4,
+		Latitude:      -41.2865,
+		Longitude:     174.7787,
+		Depth:         22.4,
+		EarthquakeURL: "https://www.geonet.org.nz/earthquake/2026p038412",
+	}
+	if choices[0] != want {
+		t.Errorf("expected %+v, got %+v", want, choices[0])
+	}
+}
+
+func TestQuakesBadMMI(t *testing.T) {
+	routes := wefttest.Requests{
+		{ID: wefttest.L(), URL: "/api/quakes?mmi=9", User: testEditor, Password: testEditorPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/api/quakes?mmi=strong", User: testEditor, Password: testEditorPassword, Status: http.StatusBadRequest},
+	}
+	if err := routes.DoAll(ts.URL); err != nil {
+		t.Error(err)
+	}
+}
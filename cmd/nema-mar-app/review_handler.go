@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/valid"
+)
+
+// reviewPageHandler lists EATs awaiting approval, or shows one for review when
+// an id is given.
+func reviewPageHandler(r *http.Request, h http.Header, b *bytes.Buffer, nonce string) error {
+	q, err := weft.CheckQueryValid(r, []string{"GET"}, []string{}, []string{"id"}, valid.Query)
+	if err != nil {
+		return err
+	}
+
+	page := Page{Nonce: nonce, User: auth.UserFrom(r.Context())}
+
+	if idStr := q.Get("id"); idStr != "" {
+		id, _ := strconv.Atoi(idStr)
+		eat, err := fsClient.GetEAT(id)
+		if err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+		if eat == nil || eat.State != fastschema.StatePendingApproval {
+			return weft.StatusError{Code: http.StatusNotFound, Err: errors.New("no pending EAT with that id")}
+		}
+		page.CurrentEAT = eat
+
+		latest, err := fsClient.GetLatestVersion(eat.EventID)
+		if err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+		if latest != nil {
+			page.LatestVersion = latest.Version
+		}
+	} else {
+		pending, err := fsClient.ListPending()
+		if err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+		page.Pending = pending
+	}
+
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	return reviewTemplate.ExecuteTemplate(b, "base", page)
+}
+
+// publishMu serialises version allocation within this process. It saves
+// concurrent approvals here from racing to a conflict, but with more than one
+// instance running the unique version_key field is what keeps versions unique.
+var publishMu sync.Mutex
+
+// publishPending allocates the next version number to a pending EAT and
+// marks it published along with fields. It fails with a 409 conflict if
+// another version of the event has been published since the EAT was
+// submitted.
+func publishPending(eat *fastschema.EAT, fields map[string]interface{}) error {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+
+	if err := checkBaseVersion(eat.EventID, eat.BaseVersion); err != nil {
+		return err
+	}
+
+	eat.Version = eat.BaseVersion + 1
+	eat.VersionKey = fastschema.VersionKey(eat.EventID, eat.Version)
+	eat.State = fastschema.StatePublished
+	fields["version"] = eat.Version
+	fields["version_key"] = eat.VersionKey
+	fields["state"] = eat.State
+
+	if _, err := fsClient.UpdateEAT(eat.ID, fields); err != nil {
+		if errors.Is(err, fastschema.ErrConflict) {
+			return weft.StatusError{Code: http.StatusConflict, Err: fmt.Errorf("version %d of %s has already been published", eat.Version, eat.EventTitle)}
+		}
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+
+	return nil
+}
+
+// approveRequest is the JSON payload for the approve endpoint.
+type approveRequest struct {
+	ID       int    `json:"id"`
+	Decision string `json:"decision"` // "approve" or "reject"
+	Comment  string `json:"comment"`
+}
+
+// apiApproveHandler records an approver's decision on a pending EAT. Approval
+// allocates the version number and publishes the EAT, which generates the PDF
+// and sends email. The submitter cannot approve their own EAT, and an EAT
+// based on a version that has since been superseded can't be approved.
+func apiApproveHandler(r *http.Request, h http.Header, b *bytes.Buffer) error {
+	if err := weft.CheckQuery(r, []string{"POST"}, []string{}, []string{}); err != nil {
+		return err
+	}
+
+	var req approveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid JSON: %w", err)}
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+
+	switch req.Decision {
+	case "approve":
+	case "reject":
+		if req.Comment == "" {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("a comment is required to reject an EAT")}
+		}
+	default:
+		return weft.StatusError{Code: http.StatusBadRequest, Err: errors.New("decision must be 'approve' or 'reject'")}
+	}
+
+	user := auth.UserFrom(r.Context())
+	if user == nil {
+		return weft.StatusError{Code: http.StatusUnauthorized, Err: errors.New("unknown user")}
+	}
+
+	eat, err := fsClient.GetEAT(req.ID)
+	if err != nil {
+		return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+	}
+	if eat == nil {
+		return weft.StatusError{Code: http.StatusNotFound, Err: errors.New("EAT not found")}
+	}
+	if eat.State != fastschema.StatePendingApproval {
+		return weft.StatusError{Code: http.StatusConflict, Err: fmt.Errorf("EAT is %s, not pending approval", eat.State)}
+	}
+	if eat.SubmittedBy == user.Name {
+		return weft.StatusError{Code: http.StatusForbidden, Err: errors.New("an EAT must be approved by someone other than its submitter")}
+	}
+
+	now := time.Now().UTC()
+	eat.ReviewedBy = user.Name
+	eat.ReviewedAt = &now
+	eat.ReviewComment = req.Comment
+
+	fields := map[string]interface{}{
+		"reviewed_by":    eat.ReviewedBy,
+		"reviewed_at":    now.Format(time.RFC3339),
+		"review_comment": eat.ReviewComment,
+	}
+
+	if req.Decision == "reject" {
+		eat.State = fastschema.StateRejected
+		fields["state"] = eat.State
+		if _, err := fsClient.UpdateEAT(eat.ID, fields); err != nil {
+			return weft.StatusError{Code: http.StatusInternalServerError, Err: err}
+		}
+	} else if err := publishPending(eat, fields); err != nil {
+		return err
+	}
+
+	if eat.State == fastschema.StatePublished {
+		distributeEAT(eat)
+	}
+
+	h.Set("Content-Type", "application/json")
+	return json.NewEncoder(b).Encode(publishResponse{
+		Success: true,
+		ID:      eat.ID,
+		Version: eat.Version,
+		State:   eat.State,
+	})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/GeoNet/kit/weft/wefttest"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestReviewPages(t *testing.T) {
+	routes := wefttest.Requests{
+		{ID: wefttest.L(), URL: "/gha-portal/review", User: testApprover, Password: testApproverPassword, Content: "text/html; charset=utf-8"},
+		{ID: wefttest.L(), URL: "/gha-portal/review?id=51", User: testApprover, Password: testApproverPassword, Content: "text/html; charset=utf-8"},
+		// Published EATs aren't up for review
+		{ID: wefttest.L(), URL: "/gha-portal/review?id=1", User: testApprover, Password: testApproverPassword, Status: http.StatusNotFound},
+	}
+	if err := routes.DoAll(ts.URL); err != nil {
+		t.Error(err)
+	}
+}
+
+// approve posts a decision to /api/approve as the test approver.
+func approve(t *testing.T, req approveRequest) (int, publishResponse) {
+	t.Helper()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ts.URL+"/api/approve", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBasicAuth(testApprover, testApproverPassword)
+	r.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var pr publishResponse
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return resp.StatusCode, pr
+}
+
+func TestApprove(t *testing.T) {
+	code, resp := approve(t, approveRequest{ID: 51, Decision: "approve"})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if resp.State != fastschema.StatePublished {
+		t.Errorf("expected state published, got %s", resp.State)
+	}
+	// The mock already has version 1 of this event published.
+	if resp.Version != 2 {
+		t.Errorf("expected version 2, got %d", resp.Version)
+	}
+}
+
+func TestApproveOwnSubmission(t *testing.T) {
+	code, _ := approve(t, approveRequest{ID: 50, Decision: "approve"})
+	if code != http.StatusForbidden {
+		t.Errorf("expected 403 approving own submission, got %d", code)
+	}
+}
+
+func TestReject(t *testing.T) {
+	tests := []struct {
+		name string
+		req  approveRequest
+		code int
+	}{
+		{"with comment", approveRequest{ID: 51, Decision: "reject", Comment: "Magnitude is wrong"}, http.StatusOK},
+		{"without comment", approveRequest{ID: 51, Decision: "reject"}, http.StatusBadRequest},
+		{"bad decision", approveRequest{ID: 51, Decision: "maybe"}, http.StatusBadRequest},
+		{"not pending", approveRequest{ID: 1, Decision: "reject", Comment: "no"}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := approve(t, tt.req)
+			if code != tt.code {
+				t.Fatalf("expected %d, got %d", tt.code, code)
+			}
+			if code == http.StatusOK && resp.State != fastschema.StateRejected {
+				t.Errorf("expected state rejected, got %s", resp.State)
+			}
+		})
+	}
+}
+
+// submit posts an EAT to /api/publish as the test editor.
+func submit(t *testing.T, body string) *http.Response {
+	t.Helper()
+
+	r, err := http.NewRequest(http.MethodPost, ts.URL+"/api/publish", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBasicAuth(testEditor, testEditorPassword)
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestPublishSubmitsForApproval(t *testing.T) {
+	resp := submit(t, `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"confirmed"}`)
+	defer resp.Body.Close()
+
+	var pr publishResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		t.Fatal(err)
+	}
+	if !pr.Success || pr.State != fastschema.StatePendingApproval {
+		t.Errorf("expected a pending submission, got %+v", pr)
+	}
+}
+
+func TestPublishAttachments(t *testing.T) {
+	for len(createdEATs) > 0 {
+		<-createdEATs
+	}
+
+	// Attachments are given by ID and their records, URLs included, come from
+	// FastSchema. Emailed IDs that aren't attached are dropped.
+	resp := submit(t, `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"confirmed",
+		"attachments":[7,9],"email_attachments":[9,8]}`)
+	resp.Body.Close()
+
+	select {
+	case eat := <-createdEATs:
+		if len(eat.Attachments) != 2 || eat.Attachments[0].URL != "/files/map.png" || eat.Attachments[1].Name != "notes.txt" {
+			t.Errorf("unexpected attachments %+v", eat.Attachments)
+		}
+		if !reflect.DeepEqual(eat.EmailAttachments, []int{9}) {
+			t.Errorf("expected only attached files emailed, got %v", eat.EmailAttachments)
+		}
+	default:
+		t.Fatal("expected an EAT to be created")
+	}
+
+	resp = submit(t, `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"confirmed",
+		"attachments":[{"id":1,"url":"http://169.254.169.254/"}]}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected file records to be refused, got %d", resp.StatusCode)
+	}
+
+	resp = submit(t, `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"confirmed","attachments":[42]}`)
+	defer resp.Body.Close()
+	var pr publishResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		t.Fatal(err)
+	}
+	if pr.Success || pr.Error != "attachment 42 not found" {
+		t.Errorf("expected an unknown attachment to be refused, got %+v", pr)
+	}
+}
+
+func TestPublishStaleBaseVersion(t *testing.T) {
+	// The mock has version 1 of this event published.
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"new event that exists", `{"mode":"new_event","public_id":"2026p000001","location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"preliminary"}`},
+		{"superseded base", `{"mode":"new_version","existing_eat_id":1,"base_version":0,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"preliminary"}`},
+		{"unknown base", `{"mode":"new_version","existing_eat_id":1,"base_version":4,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"preliminary"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := submit(t, tt.body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusConflict {
+				t.Errorf("expected 409, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestPublishEvents(t *testing.T) {
+	// The mock has version 1 of event 2026p000001, titled
+	// M5.0-Wellington-2026-01-01, published.
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"new event with the same title", `{"mode":"new_event","location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"preliminary"}`},
+		{"new event from the quake feed", `{"mode":"new_event","public_id":"2026p000002","location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.0,"status":"preliminary"}`},
+		{"revised magnitude", `{"mode":"new_version","existing_eat_id":1,"base_version":1,"location":"Wellington","event_date":"2026-01-01T00:00","magnitude":5.3,"status":"confirmed"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := submit(t, tt.body)
+			defer resp.Body.Close()
+
+			var pr publishResponse
+			if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+				t.Fatal(err)
+			}
+			if !pr.Success {
+				t.Errorf("expected success, got %+v", pr)
+			}
+		})
+	}
+}
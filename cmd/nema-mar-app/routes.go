@@ -4,10 +4,20 @@ import (
 	"net/http"
 
 	"github.com/GeoNet/kit/weft"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
 )
 
 var mux *http.ServeMux
 
+// authenticator identifies users for the protected routes. It is set in main
+// (and by tests); while nil protected routes are unavailable.
+var authenticator auth.Authenticator
+
+// requireRole wraps h so that only users granted role can reach it.
+func requireRole(role auth.Role, h http.HandlerFunc) http.HandlerFunc {
+	return auth.Require(func() auth.Authenticator { return authenticator }, role, h)
+}
+
 func init() {
 	mux = http.NewServeMux()
 
@@ -15,18 +25,40 @@ func init() {
 	mux.HandleFunc("/soh/up", weft.MakeHandler(weft.Up, weft.TextError))
 	mux.HandleFunc("/soh", weft.MakeHandler(weft.Soh, weft.TextError))
 
+	// OpenID Connect login
+	mux.HandleFunc("/auth/login", weft.MakeDirectHandler(authLoginHandler, weft.TextError))
+	mux.HandleFunc("/auth/callback", weft.MakeDirectHandler(authCallbackHandler, weft.TextError))
+	mux.HandleFunc("/auth/logout", weft.MakeDirectHandler(authLogoutHandler, weft.TextError))
+
 	// Editor portal (HTML pages with nonce for inline JS)
-	mux.HandleFunc("/gha-portal", weft.MakeHandlerWithNonce(portalPageHandler, weft.HTMLError))
-	mux.HandleFunc("/gha-portal/preview", weft.MakeHandlerWithNonce(portalPreviewHandler, weft.HTMLError))
+	mux.HandleFunc("/gha-portal", requireRole(auth.Editor, weft.MakeHandlerWithNonce(portalPageHandler, weft.HTMLError)))
+	mux.HandleFunc("/gha-portal/preview", requireRole(auth.Editor, weft.MakeHandlerWithNonce(portalPreviewHandler, weft.HTMLError)))
+	mux.HandleFunc("/gha-portal/deliveries", requireRole(auth.Editor, weft.MakeHandlerWithNonce(deliveriesPageHandler, weft.HTMLError)))
+	mux.HandleFunc("/gha-portal/review", requireRole(auth.Approver, weft.MakeHandlerWithNonce(reviewPageHandler, weft.HTMLError)))
 
 	// Dashboard (HTML page with nonce for map embed JS)
-	mux.HandleFunc("/dashboard", weft.MakeHandlerWithNonce(dashboardHandler, weft.HTMLError))
+	mux.HandleFunc("/dashboard", requireRole(auth.Reader, weft.MakeHandlerWithNonce(dashboardHandler, weft.HTMLError)))
+	mux.HandleFunc("/dashboard/diff", requireRole(auth.Reader, weft.MakeHandlerWithNonce(dashboardDiffHandler, weft.HTMLError)))
+	mux.HandleFunc("/dashboard/attachment", requireRole(auth.Reader, weft.MakeHandler(attachmentHandler, weft.TextError)))
+	mux.HandleFunc("/dashboard/map.svg", requireRole(auth.Reader, weft.MakeHandler(mapHandler, weft.TextError)))
+	mux.HandleFunc("/dashboard/map.png", requireRole(auth.Reader, weft.MakeHandler(mapHandler, weft.TextError)))
+
+	// Atom and RSS feeds of published EATs
+	mux.HandleFunc("/feed/atom", requireRole(auth.Reader, weft.MakeHandler(feedAtomHandler, weft.TextError)))
+	mux.HandleFunc("/feed/rss", requireRole(auth.Reader, weft.MakeHandler(feedRSSHandler, weft.TextError)))
 
 	// App's own JSON API endpoints (called by JS on editor page).
 	// These are NOT FastSchema proxy endpoints — they are nema-mar-app's own
 	// endpoints that internally call FastSchema for data persistence.
-	mux.HandleFunc("/api/events", weft.MakeHandler(apiEventsHandler, weft.TextError))
-	mux.HandleFunc("/api/eat", weft.MakeHandler(apiEATHandler, weft.TextError))
-	mux.HandleFunc("/api/publish", weft.MakeHandler(apiPublishHandler, weft.TextError))
-	mux.HandleFunc("/api/upload", weft.MakeDirectHandler(apiUploadHandler, weft.TextError))
+	mux.HandleFunc("/api/events", requireRole(auth.Reader, weft.MakeHandler(apiEventsHandler, weft.TextError)))
+	mux.HandleFunc("/api/eat", requireRole(auth.Reader, weft.MakeHandler(apiEATHandler, weft.TextError)))
+	mux.HandleFunc("/api/eat/diff", requireRole(auth.Reader, weft.MakeHandler(apiDiffHandler, weft.TextError)))
+	mux.HandleFunc("/api/eat.cap", requireRole(auth.Reader, weft.MakeHandler(apiCAPHandler, weft.TextError)))
+	mux.HandleFunc("/api/publish", requireRole(auth.Editor, weft.MakeHandler(apiPublishHandler, weft.TextError)))
+	mux.HandleFunc("/api/approve", requireRole(auth.Approver, weft.MakeHandler(apiApproveHandler, weft.TextError)))
+	mux.HandleFunc("/api/quakes", requireRole(auth.Editor, weft.MakeHandler(apiQuakesHandler, weft.TextError)))
+	mux.HandleFunc("/api/drafts", requireRole(auth.Editor, weft.MakeHandler(apiDraftsHandler, weft.TextError)))
+	mux.HandleFunc("/api/resend", requireRole(auth.Editor, weft.MakeHandler(apiResendHandler, weft.TextError)))
+	mux.HandleFunc("/api/upload", requireRole(auth.Editor, weft.MakeDirectHandler(apiUploadHandler, weft.TextError)))
+	mux.HandleFunc("/api/stream", requireRole(auth.Reader, weft.MakeDirectHandler(apiStreamHandler, weft.TextError)))
 }
@@ -6,15 +6,131 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/GeoNet/kit/weft/wefttest"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
 	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/quakefeed"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var ts *httptest.Server
 
+// Test users for the protected routes.
+const (
+	testEditor         = "editor"
+	testEditorPassword = "editor-pass"
+	testReader         = "reader"
+	testReaderPassword = "reader-pass"
+
+	testApprover         = "approver"
+	testApproverPassword = "approver-pass"
+)
+
+// mockEATs are the records served by the mock FastSchema server by ID.
+var mockEATs = map[string]fastschema.EAT{
+	"1": {ID: 1, EventID: 1, EventTitle: "M5.0-Wellington-2026-01-01", Location: "Wellington", Version: 1, Status: "preliminary", State: fastschema.StatePublished},
+	"2": {ID: 2, EventID: 2, EventTitle: "M6.2-Kaikoura-2026-02-01", Location: "Kaikoura", Version: 1, Status: "confirmed", State: fastschema.StatePublished,
+		Attachments: []fastschema.File{
+			{ID: 7, Name: "map.png", Type: "image/png", URL: "/files/map.png", Size: 8},
+			{ID: 8, Name: "bulletin.pdf", Type: "application/pdf", URL: "/files/bulletin.pdf", Size: 2 << 20},
+			{ID: 9, Name: "notes.txt", Type: "text/plain", URL: "/files/notes.txt", Size: 5},
+		},
+		EmailAttachments: []int{7, 8}},
+	"3": mockExercise,
+	"50": {ID: 50, EventID: 1, EventTitle: "M5.0-Wellington-2026-01-01", Location: "Wellington", Status: "confirmed",
+		BaseVersion: 1, State: fastschema.StatePendingApproval, SubmittedBy: testApprover},
+	"51": {ID: 51, EventID: 1, EventTitle: "M5.0-Wellington-2026-01-01", Location: "Wellington", Status: "confirmed",
+		BaseVersion: 1, State: fastschema.StatePendingApproval, SubmittedBy: testEditor},
+	"52": {ID: 52, EventID: 1, EventTitle: "M5.0-Wellington-2026-01-01", Location: "Wellington", Status: fastschema.StatusCancelled,
+		CancelReason: "Duplicate of event 2.", BaseVersion: 1, State: fastschema.StatePendingApproval, SubmittedBy: testEditor},
+}
+
+func bcryptHash(s string) string {
+	h, err := bcrypt.GenerateFromPassword([]byte(s), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return string(h)
+}
+
+// mockPublished is the published EAT of event 1 listed by the mock
+// FastSchema server.
+var mockPublished = fastschema.EAT{
+	ID:         1,
+	EventID:    1,
+	EventTitle: "M5.0-Wellington-2026-01-01",
+	Location:   "Wellington",
+	EventDate:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	Magnitude:  5.0,
+	Version:    1,
+	Status:     "preliminary",
+	State:      fastschema.StatePublished,
+}
+
+// mockRevised is version 2 of event 1, found only by asking for it, so the
+// other tests see version 1 as the latest.
+var mockRevised = fastschema.EAT{
+	ID:            4,
+	EventID:       1,
+	EventTitle:    "M5.0-Wellington-2026-01-01",
+	Location:      "Wellington",
+	EventDate:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	Magnitude:     5.0,
+	Version:       2,
+	Status:        "confirmed",
+	LandThreat:    true,
+	EventComments: "Stay away from the coast.",
+	Attachments:   []fastschema.File{{ID: 7, Name: "map.png", Type: "image/png", URL: "/files/map.png", Size: 8}},
+	State:         fastschema.StatePublished,
+}
+
+// mockExercise is the published EAT of exercise event 3.
+var mockExercise = fastschema.EAT{
+	ID:         3,
+	EventID:    3,
+	EventTitle: "M7.8-Hikurangi-2026-01-02",
+	Location:   "Hikurangi",
+	EventDate:  time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+	Magnitude:  7.8,
+	Version:    1,
+	Status:     "confirmed",
+	State:      fastschema.StatePublished,
+	Exercise:   true,
+}
+
+// mockEvent is the event of the published EAT served by the mock FastSchema
+// server.
+var mockEvent = fastschema.Event{ID: 1, PublicID: "2026p000001"}
+
+// mockDrafts are the editor drafts served by the mock FastSchema server by ID.
+var mockDrafts = map[string]fastschema.Draft{
+	"5": {ID: 5, Owner: testEditor, EventTitle: "M5.0-Wellington-2026-01-01", Form: json.RawMessage(`{"mode":"new_version"}`)},
+	"6": {ID: 6, Owner: testApprover, Form: json.RawMessage(`{"mode":"new_event"}`)},
+}
+
+// mockDeliveries are the email delivery jobs served by the mock FastSchema server.
+var mockDeliveries = []fastschema.Delivery{
+	{ID: 1, EATID: 1, Recipient: "ok@example.com", Status: fastschema.DeliverySent, Attempts: 1,
+		SentAt: &time.Time{}},
+	{ID: 2, EATID: 1, Recipient: "down@example.com", Status: fastschema.DeliveryPending, Attempts: 3,
+		LastError: "dial smtp: connection refused"},
+	{ID: 3, EATID: 1, Recipient: "bad@example.com", Status: fastschema.DeliveryFailed, Attempts: 1, SMTPCode: 550,
+		LastError: "smtp rcpt: 550 5.1.1 no such user",
+		Log:       []fastschema.DeliveryAttempt{{Outcome: "rejected", Code: 550, Message: "smtp rcpt: 550 5.1.1 no such user"}}},
+}
+
+// createdEATs receives EATs created on the mock FastSchema server, if there's
+// room, for tests that check what was submitted.
+var createdEATs = make(chan fastschema.EAT, 10)
+
+// createdDeliveries receives delivery jobs created on the mock FastSchema server.
+var createdDeliveries = make(chan fastschema.Delivery, 100)
+
 func TestMain(m *testing.M) {
 	// Set up a mock FastSchema server
 	mockFS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -27,22 +143,20 @@ func TestMain(m *testing.M) {
 			})
 
 		case r.URL.Path == "/api/content/eat" && r.Method == http.MethodGet:
-			resp := fastschema.ListResponse{
-				Data: fastschema.ListData{
-					Total: 1,
-					Items: []fastschema.EAT{
-						{
-							ID:         1,
-							EventTitle: "M5.0-Wellington-2026-01-01",
-							Location:   "Wellington",
-							EventDate:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
-							Magnitude:  5.0,
-							Version:    1,
-							Status:     "preliminary",
-						},
-					},
-				},
+			// Event 1 has a published version, and event 3 is an exercise
+			// published since. Version 2 of event 1 is only found by number.
+			var resp fastschema.ListResponse
+			switch filter := r.URL.Query().Get("filter"); {
+			case strings.Contains(filter, `"event_id":{"$eq":1},"version":{"$eq":2}`):
+				resp.Data.Items = []fastschema.EAT{mockRevised}
+			case strings.Contains(filter, `"event_id":{"$eq":1}`):
+				resp.Data.Items = []fastschema.EAT{mockPublished}
+			case strings.Contains(filter, `"event_id":{"$eq":3}`):
+				resp.Data.Items = []fastschema.EAT{mockExercise}
+			case !strings.Contains(filter, `"event_id"`):
+				resp.Data.Items = []fastschema.EAT{mockExercise, mockPublished}
 			}
+			resp.Data.Total = len(resp.Data.Items)
 			json.NewEncoder(w).Encode(resp)
 
 		case r.URL.Path == "/api/content/eat" && r.Method == http.MethodPost:
@@ -50,9 +164,90 @@ func TestMain(m *testing.M) {
 			json.NewDecoder(r.Body).Decode(&eat)
 			eat.ID = 99
 			eat.CreatedAt = time.Now()
+			select {
+			case createdEATs <- eat:
+			default:
+			}
 			resp := fastschema.SingleResponse{Data: eat}
 			json.NewEncoder(w).Encode(resp)
 
+		case strings.HasPrefix(r.URL.Path, "/api/content/eat/") && r.Method == http.MethodGet:
+			eat, ok := mockEATs[strings.TrimPrefix(r.URL.Path, "/api/content/eat/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(fastschema.SingleResponse{Data: eat})
+
+		case strings.HasPrefix(r.URL.Path, "/api/content/eat/") && r.Method == http.MethodPut:
+			eat := mockEATs[strings.TrimPrefix(r.URL.Path, "/api/content/eat/")]
+			json.NewDecoder(r.Body).Decode(&eat)
+			json.NewEncoder(w).Encode(fastschema.SingleResponse{Data: eat})
+
+		case r.URL.Path == "/api/content/event" && r.Method == http.MethodGet:
+			var resp fastschema.EventListResponse
+			if strings.Contains(r.URL.Query().Get("filter"), mockEvent.PublicID) {
+				resp.Data.Items = append(resp.Data.Items, mockEvent)
+			}
+			json.NewEncoder(w).Encode(resp)
+
+		case r.URL.Path == "/api/content/event" && r.Method == http.MethodPost:
+			var e fastschema.Event
+			json.NewDecoder(r.Body).Decode(&e)
+			e.ID = 97
+			json.NewEncoder(w).Encode(fastschema.EventResponse{Data: e})
+
+		case r.URL.Path == "/api/content/draft" && r.Method == http.MethodGet:
+			var resp fastschema.DraftListResponse
+			for _, d := range mockDrafts {
+				resp.Data.Items = append(resp.Data.Items, d)
+			}
+			json.NewEncoder(w).Encode(resp)
+
+		case strings.HasPrefix(r.URL.Path, "/api/content/draft"):
+			d, ok := mockDrafts[strings.TrimPrefix(r.URL.Path, "/api/content/draft/")]
+			if r.Method != http.MethodPost && !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodPost {
+				d.ID = 98
+			}
+			if r.Method == http.MethodPost || r.Method == http.MethodPut {
+				json.NewDecoder(r.Body).Decode(&d)
+			}
+			json.NewEncoder(w).Encode(fastschema.DraftResponse{Data: d})
+
+		case r.URL.Path == "/api/content/delivery" && r.Method == http.MethodGet:
+			var resp fastschema.DeliveryListResponse
+			resp.Data.Items = mockDeliveries
+			json.NewEncoder(w).Encode(resp)
+
+		case r.URL.Path == "/api/content/delivery" && r.Method == http.MethodPost:
+			var d fastschema.Delivery
+			json.NewDecoder(r.Body).Decode(&d)
+			createdDeliveries <- d
+			json.NewEncoder(w).Encode(fastschema.DeliveryResponse{Data: d})
+
+		case strings.HasPrefix(r.URL.Path, "/api/content/delivery/") && r.Method == http.MethodPut:
+			var d fastschema.Delivery
+			json.NewDecoder(r.Body).Decode(&d)
+			json.NewEncoder(w).Encode(fastschema.DeliveryResponse{Data: d})
+
+		case strings.HasPrefix(r.URL.Path, "/api/content/file/") && r.Method == http.MethodGet:
+			// Uploaded files are those attached to EAT 2.
+			id := strings.TrimPrefix(r.URL.Path, "/api/content/file/")
+			for _, f := range mockEATs["2"].Attachments {
+				if strconv.Itoa(f.ID) == id {
+					json.NewEncoder(w).Encode(fastschema.FileResponse{Data: f})
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+
+		case strings.HasPrefix(r.URL.Path, "/files/"):
+			w.Write([]byte("file:" + strings.TrimPrefix(r.URL.Path, "/files/")))
+
 		case r.URL.Path == "/api/schema" && r.Method == http.MethodPost:
 			w.WriteHeader(http.StatusOK)
 
@@ -63,6 +258,26 @@ func TestMain(m *testing.M) {
 
 	fsClient = fastschema.NewClient(mockFS.URL)
 
+	mockQuakes := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/quake" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"type":"FeatureCollection","features":[{"type":"Feature",
+			"geometry":{"type":"Point","coordinates":[174.7787,-41.2865]},
+			"properties":{"publicID":"2026p038412","time":"2026-01-15T10:30:12.345Z","depth":22.4,"magnitude":5.43,"mmi":5,
+			"locality":"10 km north-west of Wellington","quality":"best"}}]}`))
+	}))
+	quakeClient = quakefeed.NewClient(mockQuakes.URL)
+
+	users, err := auth.ParseBasic(testEditor + ":editor:" + bcryptHash(testEditorPassword) + "," +
+		testReader + ":reader:" + bcryptHash(testReaderPassword) + "," +
+		testApprover + ":editor|approver:" + bcryptHash(testApproverPassword))
+	if err != nil {
+		panic("could not set up test users: " + err.Error())
+	}
+	authenticator = users
+
 	// Load templates from the local templates directory
 	templateDir := filepath.Join("templates")
 	if err := loadTemplates(templateDir); err != nil {
@@ -78,6 +293,7 @@ func TestMain(m *testing.M) {
 	code := m.Run()
 
 	ts.Close()
+	mockQuakes.Close()
 	mockFS.Close()
 	os.Exit(code)
 }
@@ -86,9 +302,18 @@ func TestRoutes(t *testing.T) {
 	routes := wefttest.Requests{
 		{ID: wefttest.L(), URL: "/soh/up"},
 		{ID: wefttest.L(), URL: "/soh"},
-		{ID: wefttest.L(), URL: "/gha-portal"},
-		{ID: wefttest.L(), URL: "/dashboard"},
-		{ID: wefttest.L(), URL: "/api/events", Content: "application/json"},
+		{ID: wefttest.L(), URL: "/gha-portal", User: testEditor, Password: testEditorPassword},
+		{ID: wefttest.L(), URL: "/dashboard", User: testEditor, Password: testEditorPassword},
+		{ID: wefttest.L(), URL: "/dashboard?event=1&version=1", User: testEditor, Password: testEditorPassword},
+		{ID: wefttest.L(), URL: "/api/events", User: testEditor, Password: testEditorPassword, Content: "application/json"},
+		{ID: wefttest.L(), URL: "/api/eat?event=1", User: testEditor, Password: testEditorPassword, Content: "application/json"},
+		{ID: wefttest.L(), URL: "/api/eat?event=2", User: testEditor, Password: testEditorPassword, Status: http.StatusNotFound},
+		{ID: wefttest.L(), URL: "/api/eat?event=M5.0-Wellington-2026-01-01", User: testEditor, Password: testEditorPassword, Status: http.StatusBadRequest},
+		{ID: wefttest.L(), URL: "/feed/atom", User: testEditor, Password: testEditorPassword, Content: "application/atom+xml; charset=utf-8"},
+		{ID: wefttest.L(), URL: "/feed/rss", User: testEditor, Password: testEditorPassword, Content: "application/rss+xml; charset=utf-8"},
+		{ID: wefttest.L(), URL: "/api/eat.cap?event=1", User: testEditor, Password: testEditorPassword, Content: "application/cap+xml"},
+		{ID: wefttest.L(), URL: "/api/eat.cap?id=1", User: testReader, Password: testReaderPassword, Content: "application/cap+xml"},
+		{ID: wefttest.L(), URL: "/api/eat.cap?id=50", User: testReader, Password: testReaderPassword, Status: http.StatusNotFound},
 	}
 	if err := routes.DoAll(ts.URL); err != nil {
 		t.Error(err)
@@ -107,11 +332,41 @@ func TestRoutes404(t *testing.T) {
 func TestMethodNotAllowed(t *testing.T) {
 	// /api/events uses TextError which returns text/plain for errors
 	r := wefttest.Request{
-		ID:      wefttest.L(),
-		URL:     "/api/events",
-		Content: "text/plain; charset=utf-8",
+		ID:       wefttest.L(),
+		URL:      "/api/events",
+		User:     testReader,
+		Password: testReaderPassword,
+		Content:  "text/plain; charset=utf-8",
 	}
 	if _, err := r.MethodNotAllowed(ts.URL, []string{"GET"}); err != nil {
 		t.Error(err)
 	}
 }
+
+func TestRoutesAuth(t *testing.T) {
+	routes := wefttest.Requests{
+		// No credentials
+		{ID: wefttest.L(), URL: "/gha-portal", Status: http.StatusUnauthorized},
+		{ID: wefttest.L(), URL: "/dashboard", Status: http.StatusUnauthorized},
+		{ID: wefttest.L(), URL: "/api/publish", Method: "POST", Status: http.StatusUnauthorized},
+		// Bad password
+		{ID: wefttest.L(), URL: "/dashboard", User: testReader, Password: "wrong", Status: http.StatusUnauthorized},
+		// Readers can view the dashboard but not the portal or write APIs
+		{ID: wefttest.L(), URL: "/dashboard", User: testReader, Password: testReaderPassword},
+		{ID: wefttest.L(), URL: "/gha-portal", User: testReader, Password: testReaderPassword, Status: http.StatusForbidden},
+		{ID: wefttest.L(), URL: "/api/publish", Method: "POST", User: testReader, Password: testReaderPassword, Status: http.StatusForbidden},
+		{ID: wefttest.L(), URL: "/api/upload", Method: "POST", User: testReader, Password: testReaderPassword, Status: http.StatusForbidden},
+		{ID: wefttest.L(), URL: "/api/quakes", User: testReader, Password: testReaderPassword, Status: http.StatusForbidden},
+		// Only approvers can review
+		{ID: wefttest.L(), URL: "/gha-portal/review", User: testEditor, Password: testEditorPassword, Status: http.StatusForbidden},
+		{ID: wefttest.L(), URL: "/api/approve", Method: "POST", User: testEditor, Password: testEditorPassword, Status: http.StatusForbidden},
+		// Delivery status is for operators
+		{ID: wefttest.L(), URL: "/gha-portal/deliveries?id=1", User: testReader, Password: testReaderPassword, Status: http.StatusForbidden},
+		{ID: wefttest.L(), URL: "/api/resend", Method: "POST", User: testReader, Password: testReaderPassword, Status: http.StatusForbidden},
+		// State of health stays public
+		{ID: wefttest.L(), URL: "/soh"},
+	}
+	if err := routes.DoAll(ts.URL); err != nil {
+		t.Error(err)
+	}
+}
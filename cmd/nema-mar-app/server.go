@@ -2,15 +2,24 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/GeoNet/kit/health"
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
+	"github.com/GeoNet/nema-mar-portal/internal/email"
 	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/oidc"
+	"github.com/GeoNet/nema-mar-portal/internal/outbox"
+	"github.com/GeoNet/nema-mar-portal/internal/pdf"
+	"github.com/GeoNet/nema-mar-portal/internal/quakefeed"
 )
 
 // sourceDir returns the directory of this source file, used to resolve
@@ -21,9 +30,29 @@ func sourceDir() string {
 }
 
 var (
-	fsClient *fastschema.Client
+	fsClient  *fastschema.Client
+	publicURL string // externally visible base URL, e.g. https://nema-mar.geonet.org.nz
 )
 
+// baseURL returns the externally visible base URL of the app. PUBLIC_URL is
+// used when set, otherwise it is derived from the request, honouring the
+// X-Forwarded-Proto header set by the load balancer.
+func baseURL(r *http.Request) string {
+	if publicURL != "" {
+		return publicURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p == "http" || p == "https" {
+		scheme = p
+	}
+
+	return scheme + "://" + r.Host
+}
+
 func main() {
 	if health.RunningHealthCheck() {
 		healthCheck()
@@ -36,6 +65,27 @@ func main() {
 
 	fsClient = fastschema.NewClient(fsURL)
 
+	publicURL = strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+
+	if u := os.Getenv("QUAKE_FEED_URL"); u != "" {
+		quakeClient = quakefeed.NewClient(u)
+	}
+
+	if s := os.Getenv("EXERCISE_MODE"); s != "" {
+		mode, err := strconv.ParseBool(s)
+		if err != nil {
+			log.Fatalf("invalid EXERCISE_MODE: %s", s)
+		}
+		exerciseMode = mode
+		if exerciseMode {
+			log.Println("exercise mode: every EAT submitted is marked as an exercise")
+		}
+	}
+
+	if sender := os.Getenv("CAP_SENDER"); sender != "" {
+		capOptions.Sender = sender
+	}
+
 	// Authenticate with FastSchema sidecar.
 	fsUser := os.Getenv("FS_ADMIN_USER")
 	fsPass := os.Getenv("FS_ADMIN_PASS")
@@ -45,22 +95,80 @@ func main() {
 		}
 	}
 
-	// Apply schema on startup.
-	schemaPath := os.Getenv("SCHEMA_PATH")
-	if schemaPath == "" {
-		schemaPath = "/app/schema/eat.json"
-		if _, err := os.Stat(schemaPath); os.IsNotExist(err) {
-			// Fall back to source-relative path for local development.
-			schemaPath = filepath.Join(sourceDir(), "..", "..", "schema", "eat.json")
+	// The PDF layout is a template config comms can change without a release.
+	pdfLayout = pdf.DefaultLayout()
+	if path := os.Getenv("PDF_LAYOUT_FILE"); path != "" {
+		l, err := pdf.LoadLayout(path)
+		if err != nil {
+			log.Fatalf("invalid PDF_LAYOUT_FILE: %v", err)
 		}
+		pdfLayout = l
 	}
-	schemaJSON, err := os.ReadFile(schemaPath)
-	if err != nil {
-		log.Printf("warning: could not read schema file %s: %v", schemaPath, err)
+
+	// Email is delivered from a persisted outbox so failed sends are retried.
+	if cfg, err := email.ConfigFromEnv(); err != nil {
+		log.Printf("warning: email not configured: %v", err)
 	} else {
-		if err := fsClient.ApplySchema(schemaJSON); err != nil {
-			log.Printf("warning: failed to apply schema: %v", err)
+		emailConfig = cfg
+		outboxWorker = outbox.NewWorker(fsClient, deliverEAT)
+		go outboxWorker.Run(context.Background())
+	}
+
+	// Users for the portal and dashboard: OIDC login for staff, with optional
+	// basic auth users for automation and feed readers.
+	var authenticators auth.Multi
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		if err := configureOIDC(issuer); err != nil {
+			log.Fatalf("failed to configure OIDC: %v", err)
+		}
+		authenticators = append(authenticators, oidcLogin.sessions)
+	}
+	if users := os.Getenv("AUTH_USERS"); users != "" {
+		b, err := auth.ParseBasic(users)
+		if err != nil {
+			log.Fatalf("invalid AUTH_USERS: %v", err)
 		}
+		authenticators = append(authenticators, b)
+	}
+	if len(authenticators) > 0 {
+		authenticator = authenticators
+	} else {
+		log.Println("warning: neither OIDC_ISSUER nor AUTH_USERS set, portal and dashboard are unavailable")
+	}
+
+	// Apply schemas on startup.
+	schemaDir := os.Getenv("SCHEMA_DIR")
+	if schemaDir == "" {
+		schemaDir = "/app/schema"
+		if _, err := os.Stat(schemaDir); os.IsNotExist(err) {
+			// Fall back to source-relative path for local development.
+			schemaDir = filepath.Join(sourceDir(), "..", "..", "schema")
+		}
+	}
+	schemaPaths, err := filepath.Glob(filepath.Join(schemaDir, "*.json"))
+	if err != nil || len(schemaPaths) == 0 {
+		log.Printf("warning: no schema files found in %s", schemaDir)
+	}
+	// Schemas related to one another can only be applied once both exist, so
+	// those that fail are tried again after the rest.
+	var failed []string
+	for _, schemaPath := range schemaPaths {
+		if err := applySchema(schemaPath); err != nil {
+			failed = append(failed, schemaPath)
+		}
+	}
+	for _, schemaPath := range failed {
+		if err := applySchema(schemaPath); err != nil {
+			log.Printf("warning: failed to apply schema %s: %v", filepath.Base(schemaPath), err)
+		}
+	}
+
+	// EATs saved before events were introduced are found by event only once
+	// they have one.
+	if n, err := migrateLegacyEATs(); err != nil {
+		log.Printf("warning: failed to give legacy EATs events, %d migrated: %v", n, err)
+	} else if n > 0 {
+		log.Printf("gave %d legacy EATs events", n)
 	}
 
 	// Load templates.
@@ -86,6 +194,56 @@ func main() {
 	log.Fatal(server.ListenAndServe())
 }
 
+// applySchema applies the FastSchema schema in the JSON file at path.
+func applySchema(path string) error {
+	schemaJSON, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return fsClient.ApplySchema(schemaJSON)
+}
+
+// configureOIDC discovers the identity provider and sets up OIDC login.
+func configureOIDC(issuer string) error {
+	key := os.Getenv("SESSION_KEY")
+	sessions, err := auth.NewSessions([]byte(key))
+	if err != nil {
+		return fmt.Errorf("SESSION_KEY: %w", err)
+	}
+	sessions.Secure = !strings.HasPrefix(publicURL, "http://")
+
+	groupRoles, err := auth.ParseGroupRoles(os.Getenv("OIDC_GROUP_ROLES"))
+	if err != nil {
+		return fmt.Errorf("OIDC_GROUP_ROLES: %w", err)
+	}
+
+	groupsClaim := os.Getenv("OIDC_GROUPS_CLAIM")
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	provider, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+	})
+	if err != nil {
+		return err
+	}
+
+	oidcLogin = &oidcSettings{
+		provider:    provider,
+		sessions:    sessions,
+		groupsClaim: groupsClaim,
+		groupRoles:  groupRoles,
+	}
+
+	return nil
+}
+
 func healthCheck() {
 	timeout := 30 * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -4,46 +4,52 @@ import (
 	"fmt"
 	"html/template"
 	"path/filepath"
-	"time"
 
+	"github.com/GeoNet/nema-mar-portal/internal/auth"
+	"github.com/GeoNet/nema-mar-portal/internal/diff"
+	"github.com/GeoNet/nema-mar-portal/internal/email"
 	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/format"
 )
 
 // Page holds data passed to HTML templates.
 type Page struct {
-	Nonce        string
-	Events       []string         // distinct event titles for dropdown
-	CurrentEAT   *fastschema.EAT  // current EAT for display/edit
-	Versions     []fastschema.EAT // version history
-	IsNewEvent   bool
-	IsNewVersion bool
-	Error        string
-	Success      string
-	HasNewBanner bool // show "new event/version" banner on dashboard
+	Nonce         string
+	User          *auth.User                // signed in user, nil if unknown
+	Events        []fastschema.EventSummary // recent events for dropdown
+	CurrentEAT    *fastschema.EAT           // current EAT for display/edit
+	Versions      []fastschema.EAT          // version history
+	Pending       []fastschema.EAT          // EATs awaiting approval
+	Drafts        []fastschema.Draft        // user's autosaved editor drafts
+	Deliveries    []fastschema.Delivery     // email delivery jobs for CurrentEAT
+	EmailGroups   []email.Group             // recipient groups CurrentEAT's email is routed to
+	EmailPrevious []string                  // earlier versions' recipients a cancellation is emailed to
+	EmailEnabled  bool                      // whether email sending is configured
+	LatestVersion int                       // latest version number of CurrentEAT's event
+	IsNewEvent    bool
+	Error         string
+	Success       string
+	HasNewBanner  bool       // show "new event/version" banner on dashboard
+	ShowExercises bool       // include exercise EATs on the dashboard
+	Diff          *diff.Diff // changes from an earlier version to CurrentEAT
 }
 
 var (
-	editorTemplate    *template.Template
-	dashboardTemplate *template.Template
-	previewTemplate   *template.Template
+	editorTemplate     *template.Template
+	dashboardTemplate  *template.Template
+	previewTemplate    *template.Template
+	reviewTemplate     *template.Template
+	deliveriesTemplate *template.Template
+	diffTemplate       *template.Template
 )
 
 var funcMap = template.FuncMap{
-	"formatDate": func(t time.Time) string {
-		return t.UTC().Format("2006-01-02T15:04")
-	},
-	"formatDateDisplay": func(t time.Time) string {
-		return t.UTC().Format("2006-01-02 15:04 UTC")
-	},
-	"formatMagnitude": func(m float32) string {
-		return fmt.Sprintf("%.1f", m)
-	},
-	"boolYesNo": func(b bool) string {
-		if b {
-			return "Yes"
-		}
-		return "No"
-	},
+	"formatDate":        format.DateInput,
+	"formatDateDisplay": format.Date,
+	"formatMagnitude":   format.Magnitude,
+	"formatEpicentre":   format.Epicentre,
+	"formatDepth":       format.Depth,
+	"boolYesNo":         format.YesNo,
 	"isImage": func(mimeType string) bool {
 		switch mimeType {
 		case "image/png", "image/jpeg", "image/gif", "image/webp":
@@ -51,9 +57,17 @@ var funcMap = template.FuncMap{
 		}
 		return false
 	},
+	"mapURL":  mapURL,
+	"diffURL": diffURL,
 	"isPDF": func(mimeType string) bool {
 		return mimeType == "application/pdf"
 	},
+	"exerciseMode": func() bool {
+		return exerciseMode
+	},
+	"oidcEnabled": func() bool {
+		return oidcLogin != nil
+	},
 }
 
 func loadTemplates(dir string) error {
@@ -76,5 +90,20 @@ func loadTemplates(dir string) error {
 		return fmt.Errorf("parsing preview template: %w", err)
 	}
 
+	reviewTemplate, err = template.New("base.html").Funcs(funcMap).ParseFiles(base, filepath.Join(dir, "review.html"))
+	if err != nil {
+		return fmt.Errorf("parsing review template: %w", err)
+	}
+
+	deliveriesTemplate, err = template.New("base.html").Funcs(funcMap).ParseFiles(base, filepath.Join(dir, "deliveries.html"))
+	if err != nil {
+		return fmt.Errorf("parsing deliveries template: %w", err)
+	}
+
+	diffTemplate, err = template.New("base.html").Funcs(funcMap).ParseFiles(base, filepath.Join(dir, "diff.html"))
+	if err != nil {
+		return fmt.Errorf("parsing diff template: %w", err)
+	}
+
 	return nil
 }
@@ -1,8 +1,9 @@
 module github.com/GeoNet/nema-mar-portal
 
-go 1.23
+go 1.23.0
 
 require (
 	github.com/GeoNet/kit v0.0.0-20250803205759-df08ce98e1ed
 	github.com/go-pdf/fpdf v0.9.0
+	golang.org/x/crypto v0.36.0
 )
@@ -0,0 +1,243 @@
+// Package auth provides authentication and role-based access control for
+// the portal and dashboard.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role is an application role granted to a user.
+type Role string
+
+// Roles understood by the application. Editors create and publish EATs,
+// approvers review them, and readers view the dashboard. Editors and
+// approvers can also read.
+const (
+	Editor   Role = "editor"
+	Approver Role = "approver"
+	Reader   Role = "reader"
+)
+
+// ErrUnauthenticated is returned by an Authenticator when the request carries
+// no valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// User is an authenticated user.
+type User struct {
+	Name  string `json:"name"`
+	Roles []Role `json:"roles"`
+}
+
+// HasRole reports whether u has been granted role. Every authenticated role
+// implies Reader.
+func (u *User) HasRole(role Role) bool {
+	if u == nil {
+		return false
+	}
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+		if role == Reader && (r == Editor || r == Approver) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRole validates and returns the Role named s.
+func ParseRole(s string) (Role, error) {
+	switch r := Role(strings.ToLower(strings.TrimSpace(s))); r {
+	case Editor, Approver, Reader:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown role: %q", s)
+}
+
+// ParseGroupRoles parses a mapping of identity provider groups to roles in
+// the form "group=role|role", separated by commas, as used in the
+// OIDC_GROUP_ROLES environment variable.
+func ParseGroupRoles(s string) (map[string][]Role, error) {
+	m := make(map[string][]Role)
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		group, roles, ok := strings.Cut(entry, "=")
+		group = strings.TrimSpace(group)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid group mapping: %q", entry)
+		}
+
+		for _, rs := range strings.Split(roles, "|") {
+			role, err := ParseRole(rs)
+			if err != nil {
+				return nil, fmt.Errorf("group %s: %w", group, err)
+			}
+			m[group] = append(m[group], role)
+		}
+	}
+
+	return m, nil
+}
+
+// RolesForGroups returns the distinct roles granted to members of groups.
+func RolesForGroups(m map[string][]Role, groups []string) []Role {
+	seen := make(map[Role]bool)
+	var roles []Role
+	for _, g := range groups {
+		for _, r := range m[g] {
+			if !seen[r] {
+				seen[r] = true
+				roles = append(roles, r)
+			}
+		}
+	}
+	return roles
+}
+
+// Authenticator identifies the user making a request.
+type Authenticator interface {
+	// Authenticate returns the user for r, or ErrUnauthenticated.
+	Authenticate(r *http.Request) (*User, error)
+	// Challenge responds to a request that failed authentication.
+	Challenge(w http.ResponseWriter, r *http.Request)
+}
+
+type contextKey struct{}
+
+// WithUser returns a copy of ctx carrying u.
+func WithUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, contextKey{}, u)
+}
+
+// UserFrom returns the user stored in ctx by Require, or nil.
+func UserFrom(ctx context.Context) *User {
+	u, _ := ctx.Value(contextKey{}).(*User)
+	return u
+}
+
+// Require wraps next so that it is only served to users with role. The
+// Authenticator is looked up on each request so it can be configured after
+// routes are registered. A nil Authenticator denies every request.
+func Require(a func() Authenticator, role Role, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authn := a()
+		if authn == nil {
+			http.Error(w, "authentication not configured", http.StatusServiceUnavailable)
+			return
+		}
+
+		u, err := authn.Authenticate(r)
+		if err != nil {
+			authn.Challenge(w, r)
+			return
+		}
+
+		if !u.HasRole(role) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), u)))
+	}
+}
+
+// Basic authenticates users with HTTP basic auth against a static list of
+// users with bcrypt password hashes.
+type Basic struct {
+	Realm string
+	users map[string]basicUser
+	// dummy is compared against for unknown users so they take as long as
+	// bad passwords.
+	dummy []byte
+}
+
+type basicUser struct {
+	hash  []byte
+	roles []Role
+}
+
+// ParseBasic parses users in the form "name:role|role:bcrypthash", separated
+// by commas, as used in the AUTH_USERS environment variable.
+func ParseBasic(s string) (*Basic, error) {
+	b := &Basic{Realm: "NEMA MAR Portal", users: make(map[string]basicUser)}
+	maxCost := 0
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid user entry: %q", entry)
+		}
+
+		hash := []byte(parts[2])
+		cost, err := bcrypt.Cost(hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid password hash for user %s: %w", parts[0], err)
+		}
+		maxCost = max(maxCost, cost)
+
+		var roles []Role
+		for _, rs := range strings.Split(parts[1], "|") {
+			role, err := ParseRole(rs)
+			if err != nil {
+				return nil, fmt.Errorf("user %s: %w", parts[0], err)
+			}
+			roles = append(roles, role)
+		}
+
+		b.users[parts[0]] = basicUser{hash: hash, roles: roles}
+	}
+
+	if len(b.users) > 0 {
+		dummy, err := bcrypt.GenerateFromPassword([]byte("unknown user"), maxCost)
+		if err != nil {
+			return nil, err
+		}
+		b.dummy = dummy
+	}
+
+	return b, nil
+}
+
+// Authenticate implements Authenticator.
+func (b *Basic) Authenticate(r *http.Request) (*User, error) {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	u, found := b.users[name]
+	if !found {
+		// Compare anyway so unknown users take as long as bad passwords.
+		if b.dummy != nil {
+			bcrypt.CompareHashAndPassword(b.dummy, []byte(password))
+		}
+		return nil, ErrUnauthenticated
+	}
+	if bcrypt.CompareHashAndPassword(u.hash, []byte(password)) != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	return &User{Name: name, Roles: u.roles}, nil
+}
+
+// Challenge implements Authenticator.
+func (b *Basic) Challenge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, b.Realm))
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
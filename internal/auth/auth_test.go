@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func bcryptHash(s string) string {
+	h, err := bcrypt.GenerateFromPassword([]byte(s), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return string(h)
+}
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		roles []Role
+		role  Role
+		want  bool
+	}{
+		{[]Role{Editor}, Editor, true},
+		{[]Role{Editor}, Reader, true},
+		{[]Role{Editor}, Approver, false},
+		{[]Role{Approver}, Reader, true},
+		{[]Role{Approver}, Editor, false},
+		{[]Role{Reader}, Reader, true},
+		{[]Role{Reader}, Editor, false},
+		{nil, Reader, false},
+	}
+
+	for _, tt := range tests {
+		u := &User{Name: "test", Roles: tt.roles}
+		if got := u.HasRole(tt.role); got != tt.want {
+			t.Errorf("%v.HasRole(%s) = %v, want %v", tt.roles, tt.role, got, tt.want)
+		}
+	}
+
+	var u *User
+	if u.HasRole(Reader) {
+		t.Error("nil user should have no roles")
+	}
+}
+
+func TestParseBasic(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"valid", "alice:editor|approver:" + bcryptHash("a") + ", bob:reader:" + bcryptHash("b"), false},
+		{"empty", "", false},
+		{"missing hash", "alice:editor", true},
+		{"bad hash", "alice:editor:xyz", true},
+		{"sha256 hash", "alice:editor:ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb", true},
+		{"unknown role", "alice:admin:" + bcryptHash("a"), true},
+		{"empty name", ":editor:" + bcryptHash("a"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBasic(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseBasic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	b, err := ParseBasic("alice:editor:" + bcryptHash("alice-pass") + ",bob:reader:" + bcryptHash("bob-pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen *User
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = UserFrom(r.Context())
+	})
+	h := Require(func() Authenticator { return b }, Editor, next)
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		status   int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"unknown user", "carol", "carol-pass", http.StatusUnauthorized},
+		{"wrong password", "alice", "bob-pass", http.StatusUnauthorized},
+		{"missing role", "bob", "bob-pass", http.StatusForbidden},
+		{"allowed", "alice", "alice-pass", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate challenge")
+			}
+			if tt.status == http.StatusOK && (seen == nil || seen.Name != tt.user) {
+				t.Errorf("expected user %s in context, got %+v", tt.user, seen)
+			}
+		})
+	}
+}
+
+func TestRequire_NotConfigured(t *testing.T) {
+	h := Require(func() Authenticator { return nil }, Reader, http.NotFoundHandler())
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Session cookie defaults.
+const (
+	DefaultSessionCookie = "nema_mar_session"
+	DefaultSessionTTL    = 12 * time.Hour
+)
+
+// ErrInvalidSeal is returned by Open for tampered, malformed or expired values.
+var ErrInvalidSeal = errors.New("invalid or expired sealed value")
+
+// Sessions issues and checks HMAC-signed session cookies. It implements
+// Authenticator for users who logged in through a login page such as OIDC.
+type Sessions struct {
+	Cookie    string        // cookie name
+	TTL       time.Duration // session lifetime
+	Secure    bool          // set the Secure attribute on cookies
+	LoginPath string        // browsers without a session are redirected here
+
+	key []byte
+}
+
+// NewSessions returns Sessions signing with key, which must be at least 32 bytes.
+func NewSessions(key []byte) (*Sessions, error) {
+	if len(key) < 32 {
+		return nil, errors.New("session key must be at least 32 bytes")
+	}
+	return &Sessions{
+		Cookie:    DefaultSessionCookie,
+		TTL:       DefaultSessionTTL,
+		Secure:    true,
+		LoginPath: "/auth/login",
+		key:       key,
+	}, nil
+}
+
+type sealed struct {
+	Expiry int64           `json:"exp"`
+	Value  json.RawMessage `json:"v"`
+}
+
+// Seal encodes v as a signed string that expires after ttl.
+func (s *Sessions) Seal(v interface{}, ttl time.Duration) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshal sealed value: %w", err)
+	}
+	payload, err := json.Marshal(sealed{Expiry: time.Now().Add(ttl).Unix(), Value: b})
+	if err != nil {
+		return "", fmt.Errorf("marshal sealed value: %w", err)
+	}
+
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	return p + "." + base64.RawURLEncoding.EncodeToString(s.sign(p)), nil
+}
+
+// Open verifies a string produced by Seal and decodes it into v.
+func (s *Sessions) Open(value string, v interface{}) error {
+	p, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return ErrInvalidSeal
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(p)) {
+		return ErrInvalidSeal
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return ErrInvalidSeal
+	}
+	var sv sealed
+	if err := json.Unmarshal(payload, &sv); err != nil {
+		return ErrInvalidSeal
+	}
+	if time.Now().Unix() > sv.Expiry {
+		return ErrInvalidSeal
+	}
+
+	if err := json.Unmarshal(sv.Value, v); err != nil {
+		return ErrInvalidSeal
+	}
+	return nil
+}
+
+func (s *Sessions) sign(payload string) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
+
+// Start sets a session cookie for u.
+func (s *Sessions) Start(w http.ResponseWriter, u *User) error {
+	v, err := s.Seal(u, s.TTL)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.Cookie,
+		Value:    v,
+		Path:     "/",
+		MaxAge:   int(s.TTL.Seconds()),
+		Secure:   s.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// End clears the session cookie.
+func (s *Sessions) End(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.Cookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   s.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Authenticate implements Authenticator.
+func (s *Sessions) Authenticate(r *http.Request) (*User, error) {
+	c, err := r.Cookie(s.Cookie)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+
+	var u User
+	if err := s.Open(c.Value, &u); err != nil || u.Name == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	return &u, nil
+}
+
+// Challenge implements Authenticator. Browser page requests are redirected to
+// the login page; everything else gets a 401.
+func (s *Sessions) Challenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, s.LoginPath+"?return_to="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return
+	}
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// Multi tries each Authenticator in turn. Failed requests are challenged by
+// the first one.
+type Multi []Authenticator
+
+// Authenticate implements Authenticator.
+func (m Multi) Authenticate(r *http.Request) (*User, error) {
+	for _, a := range m {
+		if u, err := a.Authenticate(r); err == nil {
+			return u, nil
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+// Challenge implements Authenticator.
+func (m Multi) Challenge(w http.ResponseWriter, r *http.Request) {
+	if len(m) == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	m[0].Challenge(w, r)
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestNewSessions(t *testing.T) {
+	if _, err := NewSessions([]byte("short")); err == nil {
+		t.Error("expected error for short key")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	s, err := NewSessions(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := s.Seal(map[string]string{"a": "b"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	if err := s.Open(v, &got); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if got["a"] != "b" {
+		t.Errorf("unexpected value: %v", got)
+	}
+
+	if err := s.Open("x"+v, &got); err == nil {
+		t.Error("expected error for tampered value")
+	}
+
+	other, _ := NewSessions([]byte("fedcba9876543210fedcba9876543210"))
+	if err := other.Open(v, &got); err == nil {
+		t.Error("expected error for value sealed with another key")
+	}
+
+	expired, _ := s.Seal("x", -time.Minute)
+	var x string
+	if err := s.Open(expired, &x); err == nil {
+		t.Error("expected error for expired value")
+	}
+}
+
+func TestSessionsAuthenticate(t *testing.T) {
+	s, err := NewSessions(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := s.Start(w, &User{Name: "alice", Roles: []Role{Editor}}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+
+	u, err := s.Authenticate(r)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if u.Name != "alice" || !u.HasRole(Editor) {
+		t.Errorf("unexpected user: %+v", u)
+	}
+
+	if _, err := s.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Error("expected error without a session cookie")
+	}
+}
+
+func TestSessionsChallenge(t *testing.T) {
+	s, err := NewSessions(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/dashboard?event_title=a", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w := httptest.NewRecorder()
+	s.Challenge(w, r)
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect for browser, got %d", w.Code)
+	}
+	if loc := w.Header().Get("Location"); !strings.HasPrefix(loc, "/auth/login?return_to=%2Fdashboard") {
+		t.Errorf("unexpected location: %s", loc)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/events", nil)
+	r.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	s.Challenge(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for API request, got %d", w.Code)
+	}
+}
+
+func TestParseGroupRoles(t *testing.T) {
+	m, err := ParseGroupRoles("ngmc-duty=editor, ngmc-senior=editor|approver,nema=reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := RolesForGroups(m, []string{"ngmc-duty", "ngmc-senior", "unmapped"})
+	if len(roles) != 2 {
+		t.Errorf("expected 2 distinct roles, got %v", roles)
+	}
+
+	if _, err := ParseGroupRoles("nema"); err == nil {
+		t.Error("expected error for missing roles")
+	}
+	if _, err := ParseGroupRoles("nema=admin"); err == nil {
+		t.Error("expected error for unknown role")
+	}
+}
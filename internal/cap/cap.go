@@ -0,0 +1,228 @@
+// Package cap maps EATs to Common Alerting Protocol (CAP 1.2) alert messages.
+package cap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// Namespace is the CAP 1.2 XML namespace.
+const Namespace = "urn:oasis:names:tc:emergency:cap:1.2"
+
+// ContentType is the media type for CAP documents.
+const ContentType = "application/cap+xml"
+
+// Defaults used when Options fields are empty.
+const (
+	DefaultSender     = "eat@geonet.org.nz"
+	DefaultSenderName = "GeoNet National Geohazards Monitoring Centre"
+)
+
+// CAP requires a numeric timezone offset rather than "Z".
+const timeFormat = "2006-01-02T15:04:05-07:00"
+
+// Options controls values in the alert that don't come from the EAT.
+type Options struct {
+	Sender     string // CAP sender, must not contain spaces, commas or restricted characters
+	SenderName string
+}
+
+// Alert is a CAP 1.2 alert message.
+type Alert struct {
+	XMLName     xml.Name `xml:"urn:oasis:names:tc:emergency:cap:1.2 alert"`
+	Identifier  string   `xml:"identifier"`
+	Sender      string   `xml:"sender"`
+	Sent        string   `xml:"sent"`
+	Status      string   `xml:"status"`
+	MsgType     string   `xml:"msgType"`
+	Scope       string   `xml:"scope"`
+	Restriction string   `xml:"restriction,omitempty"`
+	Note        string   `xml:"note,omitempty"`
+	References  string   `xml:"references,omitempty"`
+	Incidents   string   `xml:"incidents,omitempty"`
+	Info        []Info   `xml:"info"`
+}
+
+// Info holds the details of the event an alert describes.
+type Info struct {
+	Language    string      `xml:"language,omitempty"`
+	Category    []string    `xml:"category"`
+	Event       string      `xml:"event"`
+	Urgency     string      `xml:"urgency"`
+	Severity    string      `xml:"severity"`
+	Certainty   string      `xml:"certainty"`
+	Onset       string      `xml:"onset,omitempty"`
+	SenderName  string      `xml:"senderName,omitempty"`
+	Headline    string      `xml:"headline,omitempty"`
+	Description string      `xml:"description,omitempty"`
+	Web         string      `xml:"web,omitempty"`
+	Parameter   []Parameter `xml:"parameter"`
+	Resource    []Resource  `xml:"resource"`
+	Area        []Area      `xml:"area"`
+}
+
+// Parameter is a system-specific name/value pair.
+type Parameter struct {
+	ValueName string `xml:"valueName"`
+	Value     string `xml:"value"`
+}
+
+// Resource references a file associated with the alert.
+type Resource struct {
+	ResourceDesc string `xml:"resourceDesc"`
+	MimeType     string `xml:"mimeType"`
+	Size         int64  `xml:"size,omitempty"`
+	URI          string `xml:"uri,omitempty"`
+}
+
+// Area describes the geographic area the alert applies to.
+type Area struct {
+	AreaDesc string   `xml:"areaDesc"`
+	Circle   []string `xml:"circle"`
+}
+
+// FromEAT builds a CAP alert for eat. previous holds the earlier versions of
+// the same event; when present the alert is an Update referencing them.
+func FromEAT(eat *fastschema.EAT, previous []fastschema.EAT, opts Options) *Alert {
+	if opts.Sender == "" {
+		opts.Sender = DefaultSender
+	}
+	if opts.SenderName == "" {
+		opts.SenderName = DefaultSenderName
+	}
+
+	a := &Alert{
+		Identifier:  Identifier(eat.EventID, eat.Version),
+		Sender:      opts.Sender,
+		Sent:        formatTime(sentTime(eat)),
+		Status:      "Actual",
+		MsgType:     "Alert",
+		Scope:       "Restricted",
+		Restriction: "For NEMA and MAR stakeholders only",
+		Incidents:   Identifier(eat.EventID, 1),
+	}
+	if eat.Exercise {
+		a.Status = "Exercise"
+	}
+
+	var refs []string
+	for _, p := range previous {
+		if p.Version >= eat.Version {
+			continue
+		}
+		refs = append(refs, strings.Join([]string{opts.Sender, Identifier(p.EventID, p.Version), formatTime(sentTime(&p))}, ","))
+	}
+	if len(refs) > 0 {
+		a.MsgType = "Update"
+		a.References = strings.Join(refs, " ")
+	}
+	if eat.IsCancelled() {
+		a.MsgType = "Cancel"
+		a.Note = eat.CancelReason
+	}
+
+	info := Info{
+		Language:    "en-NZ",
+		Category:    []string{"Geo"},
+		Event:       "Earthquake",
+		Urgency:     "Immediate",
+		Severity:    Severity(eat),
+		Certainty:   Certainty(eat.Status),
+		SenderName:  opts.SenderName,
+		Headline:    eat.EventTitle,
+		Description: eat.EventComments,
+		Web:         eat.EarthquakeURL,
+		Parameter: []Parameter{
+			{ValueName: "EATVersion", Value: strconv.Itoa(eat.Version)},
+			{ValueName: "EATStatus", Value: eat.Status},
+			{ValueName: "Magnitude", Value: fmt.Sprintf("%.1f", eat.Magnitude)},
+			{ValueName: "BeachMarineThreat", Value: strconv.FormatBool(eat.BeachMarineThreat)},
+			{ValueName: "LandThreat", Value: strconv.FormatBool(eat.LandThreat)},
+			{ValueName: "TEPActivated", Value: strconv.FormatBool(eat.TEPActivated)},
+		},
+		Area: []Area{{AreaDesc: eat.Location}},
+	}
+	if !eat.EventDate.IsZero() {
+		info.Onset = formatTime(eat.EventDate)
+	}
+	if eat.HasEpicentre() {
+		// A zero radius circle is the epicentre point.
+		info.Area[0].Circle = []string{Circle(*eat.Latitude, *eat.Longitude, 0)}
+	}
+
+	for _, f := range eat.Attachments {
+		info.Resource = append(info.Resource, Resource{
+			ResourceDesc: f.Name,
+			MimeType:     f.Type,
+			Size:         f.Size,
+			URI:          f.URL,
+		})
+	}
+
+	a.Info = []Info{info}
+
+	return a
+}
+
+// Marshal returns the alert as an XML document.
+func (a *Alert) Marshal() ([]byte, error) {
+	b, err := xml.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal cap alert: %w", err)
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// Identifier returns the CAP identifier for an EAT version. It is keyed on the
+// event rather than the title, which can change between versions and be shared
+// by two events. The first version's identifier names the incident.
+func Identifier(eventID, version int) string {
+	return fmt.Sprintf("NZ.GeoNet.EAT.event-%d.v%d", eventID, version)
+}
+
+// Severity derives the CAP severity from the EAT threat flags.
+func Severity(eat *fastschema.EAT) string {
+	switch {
+	case eat.BeachMarineThreat && eat.LandThreat:
+		return "Extreme"
+	case eat.BeachMarineThreat || eat.LandThreat:
+		return "Severe"
+	case eat.TEPActivated:
+		return "Moderate"
+	default:
+		return "Minor"
+	}
+}
+
+// Certainty maps the EAT status to a CAP certainty.
+func Certainty(status string) string {
+	switch status {
+	case "confirmed":
+		return "Observed"
+	case "preliminary":
+		return "Likely"
+	default:
+		return "Unknown"
+	}
+}
+
+// Circle formats a CAP circle: a WGS 84 "lat,lon" point followed by a radius in km.
+func Circle(latitude, longitude, radiusKm float64) string {
+	return fmt.Sprintf("%.4f,%.4f %.1f", latitude, longitude, radiusKm)
+}
+
+func sentTime(eat *fastschema.EAT) time.Time {
+	if t := eat.PublishedAt(); !t.IsZero() {
+		return t
+	}
+	return time.Now()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
@@ -0,0 +1,247 @@
+package cap
+
+import (
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func testEAT() *fastschema.EAT {
+	return &fastschema.EAT{
+		ID:                3,
+		EventID:           4,
+		EventTitle:        "M5.0-Wellington-2026-01-15",
+		Location:          "Wellington",
+		EventDate:         time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC),
+		Magnitude:         5.0,
+		EarthquakeURL:     "https://www.geonet.org.nz/earthquake/2026p001",
+		Version:           2,
+		EventComments:     "Test earthquake event.",
+		BeachMarineThreat: true,
+		Status:            "confirmed",
+		Attachments: []fastschema.File{
+			{Name: "map.png", Type: "image/png", Size: 1024, URL: "https://example.com/map.png"},
+		},
+		CreatedAt: time.Date(2026, 1, 15, 11, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestFromEAT(t *testing.T) {
+	previous := []fastschema.EAT{
+		{EventID: 4, EventTitle: "M5.0-Wellington-2026-01-15", Version: 1, CreatedAt: time.Date(2026, 1, 15, 10, 40, 0, 0, time.UTC)},
+		{EventID: 4, EventTitle: "M5.0-Wellington-2026-01-15", Version: 2, CreatedAt: time.Date(2026, 1, 15, 11, 0, 0, 0, time.UTC)},
+	}
+
+	a := FromEAT(testEAT(), previous, Options{})
+
+	if a.Identifier != "NZ.GeoNet.EAT.event-4.v2" {
+		t.Errorf("unexpected identifier: %s", a.Identifier)
+	}
+	if a.Sent != "2026-01-15T11:00:00+00:00" {
+		t.Errorf("unexpected sent: %s", a.Sent)
+	}
+	if a.MsgType != "Update" {
+		t.Errorf("expected msgType Update, got %s", a.MsgType)
+	}
+	want := "eat@geonet.org.nz,NZ.GeoNet.EAT.event-4.v1,2026-01-15T10:40:00+00:00"
+	if a.References != want {
+		t.Errorf("unexpected references: %s", a.References)
+	}
+	if a.Incidents != "NZ.GeoNet.EAT.event-4.v1" {
+		t.Errorf("unexpected incidents: %s", a.Incidents)
+	}
+	if len(a.Info) != 1 {
+		t.Fatalf("expected 1 info, got %d", len(a.Info))
+	}
+	if a.Info[0].Severity != "Severe" {
+		t.Errorf("expected severity Severe, got %s", a.Info[0].Severity)
+	}
+	if a.Info[0].Certainty != "Observed" {
+		t.Errorf("expected certainty Observed, got %s", a.Info[0].Certainty)
+	}
+}
+
+func TestFromEAT_FirstVersion(t *testing.T) {
+	eat := testEAT()
+	eat.Version = 1
+
+	a := FromEAT(eat, nil, Options{})
+	if a.MsgType != "Alert" {
+		t.Errorf("expected msgType Alert, got %s", a.MsgType)
+	}
+	if a.References != "" {
+		t.Errorf("expected no references, got %s", a.References)
+	}
+}
+
+func TestFromEAT_Epicentre(t *testing.T) {
+	eat := testEAT()
+	if a := FromEAT(eat, nil, Options{}); len(a.Info[0].Area[0].Circle) != 0 {
+		t.Errorf("expected no circle without an epicentre, got %v", a.Info[0].Area[0].Circle)
+	}
+
+	lat, lon := -41.28664, 174.77557
+	eat.Latitude, eat.Longitude = &lat, &lon
+	area := FromEAT(eat, nil, Options{}).Info[0].Area[0]
+	if area.AreaDesc != "Wellington" || len(area.Circle) != 1 || area.Circle[0] != "-41.2866,174.7756 0.0" {
+		t.Errorf("expected the epicentre as a circle, got %+v", area)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		eat      fastschema.EAT
+		expected string
+	}{
+		{fastschema.EAT{}, "Minor"},
+		{fastschema.EAT{TEPActivated: true}, "Moderate"},
+		{fastschema.EAT{LandThreat: true}, "Severe"},
+		{fastschema.EAT{BeachMarineThreat: true}, "Severe"},
+		{fastschema.EAT{BeachMarineThreat: true, LandThreat: true}, "Extreme"},
+	}
+
+	for _, tt := range tests {
+		if got := Severity(&tt.eat); got != tt.expected {
+			t.Errorf("Severity(%+v) = %s, want %s", tt.eat, got, tt.expected)
+		}
+	}
+}
+
+func TestFromEAT_RevisedTitle(t *testing.T) {
+	eat := testEAT()
+	eat.Magnitude = 5.3
+	eat.EventTitle = "M5.3-Wellington-2026-01-15"
+	previous := []fastschema.EAT{
+		{EventID: 4, EventTitle: "M5.0-Wellington-2026-01-15", Version: 1, CreatedAt: time.Date(2026, 1, 15, 10, 40, 0, 0, time.UTC)},
+	}
+
+	a := FromEAT(eat, previous, Options{})
+
+	// The identifiers follow the event, not the revised title.
+	if a.Identifier != "NZ.GeoNet.EAT.event-4.v2" {
+		t.Errorf("unexpected identifier: %s", a.Identifier)
+	}
+	if a.Incidents != "NZ.GeoNet.EAT.event-4.v1" {
+		t.Errorf("expected the incident named after version 1, got %s", a.Incidents)
+	}
+	if a.References != "eat@geonet.org.nz,NZ.GeoNet.EAT.event-4.v1,2026-01-15T10:40:00+00:00" {
+		t.Errorf("unexpected references: %s", a.References)
+	}
+
+	// Another event with the same title has its own identifiers.
+	other := testEAT()
+	other.EventID = 5
+	if a := FromEAT(other, nil, Options{}); a.Identifier != "NZ.GeoNet.EAT.event-5.v2" || a.Incidents != "NZ.GeoNet.EAT.event-5.v1" {
+		t.Errorf("expected identifiers of event 5, got %s and %s", a.Identifier, a.Incidents)
+	}
+}
+
+func TestFromEAT_Cancelled(t *testing.T) {
+	eat := testEAT()
+	eat.Status = fastschema.StatusCancelled
+	eat.CancelReason = "Issued for the wrong event."
+	previous := []fastschema.EAT{
+		{EventTitle: "M5.0-Wellington-2026-01-15", Version: 1, CreatedAt: time.Date(2026, 1, 15, 10, 40, 0, 0, time.UTC)},
+	}
+
+	a := FromEAT(eat, previous, Options{})
+
+	if a.MsgType != "Cancel" {
+		t.Errorf("expected msgType Cancel, got %s", a.MsgType)
+	}
+	if a.Note != eat.CancelReason {
+		t.Errorf("expected the reason as the note, got %q", a.Note)
+	}
+	if a.References == "" {
+		t.Error("expected a cancellation to reference the alerts it cancels")
+	}
+}
+
+func TestFromEAT_Exercise(t *testing.T) {
+	eat := testEAT()
+	if a := FromEAT(eat, nil, Options{}); a.Status != "Actual" {
+		t.Errorf("expected status Actual, got %s", a.Status)
+	}
+
+	eat.Exercise = true
+	if a := FromEAT(eat, nil, Options{}); a.Status != "Exercise" {
+		t.Errorf("expected status Exercise, got %s", a.Status)
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	if got := Identifier(12, 4); got != "NZ.GeoNet.EAT.event-12.v4" {
+		t.Errorf("unexpected identifier: %s", got)
+	}
+}
+
+func TestCircle(t *testing.T) {
+	if got := Circle(-41.28664, 174.77557, 50); got != "-41.2866,174.7756 50.0" {
+		t.Errorf("unexpected circle: %s", got)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	b, err := FromEAT(testEAT(), nil, Options{}).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var a Alert
+	if err := xml.Unmarshal(b, &a); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if a.XMLName.Space != Namespace {
+		t.Errorf("unexpected namespace: %s", a.XMLName.Space)
+	}
+}
+
+// TestSchemaValidation validates generated alerts against the official CAP 1.2
+// XSD. It needs xmllint and is skipped when that isn't installed.
+func TestSchemaValidation(t *testing.T) {
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint not installed")
+	}
+
+	minimal := &fastschema.EAT{
+		EventTitle: "M3.0-Test-2026-01-01",
+		Location:   "Test",
+		Magnitude:  3.0,
+		Version:    1,
+		Status:     "preliminary",
+	}
+
+	tests := []struct {
+		name     string
+		eat      *fastschema.EAT
+		previous []fastschema.EAT
+	}{
+		{"alert", minimal, nil},
+		{"update", testEAT(), []fastschema.EAT{{EventTitle: "M5.0-Wellington-2026-01-15", Version: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := FromEAT(tt.eat, tt.previous, Options{}).Marshal()
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			f := filepath.Join(t.TempDir(), "alert.xml")
+			if err := os.WriteFile(f, b, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := exec.Command(xmllint, "--noout", "--schema", filepath.Join("testdata", "CAP-v1.2.xsd"), f).CombinedOutput()
+			if err != nil {
+				t.Errorf("schema validation failed: %v\n%s\n%s", err, out, b)
+			}
+		})
+	}
+}
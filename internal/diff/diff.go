@@ -0,0 +1,154 @@
+// Package diff compares two versions of an EAT, so recipients can see at a
+// glance what an update changed: the fields that differ, a word level diff of
+// the event comments and the attachments added or removed.
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/format"
+)
+
+// Field is an EAT field in both versions, as displayed.
+type Field struct {
+	Name    string `json:"name"`  // JSON name of the EAT field, e.g. "land_threat"
+	Label   string `json:"label"` // e.g. "Land Threat"
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Changed bool   `json:"changed"`
+}
+
+// Diff is the difference between two versions of an event's EAT.
+type Diff struct {
+	EventID  int               `json:"event_id"`
+	From     int               `json:"from"` // version compared from
+	To       int               `json:"to"`   // version compared to
+	Fields   []Field           `json:"fields"`
+	Comments []Span            `json:"comments"` // word level diff of the event comments
+	Added    []fastschema.File `json:"attachments_added"`
+	Removed  []fastschema.File `json:"attachments_removed"`
+}
+
+// Compare returns the difference from one version of an EAT to another.
+func Compare(from, to *fastschema.EAT) *Diff {
+	d := &Diff{
+		EventID:  to.EventID,
+		From:     from.Version,
+		To:       to.Version,
+		Comments: Words(from.EventComments, to.EventComments),
+	}
+
+	add := func(name, label, a, b string) {
+		d.Fields = append(d.Fields, Field{Name: name, Label: label, From: a, To: b, Changed: a != b})
+	}
+	add("event_title", "Event Title", from.EventTitle, to.EventTitle)
+	add("status", "Status", from.Status, to.Status)
+	if from.CancelReason != "" || to.CancelReason != "" {
+		add("cancel_reason", "Reason for Cancellation", from.CancelReason, to.CancelReason)
+	}
+	add("location", "Location", from.Location, to.Location)
+	add("event_date", "Event Date (UTC)", format.Date(from.EventDate), format.Date(to.EventDate))
+	add("magnitude", "Magnitude", format.Magnitude(from.Magnitude), format.Magnitude(to.Magnitude))
+	add("epicentre", "Epicentre", epicentre(from), epicentre(to))
+	add("depth", "Depth", depth(from), depth(to))
+	add("earthquake_url", "Earthquake Info", from.EarthquakeURL, to.EarthquakeURL)
+	add("beach_marine_threat", "Beach and Marine Threat", format.YesNo(from.BeachMarineThreat), format.YesNo(to.BeachMarineThreat))
+	add("land_threat", "Land Threat", format.YesNo(from.LandThreat), format.YesNo(to.LandThreat))
+	add("tep_activated", "TEP Activated", format.YesNo(from.TEPActivated), format.YesNo(to.TEPActivated))
+
+	// Attachments are the same file if they have the same ID.
+	fromFiles := make(map[string]bool)
+	for _, f := range from.Attachments {
+		fromFiles[fileKey(f)] = true
+	}
+	toFiles := make(map[string]bool)
+	for _, f := range to.Attachments {
+		toFiles[fileKey(f)] = true
+		if !fromFiles[fileKey(f)] {
+			d.Added = append(d.Added, f)
+		}
+	}
+	for _, f := range from.Attachments {
+		if !toFiles[fileKey(f)] {
+			d.Removed = append(d.Removed, f)
+		}
+	}
+
+	return d
+}
+
+// fileKey identifies an attachment, by name for any saved without an ID.
+func fileKey(f fastschema.File) string {
+	if f.ID != 0 {
+		return fmt.Sprintf("id:%d", f.ID)
+	}
+	return "name:" + f.Name
+}
+
+func epicentre(eat *fastschema.EAT) string {
+	if !eat.HasEpicentre() {
+		return "N/A"
+	}
+	return format.Epicentre(*eat.Latitude, *eat.Longitude)
+}
+
+func depth(eat *fastschema.EAT) string {
+	if eat.Depth == nil {
+		return "N/A"
+	}
+	return format.Depth(*eat.Depth)
+}
+
+// Changed returns the fields that differ.
+func (d *Diff) Changed() []Field {
+	var changed []Field
+	for _, f := range d.Fields {
+		if f.Changed {
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
+
+// CommentsChanged reports whether the event comments differ.
+func (d *Diff) CommentsChanged() bool {
+	for _, s := range d.Comments {
+		if s.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty reports whether the versions are the same.
+func (d *Diff) Empty() bool {
+	return len(d.Changed()) == 0 && !d.CommentsChanged() && len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// Summary describes the changes in a line each, e.g. "Status: preliminary →
+// confirmed", for the email and PDF.
+func (d *Diff) Summary() []string {
+	var lines []string
+	for _, f := range d.Changed() {
+		lines = append(lines, fmt.Sprintf("%s: %s → %s", f.Label, orNone(f.From), orNone(f.To)))
+	}
+	if d.CommentsChanged() {
+		lines = append(lines, "Event comments revised")
+	}
+	for _, f := range d.Added {
+		lines = append(lines, "Attachment added: "+f.Name)
+	}
+	for _, f := range d.Removed {
+		lines = append(lines, "Attachment removed: "+f.Name)
+	}
+	return lines
+}
+
+func orNone(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestCompare(t *testing.T) {
+	lat, lon := -41.29, 174.78
+	from := &fastschema.EAT{
+		EventID:       4,
+		EventTitle:    "M5.0-Wellington-2026-01-15",
+		Version:       3,
+		Status:        "preliminary",
+		Location:      "Wellington",
+		EventDate:     time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC),
+		Magnitude:     5.0,
+		EventComments: "Stay away from the coast.",
+		Attachments: []fastschema.File{
+			{ID: 7, Name: "map.png"},
+			{ID: 8, Name: "bulletin.pdf"},
+		},
+	}
+	to := *from
+	to.Version = 4
+	to.Status = "confirmed"
+	to.LandThreat = true
+	to.Latitude, to.Longitude = &lat, &lon
+	to.EventComments = "Stay well away from the coast."
+	to.Attachments = []fastschema.File{
+		{ID: 7, Name: "map.png"},
+		{ID: 9, Name: "bulletin-v2.pdf"},
+	}
+
+	d := Compare(from, &to)
+
+	if d.EventID != 4 || d.From != 3 || d.To != 4 {
+		t.Errorf("unexpected versions %+v", d)
+	}
+
+	want := []Field{
+		{Name: "status", Label: "Status", From: "preliminary", To: "confirmed", Changed: true},
+		{Name: "epicentre", Label: "Epicentre", From: "N/A", To: "41.29°S 174.78°E", Changed: true},
+		{Name: "land_threat", Label: "Land Threat", From: "No", To: "Yes", Changed: true},
+	}
+	if got := d.Changed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected changes %+v, got %+v", want, got)
+	}
+	if len(d.Fields) != 11 {
+		t.Errorf("expected every field side by side, got %d", len(d.Fields))
+	}
+
+	if !d.CommentsChanged() {
+		t.Error("expected the comments to have changed")
+	}
+	if len(d.Added) != 1 || d.Added[0].Name != "bulletin-v2.pdf" {
+		t.Errorf("unexpected added attachments %+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Name != "bulletin.pdf" {
+		t.Errorf("unexpected removed attachments %+v", d.Removed)
+	}
+
+	summary := strings.Join(d.Summary(), "\n")
+	wantSummary := `Status: preliminary → confirmed
+Epicentre: N/A → 41.29°S 174.78°E
+Land Threat: No → Yes
+Event comments revised
+Attachment added: bulletin-v2.pdf
+Attachment removed: bulletin.pdf`
+	if summary != wantSummary {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+}
+
+func TestCompareSame(t *testing.T) {
+	eat := &fastschema.EAT{Version: 1, Status: "confirmed", EventComments: "No change."}
+	d := Compare(eat, eat)
+
+	if !d.Empty() || len(d.Summary()) != 0 {
+		t.Errorf("expected no changes, got %v", d.Summary())
+	}
+	for _, f := range d.Fields {
+		if f.Name == "cancel_reason" {
+			t.Error("expected no cancellation reason unless a version is cancelled")
+		}
+	}
+}
+
+func TestCompareCancelled(t *testing.T) {
+	from := &fastschema.EAT{Version: 1, Status: "confirmed"}
+	to := &fastschema.EAT{Version: 2, Status: fastschema.StatusCancelled, CancelReason: "Issued in error."}
+
+	got := Compare(from, to).Summary()
+	want := []string{"Status: confirmed → cancelled", "Reason for Cancellation: (none) → Issued in error."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
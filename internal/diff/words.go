@@ -0,0 +1,113 @@
+package diff
+
+import "regexp"
+
+// Op is what happened to a span of text.
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Span is a run of text that is in both versions, or only in one.
+type Span struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxCells bounds the table used to compare the words that differ. Comments
+// that change more than this are shown as replaced outright.
+const maxCells = 4 << 20
+
+// tokens matches words and the whitespace between them, so the diff keeps
+// line breaks and spacing.
+var tokens = regexp.MustCompile(`\s+|\S+`)
+
+// Words returns a word level diff of from and to. Concatenating the Equal and
+// Delete spans gives from; the Equal and Insert spans give to.
+func Words(from, to string) []Span {
+	a := tokens.FindAllString(from, -1)
+	b := tokens.FindAllString(to, -1)
+
+	// Most revisions touch a few words, so trim what's unchanged at each end
+	// before comparing the rest.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var spans []Span
+	add := func(op Op, text string) {
+		if n := len(spans); n > 0 && spans[n-1].Op == op {
+			spans[n-1].Text += text
+			return
+		}
+		spans = append(spans, Span{Op: op, Text: text})
+	}
+
+	for _, t := range a[:pre] {
+		add(Equal, t)
+	}
+	middle(a[pre:len(a)-suf], b[pre:len(b)-suf], add)
+	for _, t := range a[len(a)-suf:] {
+		add(Equal, t)
+	}
+
+	return spans
+}
+
+// middle diffs a and b by their longest common subsequence of tokens.
+func middle(a, b []string, add func(Op, string)) {
+	if (len(a)+1)*(len(b)+1) > maxCells {
+		for _, t := range a {
+			add(Delete, t)
+		}
+		for _, t := range b {
+			add(Insert, t)
+		}
+		return
+	}
+
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add(Equal, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(Delete, a[i])
+			i++
+		default:
+			add(Insert, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add(Delete, a[i])
+	}
+	for ; j < len(b); j++ {
+		add(Insert, b[j])
+	}
+}
@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     []Span
+	}{
+		{"same", "No threat.", "No threat.", []Span{{Equal, "No threat."}}},
+		{"both empty", "", "", nil},
+		{"added", "", "Stay clear.", []Span{{Insert, "Stay clear."}}},
+		{"removed", "Stay clear.", "", []Span{{Delete, "Stay clear."}}},
+		{"inserted word", "Stay away from the coast.", "Stay well away from the coast.",
+			[]Span{{Equal, "Stay "}, {Insert, "well "}, {Equal, "away from the coast."}}},
+		{"replaced word", "Threat to beaches on the east coast.", "Threat to beaches on the west coast.",
+			[]Span{{Equal, "Threat to beaches on the "}, {Delete, "east"}, {Insert, "west"}, {Equal, " coast."}}},
+		{"new line", "Line one.", "Line one.\nLine two.",
+			[]Span{{Equal, "Line one."}, {Insert, "\nLine two."}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.from, tt.to)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+			checkSpans(t, got, tt.from, tt.to)
+		})
+	}
+}
+
+func TestWordsLarge(t *testing.T) {
+	from := strings.Repeat("alpha beta ", 2000)
+	to := strings.Repeat("gamma delta ", 2000)
+
+	checkSpans(t, Words(from, to), from, to)
+}
+
+// checkSpans checks the spans rebuild both versions.
+func checkSpans(t *testing.T, spans []Span, from, to string) {
+	t.Helper()
+
+	var a, b strings.Builder
+	for _, s := range spans {
+		if s.Op != Insert {
+			a.WriteString(s.Text)
+		}
+		if s.Op != Delete {
+			b.WriteString(s.Text)
+		}
+	}
+	if a.String() != from {
+		t.Errorf("spans don't rebuild from: %q", a.String())
+	}
+	if b.String() != to {
+		t.Errorf("spans don't rebuild to: %q", b.String())
+	}
+}
@@ -0,0 +1,39 @@
+package email
+
+import (
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// DefaultMaxAttachmentSize is the default cap on the total size of the EAT
+// attachments sent with an email, leaving room under common 25MB server
+// limits for the PDF and base64 encoding.
+const DefaultMaxAttachmentSize = 10 << 20
+
+// Attachment is an EAT attachment included in the email. Files without Data
+// are linked rather than attached.
+type Attachment struct {
+	File fastschema.File
+	Data []byte
+}
+
+// FetchAttachments fetches files, in order, to attach to the email while
+// their total size stays within max bytes. Files that don't fit, or can't be
+// fetched, are linked instead. fetch is called with the number of bytes left
+// and should fail if the file is bigger.
+func FetchAttachments(files []fastschema.File, max int64, fetch func(f fastschema.File, max int64) ([]byte, error)) []Attachment {
+	attachments := make([]Attachment, len(files))
+	left := max
+	for i, f := range files {
+		attachments[i].File = f
+		if f.Size > left || left <= 0 {
+			continue
+		}
+		data, err := fetch(f, left)
+		if err != nil {
+			continue
+		}
+		attachments[i].Data = data
+		left -= int64(len(data))
+	}
+	return attachments
+}
@@ -0,0 +1,105 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestFetchAttachments(t *testing.T) {
+	content := map[string]string{
+		"map.png":      strings.Repeat("m", 40),
+		"bulletin.pdf": strings.Repeat("b", 50),
+		"notes.txt":    strings.Repeat("n", 20),
+		"unsized.gif":  strings.Repeat("u", 35),
+		"broken.jpg":   "",
+	}
+	fetch := func(f fastschema.File, max int64) ([]byte, error) {
+		if f.Name == "broken.jpg" {
+			return nil, errors.New("object store unavailable")
+		}
+		b := content[f.Name]
+		if int64(len(b)) > max {
+			return nil, fastschema.ErrTooLarge
+		}
+		return []byte(b), nil
+	}
+
+	files := []fastschema.File{
+		{Name: "map.png", Size: 40},
+		{Name: "broken.jpg", Size: 10},
+		{Name: "bulletin.pdf", Size: 50}, // doesn't fit after map.png, not fetched
+		{Name: "unsized.gif"},            // size unknown, too large once fetched
+		{Name: "notes.txt", Size: 20},
+	}
+
+	attachments := FetchAttachments(files, 70, fetch)
+
+	var attached, linked []string
+	for _, a := range attachments {
+		if a.Data != nil {
+			attached = append(attached, a.File.Name)
+		} else {
+			linked = append(linked, a.File.Name)
+		}
+	}
+	if strings.Join(attached, ",") != "map.png,notes.txt" {
+		t.Errorf("unexpected attached files: %v", attached)
+	}
+	if strings.Join(linked, ",") != "broken.jpg,bulletin.pdf,unsized.gif" {
+		t.Errorf("unexpected linked files: %v", linked)
+	}
+
+	for _, a := range FetchAttachments(files, 0, fetch) {
+		if a.Data != nil {
+			t.Errorf("expected %s to be linked with no size allowance", a.File.Name)
+		}
+	}
+}
+
+func TestMessageAttachments(t *testing.T) {
+	eat := &fastschema.EAT{EventID: 1, EventTitle: "M5.0-Wellington-2026-01-01", Version: 1}
+	attachments := []Attachment{
+		{File: fastschema.File{Name: "map.png", Type: "image/png"}, Data: []byte("PNG")},
+		{File: fastschema.File{ID: 8, Name: "bulletin.pdf", Type: "application/pdf", URL: "/files/bulletin.pdf"}},
+	}
+
+	msg, err := Message(Config{FromAddr: "eat@example.com", PublicURL: "https://portal.example.com"}, eat, []byte("%PDF-1.4"), attachments, nil, []string{"a@b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, html, files := parts(t, msg)
+
+	if strings.Join(files, ",") != "application/pdf,image/png" {
+		t.Errorf("expected the PDF and map.png attached, got %v", files)
+	}
+	if !strings.Contains(string(msg), `Content-Disposition: attachment; filename=map.png`) {
+		t.Error("expected map.png attachment filename")
+	}
+
+	if !strings.Contains(text, "Attached:\n  map.png\n") {
+		t.Errorf("expected attached file listed in text body:\n%s", text)
+	}
+	// Linked files are downloaded through the dashboard, as the FastSchema
+	// URL can't be reached by recipients.
+	if !strings.Contains(text, "  bulletin.pdf: https://portal.example.com/dashboard/attachment?event=1&version=1&file=8\n") {
+		t.Errorf("expected absolute link in text body:\n%s", text)
+	}
+	if !strings.Contains(html, `<a href="https://portal.example.com/dashboard/attachment?event=1&amp;version=1&amp;file=8">bulletin.pdf</a>`) {
+		t.Errorf("expected absolute link in HTML body:\n%s", html)
+	}
+
+	// Without PUBLIC_URL there's nothing recipients could follow.
+	if msg, err = Message(Config{FromAddr: "eat@example.com"}, eat, nil, attachments, nil, []string{"a@b.com"}); err != nil {
+		t.Fatal(err)
+	}
+	text, html, _ = parts(t, msg)
+	if strings.Contains(text, "/files/") || strings.Contains(html, "/files/") {
+		t.Error("expected no FastSchema link")
+	}
+	if !strings.Contains(text, "Attachments not included in this email:\n  bulletin.pdf") {
+		t.Errorf("expected the linked file named in text body:\n%s", text)
+	}
+}
@@ -0,0 +1,344 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// rfc8463Message is the signed example message from RFC 8463 appendix A.3,
+// signed with both the Ed25519 and the RSA keys from appendix A.
+const rfc8463Message = "DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed;\r\n" +
+	" d=football.example.com; i=@football.example.com;\r\n" +
+	" q=dns/txt; s=brisbane; t=1528637909; h=from : to :\r\n" +
+	" subject : date : message-id : from : subject : date;\r\n" +
+	" bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+	" b=/gCrinpcQOoIfuHNQIbq4pgh9kyIK3AQUdt9OdqQehSwhEIug4D11Bus\r\n" +
+	" Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==\r\n" +
+	"DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed;\r\n" +
+	" d=football.example.com; i=@football.example.com;\r\n" +
+	" q=dns/txt; s=test; t=1528637909; h=from : to : subject :\r\n" +
+	" date : message-id : from : subject : date;\r\n" +
+	" bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+	" b=F45dVWDfMbQDGHJFlXUNB2HKfbCeLRyhDXgFpEL8GwpsRe0IeIixNTe3\r\n" +
+	" DhCVlUrSjV4BwcVcOF6+FF3Zo9Rpo1tFOeS9mPYQTnGdaSGsgeefOsk2Jz\r\n" +
+	" dA+L10TeYt9BgDfQNZtKdN1WO//KgIqXP7OdEFE4LjFYNcUxZQ4FADY+8=\r\n" +
+	"From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+	"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game.  Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe.\r\n"
+
+// Public keys from the brisbane._domainkey and test._domainkey records in
+// RFC 8463 appendix A.2.
+const (
+	rfc8463Ed25519Key = "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="
+	rfc8463RSAKey     = "MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQDkHlOQoBTzWRiGs5V6NpP3idY6Wk08a5qhdR6wy5bdOKb2jLQiY/J16JYi0Qvx/byYzCNb3W91y3FutACDfzwQ/BC/e/8uBsCR+yz1Lxj+PL6lHvqMKrM3rG4hstT5QjvHO9PzoxZyVYLzBfO2EeC3Ip3G+2kryOTIKT+l/K4w3QIDAQAB"
+)
+
+// rfc8463Keys looks up the RFC 8463 example public keys by selector.
+func rfc8463Keys(t *testing.T) map[string]crypto.PublicKey {
+	t.Helper()
+
+	ed, err := base64.StdEncoding.DecodeString(rfc8463Ed25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := base64.StdEncoding.DecodeString(rfc8463RSAKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]crypto.PublicKey{
+		"brisbane": ed25519.PublicKey(ed),
+		"test":     rsaKey,
+	}
+}
+
+var bTag = regexp.MustCompile(`([;:]\s*b=)[^;]*`)
+
+// verifyDKIM checks every DKIM-Signature header field in msg, looking up
+// public keys by selector. It's an independent, minimal RFC 6376 verifier
+// that only supports relaxed/relaxed canonicalization.
+func verifyDKIM(msg []byte, keys map[string]crypto.PublicKey) (int, error) {
+	header, body, ok := bytes.Cut(msg, []byte("\r\n\r\n"))
+	if !ok {
+		return 0, errors.New("no body")
+	}
+	fields := splitHeader(header)
+
+	var verified int
+	for _, sigField := range fields {
+		if !strings.EqualFold(fieldName(sigField), "DKIM-Signature") {
+			continue
+		}
+
+		tags := make(map[string]string)
+		_, value, _ := strings.Cut(sigField, ":")
+		for _, tag := range strings.Split(value, ";") {
+			k, v, _ := strings.Cut(tag, "=")
+			tags[strings.TrimSpace(k)] = strings.Join(strings.Fields(v), "")
+		}
+		if tags["c"] != "relaxed/relaxed" {
+			return verified, fmt.Errorf("unsupported canonicalization %s", tags["c"])
+		}
+
+		bh := sha256.Sum256(relaxedBody(body))
+		if base64.StdEncoding.EncodeToString(bh[:]) != tags["bh"] {
+			return verified, fmt.Errorf("selector %s: body hash mismatch", tags["s"])
+		}
+
+		h := sha256.New()
+		used := make(map[int]bool)
+		for _, name := range strings.Split(tags["h"], ":") {
+			for i := len(fields) - 1; i >= 0; i-- {
+				if !used[i] && strings.EqualFold(fieldName(fields[i]), name) {
+					used[i] = true
+					h.Write([]byte(relaxedHeader(fields[i])))
+					break
+				}
+			}
+		}
+		unsigned := bTag.ReplaceAllString(sigField, "$1")
+		h.Write([]byte(strings.TrimSuffix(relaxedHeader(unsigned), "\r\n")))
+		digest := h.Sum(nil)
+
+		sig, err := base64.StdEncoding.DecodeString(tags["b"])
+		if err != nil {
+			return verified, err
+		}
+
+		switch pub := keys[tags["s"]].(type) {
+		case ed25519.PublicKey:
+			if tags["a"] != "ed25519-sha256" || !ed25519.Verify(pub, digest, sig) {
+				return verified, fmt.Errorf("selector %s: bad signature", tags["s"])
+			}
+		case *rsa.PublicKey:
+			if tags["a"] != "rsa-sha256" {
+				return verified, fmt.Errorf("selector %s: bad algorithm", tags["s"])
+			}
+			if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig); err != nil {
+				return verified, fmt.Errorf("selector %s: %w", tags["s"], err)
+			}
+		default:
+			return verified, fmt.Errorf("no key for selector %s", tags["s"])
+		}
+		verified++
+	}
+
+	return verified, nil
+}
+
+func TestVerifyRFC8463(t *testing.T) {
+	keys := rfc8463Keys(t)
+
+	n, err := verifyDKIM([]byte(rfc8463Message), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 signatures verified, got %d", n)
+	}
+
+	tampered := strings.Replace(rfc8463Message, "Is dinner ready?", "Is lunch ready?", 1)
+	if _, err := verifyDKIM([]byte(tampered), keys); err == nil {
+		t.Error("expected tampered message to fail verification")
+	}
+}
+
+// TestCanonicalization checks the relaxed examples in RFC 6376 section 3.4.5.
+func TestCanonicalization(t *testing.T) {
+	header := "A: X\r\nB : Y\t\r\n\tZ  \r\n"
+	var got string
+	for _, f := range splitHeader([]byte(strings.TrimSuffix(header, "\r\n"))) {
+		got += relaxedHeader(f)
+	}
+	if want := "a:X\r\nb:Y Z\r\n"; got != want {
+		t.Errorf("relaxed header: expected %q, got %q", want, got)
+	}
+
+	body := " C \r\nD \t E\r\n\r\n\r\n"
+	if got, want := string(relaxedBody([]byte(body))), " C\r\nD E\r\n"; got != want {
+		t.Errorf("relaxed body: expected %q, got %q", want, got)
+	}
+
+	if got := relaxedBody([]byte("\r\n\r\n")); len(got) != 0 {
+		t.Errorf("expected empty body, got %q", got)
+	}
+}
+
+func TestSign(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eat := &fastschema.EAT{
+		EventTitle:    "M7.1-Kermadec Islands-2026-01-15",
+		Version:       3,
+		Status:        "confirmed",
+		EventComments: "Stay out of the water at Ōtaki.   Really.",
+	}
+
+	for _, tt := range []struct {
+		alg string
+		key crypto.Signer
+		pub crypto.PublicKey
+	}{
+		{"ed25519-sha256", edKey, edKey.Public()},
+		{"rsa-sha256", rsaKey, rsaKey.Public()},
+	} {
+		t.Run(tt.alg, func(t *testing.T) {
+			cfg := Config{
+				FromAddr: "eat@example.com",
+				DKIM:     &DKIM{Domain: "example.com", Selector: "eat", Key: tt.key},
+			}
+			msg, err := Message(cfg, eat, []byte("%PDF-1.4"), nil, nil, []string{"a@example.net"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.HasPrefix(msg, []byte("DKIM-Signature: v=1; a="+tt.alg+"; c=relaxed/relaxed; d=example.com; s=eat;\r\n")) {
+				t.Errorf("unexpected signature header:\n%s", msg[:200])
+			}
+			if !bytes.Contains(msg, []byte(" h=from:to:subject:date:message-id:mime-version:content-type;")) {
+				t.Error("expected the default header fields to be signed")
+			}
+
+			keys := map[string]crypto.PublicKey{"eat": tt.pub}
+			if n, err := verifyDKIM(msg, keys); err != nil || n != 1 {
+				t.Fatalf("expected signature to verify, got %d, %v", n, err)
+			}
+
+			// Relaxed canonicalization tolerates whitespace changes in transit
+			// but not changes to content.
+			rewrapped := bytes.Replace(msg, []byte("Subject: EAT:"), []byte("Subject:  EAT:"), 1)
+			if _, err := verifyDKIM(rewrapped, keys); err != nil {
+				t.Errorf("expected whitespace change to verify: %v", err)
+			}
+			tampered := bytes.Replace(msg, []byte("Version 3"), []byte("Version 4"), 1)
+			if _, err := verifyDKIM(tampered, keys); err == nil {
+				t.Error("expected tampered message to fail verification")
+			}
+		})
+	}
+}
+
+func TestParseDKIMKey(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+
+	pkcs8 := func(k interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	for name, b := range map[string][]byte{
+		"ed25519": pkcs8(edKey),
+		"rsa":     pkcs8(rsaKey),
+		"pkcs1":   pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+	} {
+		if _, err := ParseDKIMKey(b); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if _, err := ParseDKIMKey([]byte("not a key")); err == nil {
+		t.Error("expected error for non PEM key")
+	}
+}
+
+func TestSignTime(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	d := &DKIM{Domain: "example.com", Selector: "eat", Key: key}
+
+	msg, err := d.Sign([]byte("From: a@example.com\nSubject: hi\n\nhello\n"), time.Unix(1528637909, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(msg, []byte(" t=1528637909; h=from:subject;")) {
+		t.Errorf("unexpected signature:\n%s", msg)
+	}
+	if !bytes.HasSuffix(msg, []byte("From: a@example.com\r\nSubject: hi\r\n\r\nhello\r\n")) {
+		t.Error("expected message with CRLF line endings")
+	}
+
+	if _, err := d.Sign([]byte("From: a@example.com"), time.Now()); err == nil {
+		t.Error("expected error for message without a body")
+	}
+}
+
+func TestConfigFromEnvDKIM(t *testing.T) {
+	for _, k := range []string{"SMTP_HOST", "SMTP_FROM", "SMTP_RECIPIENTS", "DKIM_DOMAIN", "DKIM_SELECTOR", "DKIM_KEY_FILE"} {
+		orig := os.Getenv(k)
+		defer os.Setenv(k, orig)
+	}
+	os.Setenv("SMTP_HOST", "smtp.example.com")
+	os.Setenv("SMTP_FROM", "eat@example.com")
+	os.Setenv("SMTP_RECIPIENTS", "a@b.com")
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "dkim.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("DKIM_DOMAIN", "example.com")
+	os.Setenv("DKIM_SELECTOR", "eat")
+	os.Setenv("DKIM_KEY_FILE", keyFile)
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DKIM == nil || cfg.DKIM.Domain != "example.com" || cfg.DKIM.Selector != "eat" {
+		t.Errorf("unexpected DKIM config: %+v", cfg.DKIM)
+	}
+
+	os.Setenv("DKIM_SELECTOR", "")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("expected error for partial DKIM config")
+	}
+
+	os.Setenv("DKIM_DOMAIN", "")
+	os.Setenv("DKIM_KEY_FILE", "")
+	if cfg, err := ConfigFromEnv(); err != nil || cfg.DKIM != nil {
+		t.Errorf("expected no DKIM signing, got %+v, %v", cfg.DKIM, err)
+	}
+}
@@ -0,0 +1,66 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// File delivers messages by writing each as an .eml file in a Maildir under
+// Dir, so local development and tests can see exactly what would have been
+// sent without an SMTP server. Messages are written to Dir/tmp and moved to
+// Dir/new once complete.
+type File struct {
+	Dir string
+}
+
+var fileSeq atomic.Int64
+
+// Send writes msg to the Maildir with Return-Path and Delivered-To headers
+// recording the envelope, as a local delivery agent would.
+func (t *File) Send(from string, to []string, msg []byte) []Result {
+	path, err := t.write(from, to, msg)
+
+	results := make([]Result, len(to))
+	for i, rcpt := range to {
+		results[i] = Result{Recipient: rcpt, Outcome: Accepted, Message: path}
+		if err != nil {
+			results[i].Outcome, results[i].Message = Deferred, err.Error()
+		}
+	}
+	return results
+}
+
+func (t *File) write(from string, to []string, msg []byte) (string, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(t.Dir, sub), 0o755); err != nil {
+			return "", fmt.Errorf("maildir: %w", err)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Return-Path: <%s>\r\n", from)
+	for _, rcpt := range to {
+		fmt.Fprintf(&b, "Delivered-To: %s\r\n", rcpt)
+	}
+	b.Write(msg)
+
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%d.P%dQ%d.%s.eml", time.Now().UnixNano(), os.Getpid(), fileSeq.Add(1), host)
+
+	tmp := filepath.Join(t.Dir, "tmp", name)
+	if err := os.WriteFile(tmp, b.Bytes(), 0o644); err != nil {
+		return "", fmt.Errorf("maildir: %w", err)
+	}
+
+	path := filepath.Join(t.Dir, "new", name)
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("maildir: %w", err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,150 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// Routing decides which recipient groups receive an EAT.
+type Routing struct {
+	Groups []Group `json:"groups"`
+}
+
+// Group is a named list of recipients that receive EATs matching When.
+type Group struct {
+	Name       string   `json:"name"`
+	Recipients []string `json:"recipients"`
+	When       *Rule    `json:"when,omitempty"` // nil matches every EAT
+}
+
+// Rule is a predicate over EAT fields. Every condition that is set must hold,
+// and when Any is set at least one of its rules must match too.
+type Rule struct {
+	BeachMarineThreat *bool    `json:"beach_marine_threat,omitempty"`
+	LandThreat        *bool    `json:"land_threat,omitempty"`
+	TEPActivated      *bool    `json:"tep_activated,omitempty"`
+	Status            []string `json:"status,omitempty"` // any of these statuses
+	MinMagnitude      float32  `json:"min_magnitude,omitempty"`
+	Any               []Rule   `json:"any,omitempty"`
+}
+
+// LoadRouting reads a routing configuration from a JSON file.
+func LoadRouting(path string) (*Routing, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRouting(b)
+}
+
+// ParseRouting parses and validates a JSON routing configuration.
+func ParseRouting(b []byte) (*Routing, error) {
+	var r Routing
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("parse routing: %w", err)
+	}
+
+	if len(r.Groups) == 0 {
+		return nil, errors.New("routing has no groups")
+	}
+
+	names := make(map[string]bool)
+	for i := range r.Groups {
+		g := &r.Groups[i]
+		if g.Name == "" {
+			return nil, fmt.Errorf("group %d has no name", i+1)
+		}
+		if names[g.Name] {
+			return nil, fmt.Errorf("duplicate group %s", g.Name)
+		}
+		names[g.Name] = true
+
+		if len(g.Recipients) == 0 {
+			return nil, fmt.Errorf("group %s has no recipients", g.Name)
+		}
+		for j, rcpt := range g.Recipients {
+			g.Recipients[j] = strings.TrimSpace(rcpt)
+			if !strings.Contains(g.Recipients[j], "@") {
+				return nil, fmt.Errorf("group %s: invalid recipient %q", g.Name, rcpt)
+			}
+		}
+	}
+
+	return &r, nil
+}
+
+// SingleGroup returns routing that sends every EAT to recipients.
+func SingleGroup(name string, recipients []string) *Routing {
+	return &Routing{Groups: []Group{{Name: name, Recipients: recipients}}}
+}
+
+// Route returns the groups that should receive eat.
+func (r *Routing) Route(eat *fastschema.EAT) []Group {
+	var groups []Group
+	for _, g := range r.Groups {
+		if g.When == nil || g.When.Match(eat) {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// Recipients returns the addresses that should receive eat, without
+// duplicates, in group order.
+func (r *Routing) Recipients(eat *fastschema.EAT) []string {
+	seen := make(map[string]bool)
+	var to []string
+	for _, g := range r.Route(eat) {
+		for _, rcpt := range g.Recipients {
+			if k := strings.ToLower(rcpt); !seen[k] {
+				seen[k] = true
+				to = append(to, rcpt)
+			}
+		}
+	}
+	return to
+}
+
+// Match reports whether eat satisfies the rule.
+func (rule *Rule) Match(eat *fastschema.EAT) bool {
+	if rule.BeachMarineThreat != nil && *rule.BeachMarineThreat != eat.BeachMarineThreat {
+		return false
+	}
+	if rule.LandThreat != nil && *rule.LandThreat != eat.LandThreat {
+		return false
+	}
+	if rule.TEPActivated != nil && *rule.TEPActivated != eat.TEPActivated {
+		return false
+	}
+	if eat.Magnitude < rule.MinMagnitude {
+		return false
+	}
+
+	if len(rule.Status) > 0 {
+		var ok bool
+		for _, s := range rule.Status {
+			ok = ok || s == eat.Status
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if len(rule.Any) > 0 {
+		for i := range rule.Any {
+			if rule.Any[i].Match(eat) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
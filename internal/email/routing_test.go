@@ -0,0 +1,141 @@
+package email
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestRoute(t *testing.T) {
+	r, err := LoadRouting("testdata/recipients.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		eat        fastschema.EAT
+		groups     []string
+		recipients []string
+	}{
+		{"no threat", fastschema.EAT{Status: "preliminary", Magnitude: 6},
+			[]string{"duty-officers"},
+			[]string{"duty@example.com", "ops@example.com"}},
+		{"marine threat", fastschema.EAT{Status: "preliminary", Magnitude: 6, BeachMarineThreat: true},
+			[]string{"duty-officers", "marine"},
+			[]string{"duty@example.com", "ops@example.com", "harbours@example.com"}},
+		{"preliminary land threat", fastschema.EAT{Status: "preliminary", Magnitude: 6, LandThreat: true},
+			[]string{"duty-officers"},
+			[]string{"duty@example.com", "ops@example.com"}},
+		{"confirmed land threat", fastschema.EAT{Status: "confirmed", Magnitude: 6, LandThreat: true},
+			[]string{"duty-officers", "civil-defence"},
+			[]string{"duty@example.com", "ops@example.com", "cdem@example.com"}},
+		{"confirmed large quake", fastschema.EAT{Status: "confirmed", Magnitude: 7.8},
+			[]string{"duty-officers", "civil-defence"},
+			[]string{"duty@example.com", "ops@example.com", "cdem@example.com"}},
+		{"confirmed small quake", fastschema.EAT{Status: "confirmed", Magnitude: 7.4},
+			[]string{"duty-officers"},
+			[]string{"duty@example.com", "ops@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var groups []string
+			for _, g := range r.Route(&tt.eat) {
+				groups = append(groups, g.Name)
+			}
+			if !reflect.DeepEqual(groups, tt.groups) {
+				t.Errorf("expected groups %v, got %v", tt.groups, groups)
+			}
+			if got := r.Recipients(&tt.eat); !reflect.DeepEqual(got, tt.recipients) {
+				t.Errorf("expected recipients %v, got %v", tt.recipients, got)
+			}
+		})
+	}
+}
+
+func TestParseRouting(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"no groups", `{"groups":[]}`},
+		{"no name", `{"groups":[{"recipients":["a@b.com"]}]}`},
+		{"duplicate name", `{"groups":[{"name":"a","recipients":["a@b.com"]},{"name":"a","recipients":["c@d.com"]}]}`},
+		{"no recipients", `{"groups":[{"name":"a"}]}`},
+		{"bad recipient", `{"groups":[{"name":"a","recipients":["nobody"]}]}`},
+		{"unknown field", `{"groups":[{"name":"a","recipients":["a@b.com"],"when":{"magnitude":7}}]}`},
+		{"bad JSON", `{`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRouting([]byte(tt.json)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestConfigFromEnvRouting(t *testing.T) {
+	for _, k := range []string{"SMTP_HOST", "SMTP_FROM", "SMTP_RECIPIENTS", "RECIPIENTS_FILE", "EXERCISE_RECIPIENTS"} {
+		orig := os.Getenv(k)
+		defer os.Setenv(k, orig)
+	}
+	os.Setenv("SMTP_HOST", "smtp.example.com")
+	os.Setenv("SMTP_FROM", "test@example.com")
+
+	t.Run("recipients file", func(t *testing.T) {
+		os.Setenv("SMTP_RECIPIENTS", "")
+		os.Setenv("RECIPIENTS_FILE", "testdata/recipients.json")
+		cfg, err := ConfigFromEnv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Routing.Groups) != 3 {
+			t.Errorf("expected 3 groups, got %d", len(cfg.Routing.Groups))
+		}
+	})
+
+	t.Run("missing recipients file", func(t *testing.T) {
+		os.Setenv("RECIPIENTS_FILE", "testdata/missing.json")
+		if _, err := ConfigFromEnv(); err == nil {
+			t.Error("expected error for missing RECIPIENTS_FILE")
+		}
+	})
+
+	t.Run("default group", func(t *testing.T) {
+		os.Setenv("SMTP_RECIPIENTS", "a@b.com")
+		os.Setenv("RECIPIENTS_FILE", "")
+		cfg, err := ConfigFromEnv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := cfg.RecipientsFor(&fastschema.EAT{})
+		if !reflect.DeepEqual(got, []string{"a@b.com"}) {
+			t.Errorf("expected default group to get every EAT, got %v", got)
+		}
+		if got := cfg.RecipientsFor(&fastschema.EAT{Exercise: true}); len(got) != 0 {
+			t.Errorf("expected no recipients for an exercise without EXERCISE_RECIPIENTS, got %v", got)
+		}
+	})
+
+	t.Run("exercise recipients", func(t *testing.T) {
+		os.Setenv("SMTP_RECIPIENTS", "")
+		os.Setenv("RECIPIENTS_FILE", "testdata/recipients.json")
+		os.Setenv("EXERCISE_RECIPIENTS", "drill@example.com, drill2@example.com")
+		cfg, err := ConfigFromEnv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		eat := &fastschema.EAT{Exercise: true, LandThreat: true, Magnitude: 8}
+		if got := cfg.RecipientsFor(eat); !reflect.DeepEqual(got, []string{"drill@example.com", "drill2@example.com"}) {
+			t.Errorf("expected an exercise to go only to the exercise recipients, got %v", got)
+		}
+		if g := cfg.RoutingFor(eat).Route(eat); len(g) != 1 || g[0].Name != "exercise" {
+			t.Errorf("expected the exercise group, got %+v", g)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Sendmail delivers messages by piping them to a sendmail compatible program,
+// leaving queueing and retries to the local mail system.
+type Sendmail struct {
+	Path string
+}
+
+// Send pipes msg to sendmail for all the recipients in to. Sendmail either
+// takes the message for every recipient or for none, so each result has the
+// same outcome.
+func (t *Sendmail) Send(from string, to []string, msg []byte) []Result {
+	args := append([]string{"-i", "-f", from, "--"}, to...)
+	cmd := exec.Command(t.Path, args...)
+	// Sendmail expects local line endings.
+	cmd.Stdin = bytes.NewReader(bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n")))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	outcome, message := Accepted, ""
+	if err := cmd.Run(); err != nil {
+		outcome, message = Deferred, fmt.Sprintf("sendmail: %v", err)
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			if exitRejected(exit.ExitCode()) {
+				outcome = Rejected
+			}
+			if s := strings.TrimSpace(stderr.String()); s != "" {
+				message += ": " + s
+			}
+		}
+	}
+
+	results := make([]Result, len(to))
+	for i, rcpt := range to {
+		results[i] = Result{Recipient: rcpt, Outcome: outcome, Message: message}
+	}
+	return results
+}
+
+// exitRejected reports whether a sendmail exit status (from sysexits.h) means
+// the message can never be delivered, rather than that it might be on retry.
+func exitRejected(code int) bool {
+	switch code {
+	case 64, // EX_USAGE
+		65, // EX_DATAERR
+		67, // EX_NOUSER
+		68: // EX_NOHOST
+		return true
+	}
+	return false
+}
@@ -1,182 +1,519 @@
 package email
 
 import (
+	"bytes"
+	"crypto/rand"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net"
 	"net/smtp"
+	"net/textproto"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
 )
 
-// Config holds SMTP configuration.
+// Config holds email configuration.
 type Config struct {
-	Host       string
-	Port       string
-	Username   string
-	Password   string
-	FromAddr   string
-	Recipients []string
+	Host        string // SMTP server, used when Transport is nil
+	Port        string
+	Username    string
+	Password    string
+	ImplicitTLS bool
+	Transport   Transport // how messages are sent, SMTP to Host if nil
+	FromAddr    string
+	Recipients  []string // SMTP_RECIPIENTS, used when there's no routing file
+	Routing     *Routing
+	Exercise    *Routing   // EXERCISE_RECIPIENTS, who receive exercise EATs instead of Routing
+	PublicURL   string     // base URL of the dashboard, for links in the email
+	Templates   *Templates // email body templates, the built in ones if nil
+	DKIM        *DKIM      // signs messages when set
+
+	MaxAttachmentSize int64 // cap on the total size of EAT attachments sent
 }
 
-// ConfigFromEnv reads SMTP configuration from environment variables.
+// ConfigFromEnv reads email configuration from environment variables.
+// EMAIL_TRANSPORT selects how messages are sent:
+//
+//	smtp      to SMTP_HOST (the default), with SMTP_TLS=starttls or implicit
+//	sendmail  piped to SENDMAIL_PATH, /usr/sbin/sendmail by default
+//	file      written as .eml files to the EMAIL_DIR maildir
+//
+// Recipient groups are read from the RECIPIENTS_FILE routing configuration,
+// or else every EAT goes to the SMTP_RECIPIENTS list. Exercise EATs only go
+// to the EXERCISE_RECIPIENTS list, and aren't sent if it's empty. Email body
+// templates in EMAIL_TEMPLATE_DIR override the built in ones. Messages are
+// DKIM signed when DKIM_DOMAIN, DKIM_SELECTOR and DKIM_KEY_FILE are set. EAT
+// attachments totalling more than EMAIL_ATTACHMENT_LIMIT_MB are linked, not
+// attached.
 func ConfigFromEnv() (Config, error) {
 	cfg := Config{
-		Host:     os.Getenv("SMTP_HOST"),
-		Port:     os.Getenv("SMTP_PORT"),
-		Username: os.Getenv("SMTP_USERNAME"),
-		Password: os.Getenv("SMTP_PASSWORD"),
-		FromAddr: os.Getenv("SMTP_FROM"),
-	}
+		Host:      os.Getenv("SMTP_HOST"),
+		Port:      os.Getenv("SMTP_PORT"),
+		Username:  os.Getenv("SMTP_USERNAME"),
+		Password:  os.Getenv("SMTP_PASSWORD"),
+		FromAddr:  os.Getenv("SMTP_FROM"),
+		PublicURL: strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/"),
 
-	if cfg.Port == "" {
-		cfg.Port = "587"
+		MaxAttachmentSize: DefaultMaxAttachmentSize,
 	}
 
-	recipients := os.Getenv("SMTP_RECIPIENTS")
-	if recipients != "" {
-		cfg.Recipients = strings.Split(recipients, ",")
-		for i := range cfg.Recipients {
-			cfg.Recipients[i] = strings.TrimSpace(cfg.Recipients[i])
+	if mb := os.Getenv("EMAIL_ATTACHMENT_LIMIT_MB"); mb != "" {
+		n, err := strconv.ParseInt(mb, 10, 64)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid EMAIL_ATTACHMENT_LIMIT_MB: %s", mb)
 		}
+		cfg.MaxAttachmentSize = n << 20
 	}
 
-	if cfg.Host == "" {
-		return cfg, fmt.Errorf("SMTP_HOST not set")
+	cfg.Recipients = splitList(os.Getenv("SMTP_RECIPIENTS"))
+	if exercise := splitList(os.Getenv("EXERCISE_RECIPIENTS")); len(exercise) > 0 {
+		cfg.Exercise = SingleGroup("exercise", exercise)
 	}
+
+	switch transport := os.Getenv("EMAIL_TRANSPORT"); transport {
+	case "", "smtp":
+		if cfg.Host == "" {
+			return cfg, fmt.Errorf("SMTP_HOST not set")
+		}
+		switch tlsMode := os.Getenv("SMTP_TLS"); tlsMode {
+		case "":
+			cfg.ImplicitTLS = cfg.Port == "465"
+		case "starttls":
+		case "implicit":
+			cfg.ImplicitTLS = true
+		default:
+			return cfg, fmt.Errorf("SMTP_TLS must be starttls or implicit, not %s", tlsMode)
+		}
+		if cfg.Port == "" {
+			cfg.Port = "587"
+			if cfg.ImplicitTLS {
+				cfg.Port = "465"
+			}
+		}
+	case "sendmail":
+		path := os.Getenv("SENDMAIL_PATH")
+		if path == "" {
+			path = "/usr/sbin/sendmail"
+		}
+		cfg.Transport = &Sendmail{Path: path}
+	case "file":
+		dir := os.Getenv("EMAIL_DIR")
+		if dir == "" {
+			return cfg, fmt.Errorf("EMAIL_DIR not set")
+		}
+		cfg.Transport = &File{Dir: dir}
+	default:
+		return cfg, fmt.Errorf("unknown EMAIL_TRANSPORT %s", transport)
+	}
+
 	if cfg.FromAddr == "" {
 		return cfg, fmt.Errorf("SMTP_FROM not set")
 	}
-	if len(cfg.Recipients) == 0 {
-		return cfg, fmt.Errorf("SMTP_RECIPIENTS not set")
+
+	if path := os.Getenv("RECIPIENTS_FILE"); path != "" {
+		routing, err := LoadRouting(path)
+		if err != nil {
+			return cfg, fmt.Errorf("RECIPIENTS_FILE: %w", err)
+		}
+		cfg.Routing = routing
+	} else if len(cfg.Recipients) > 0 {
+		cfg.Routing = SingleGroup("default", cfg.Recipients)
+	} else {
+		return cfg, fmt.Errorf("SMTP_RECIPIENTS or RECIPIENTS_FILE not set")
+	}
+
+	if dir := os.Getenv("EMAIL_TEMPLATE_DIR"); dir != "" {
+		t, err := LoadTemplates(dir)
+		if err != nil {
+			return cfg, fmt.Errorf("EMAIL_TEMPLATE_DIR: %w", err)
+		}
+		cfg.Templates = t
+	}
+
+	domain, selector, keyFile := os.Getenv("DKIM_DOMAIN"), os.Getenv("DKIM_SELECTOR"), os.Getenv("DKIM_KEY_FILE")
+	if domain != "" || selector != "" || keyFile != "" {
+		if domain == "" || selector == "" || keyFile == "" {
+			return cfg, fmt.Errorf("DKIM_DOMAIN, DKIM_SELECTOR and DKIM_KEY_FILE must all be set to sign email")
+		}
+		key, err := LoadDKIMKey(keyFile)
+		if err != nil {
+			return cfg, fmt.Errorf("DKIM_KEY_FILE: %w", err)
+		}
+		cfg.DKIM = &DKIM{Domain: domain, Selector: selector, Key: key}
 	}
 
 	return cfg, nil
 }
 
-// SendEATEmail sends the EAT notification email with PDF attachment.
-func SendEATEmail(cfg Config, eat *fastschema.EAT, pdfBytes []byte) error {
-	subject := fmt.Sprintf("EAT: %s (Version %d) - %s", eat.EventTitle, eat.Version, eat.Status)
-
-	body := fmt.Sprintf(`Emergency Advisory Text
-
-Event: %s
-Version: %d
-Status: %s
-Location: %s
-Event Date: %s
-Magnitude: %.1f
-Beach/Marine Threat: %s
-Land Threat: %s
-TEP Activated: %s
-
-Comments:
-%s
-`,
-		eat.EventTitle,
-		eat.Version,
-		eat.Status,
-		eat.Location,
-		eat.EventDate.UTC().Format("2006-01-02 15:04 UTC"),
-		eat.Magnitude,
-		boolStr(eat.BeachMarineThreat),
-		boolStr(eat.LandThreat),
-		boolStr(eat.TEPActivated),
-		eat.EventComments,
-	)
-
-	// Build MIME message
-	boundary := "==NEMA_MAR_BOUNDARY=="
-	var msg strings.Builder
-
-	msg.WriteString(fmt.Sprintf("From: %s\r\n", cfg.FromAddr))
-	msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(cfg.Recipients, ", ")))
-	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+// transport returns the configured transport.
+func (cfg Config) transport() Transport {
+	if cfg.Transport != nil {
+		return cfg.Transport
+	}
+	return &SMTP{
+		Host:        cfg.Host,
+		Port:        cfg.Port,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		ImplicitTLS: cfg.ImplicitTLS,
+	}
+}
+
+// splitList splits a comma separated list of addresses.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	list := strings.Split(s, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	return list
+}
+
+// RoutingFor returns the routing of eat: the exercise recipients for an
+// exercise, nil if there are none, or else the routing rules.
+func (cfg Config) RoutingFor(eat *fastschema.EAT) *Routing {
+	if eat.Exercise {
+		return cfg.Exercise
+	}
+	return cfg.Routing
+}
+
+// RecipientsFor returns the addresses that should receive eat. An exercise is
+// never sent to the real recipients.
+func (cfg Config) RecipientsFor(eat *fastschema.EAT) []string {
+	if eat.Exercise {
+		if cfg.Exercise == nil {
+			return nil
+		}
+		return cfg.Exercise.Recipients(eat)
+	}
+	if cfg.Routing == nil {
+		return cfg.Recipients
+	}
+	return cfg.Routing.Recipients(eat)
+}
+
+// Subject returns the subject line of the email for eat. Cancellations are
+// marked at the start so they stand out in an inbox of updates, and exercises
+// are prefixed so they can't be mistaken for a real advisory.
+func Subject(eat *fastschema.EAT) string {
+	s := fmt.Sprintf("EAT: %s (Version %d) - %s", eat.EventTitle, eat.Version, eat.Status)
+	if eat.IsCancelled() {
+		s = fmt.Sprintf("EAT CANCELLED: %s (Version %d)", eat.EventTitle, eat.Version)
+	}
+	if eat.Exercise {
+		s = "EXERCISE EXERCISE EXERCISE - " + s
+	}
+	return s
+}
+
+// Message builds the EAT notification email addressed to to. The body has
+// text and HTML alternatives rendered from the email templates, and the PDF
+// and attachments with data are attached. Attachments without data are
+// linked from the body, and changes summarises what changed since the
+// previous version. The message is DKIM signed if cfg.DKIM is set.
+func Message(cfg Config, eat *fastschema.EAT, pdfBytes []byte, attachments []Attachment, changes []string, to []string) ([]byte, error) {
+	subject := Subject(eat)
+
+	t := cfg.Templates
+	if t == nil {
+		t = defaultTemplates
+	}
+	text, html, err := t.Render(cfg, eat, attachments, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	var msg bytes.Buffer
+	mixed := multipart.NewWriter(&msg)
+
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.FromAddr)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: %s\r\n", messageID(cfg.FromAddr, now))
 	msg.WriteString("MIME-Version: 1.0\r\n")
-	msg.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\r\n", boundary))
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=\"%s\"\r\n", mixed.Boundary())
 	msg.WriteString("\r\n")
 
-	// Text body
-	msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
-	msg.WriteString("Content-Transfer-Encoding: 7bit\r\n")
-	msg.WriteString("\r\n")
-	msg.WriteString(body)
-	msg.WriteString("\r\n")
+	// Text and HTML bodies
+	var alt bytes.Buffer
+	altWriter := multipart.NewWriter(&alt)
+	for _, body := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		part, err := altWriter.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {body.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write(body.content); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := altWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {fmt.Sprintf("multipart/alternative; boundary=\"%s\"", altWriter.Boundary())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(alt.Bytes()); err != nil {
+		return nil, err
+	}
 
 	// PDF attachment
 	if pdfBytes != nil {
-		msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		msg.WriteString("Content-Type: application/pdf\r\n")
-		msg.WriteString("Content-Transfer-Encoding: base64\r\n")
-		msg.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s_v%d.pdf\"\r\n",
-			eat.EventTitle, eat.Version))
-		msg.WriteString("\r\n")
-		msg.WriteString(base64.StdEncoding.EncodeToString(pdfBytes))
-		msg.WriteString("\r\n")
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"application/pdf"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": fmt.Sprintf("%s_v%d.pdf", eat.EventTitle, eat.Version)})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write([]byte(base64Lines(pdfBytes))); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, a := range attachments {
+		if a.Data == nil {
+			continue
+		}
+		contentType := a.File.Type
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.File.Name})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write([]byte(base64Lines(a.Data))); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+
+	if cfg.DKIM != nil {
+		return cfg.DKIM.Sign(msg.Bytes(), now)
+	}
+
+	return msg.Bytes(), nil
+}
+
+// messageID returns a unique Message-ID in the domain of from.
+func messageID(from string, now time.Time) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(strings.Trim(from, "<> "), "@"); ok && d != "" {
+		domain = strings.TrimRight(d, ">")
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("<%d.%s@%s>", now.UnixNano(), hex.EncodeToString(b), domain)
+}
+
+// base64Lines base64 encodes b, wrapped at 76 characters as MIME requires.
+func base64Lines(b []byte) string {
+	enc := base64.StdEncoding.EncodeToString(b)
+
+	var s strings.Builder
+	for len(enc) > 76 {
+		s.WriteString(enc[:76])
+		s.WriteString("\r\n")
+		enc = enc[76:]
 	}
+	s.WriteString(enc)
+	return s.String()
+}
 
-	msg.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+// Send delivers msg to the recipients in to with the configured transport,
+// returning a result for each.
+func Send(cfg Config, to []string, msg []byte) []Result {
+	return cfg.transport().Send(cfg.FromAddr, to, msg)
+}
 
-	// Send via SMTP with STARTTLS
-	addr := net.JoinHostPort(cfg.Host, cfg.Port)
-	auth := smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+// SMTP delivers messages to an SMTP server. Connections use STARTTLS when the
+// server offers it, or TLS from the start when ImplicitTLS is set, as is usual
+// on port 465.
+type SMTP struct {
+	Host        string
+	Port        string
+	Username    string // no authentication if empty
+	Password    string
+	ImplicitTLS bool
+	TLSConfig   *tls.Config   // nil to verify the server against the system roots
+	Timeout     time.Duration // for connecting and the whole exchange, DefaultSMTPTimeout if zero
+}
+
+// DefaultSMTPTimeout bounds each SMTP delivery, so a server that stops
+// responding can't hold up the outbox.
+const DefaultSMTPTimeout = 2 * time.Minute
 
-	conn, err := net.Dial("tcp", addr)
+// Send delivers msg to the recipients in to, returning a result for each. A
+// recipient rejected by the server doesn't stop delivery to the others.
+func (t *SMTP) Send(from string, to []string, msg []byte) []Result {
+	results := make([]Result, len(to))
+	for i, rcpt := range to {
+		results[i] = Result{Recipient: rcpt}
+	}
+
+	// fail records err against every recipient that wasn't already refused
+	// at RCPT, since none of them will have received the message. A session
+	// error, even a permanent reply to AUTH or MAIL, says nothing about the
+	// recipients, so they're deferred to be retried rather than rejected.
+	fail := func(err error) []Result {
+		_, code := classify(err)
+		for i := range results {
+			if results[i].Outcome == "" || results[i].Outcome == Accepted {
+				results[i].Outcome, results[i].Code, results[i].Message = Deferred, code, err.Error()
+			}
+		}
+		return results
+	}
+
+	addr := net.JoinHostPort(t.Host, t.Port)
+
+	tlsCfg := &tls.Config{ServerName: t.Host}
+	if t.TLSConfig != nil {
+		tlsCfg = t.TLSConfig.Clone()
+		if tlsCfg.ServerName == "" {
+			tlsCfg.ServerName = t.Host
+		}
+	}
+
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = DefaultSMTPTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	var err error
+	if t.ImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsCfg)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
 	if err != nil {
-		return fmt.Errorf("dial smtp: %w", err)
+		return fail(fmt.Errorf("dial smtp: %w", err))
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return fail(fmt.Errorf("smtp deadline: %w", err))
 	}
 
-	client, err := smtp.NewClient(conn, cfg.Host)
+	client, err := smtp.NewClient(conn, t.Host)
 	if err != nil {
-		return fmt.Errorf("smtp client: %w", err)
+		conn.Close()
+		return fail(fmt.Errorf("smtp client: %w", err))
 	}
 	defer client.Close()
 
 	// Try STARTTLS
-	if ok, _ := client.Extension("STARTTLS"); ok {
-		tlsCfg := &tls.Config{ServerName: cfg.Host}
-		if err := client.StartTLS(tlsCfg); err != nil {
-			return fmt.Errorf("starttls: %w", err)
+	if !t.ImplicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsCfg); err != nil {
+				return fail(fmt.Errorf("starttls: %w", err))
+			}
 		}
 	}
 
-	if err := client.Auth(auth); err != nil {
-		return fmt.Errorf("smtp auth: %w", err)
+	if t.Username != "" {
+		auth := smtp.PlainAuth("", t.Username, t.Password, t.Host)
+		if err := client.Auth(auth); err != nil {
+			return fail(fmt.Errorf("smtp auth: %w", err))
+		}
 	}
 
-	if err := client.Mail(cfg.FromAddr); err != nil {
-		return fmt.Errorf("smtp mail: %w", err)
+	if err := client.Mail(from); err != nil {
+		return fail(fmt.Errorf("smtp mail: %w", err))
 	}
 
-	for _, rcpt := range cfg.Recipients {
+	var accepted int
+	for i, rcpt := range to {
 		if err := client.Rcpt(rcpt); err != nil {
-			return fmt.Errorf("smtp rcpt %s: %w", rcpt, err)
+			outcome, code := classify(err)
+			results[i].Outcome, results[i].Code, results[i].Message = outcome, code, fmt.Sprintf("smtp rcpt: %v", err)
+			continue
 		}
+		results[i].Outcome = Accepted
+		accepted++
+	}
+	if accepted == 0 {
+		client.Quit()
+		return results
 	}
 
 	w, err := client.Data()
 	if err != nil {
-		return fmt.Errorf("smtp data: %w", err)
+		return fail(fmt.Errorf("smtp data: %w", err))
 	}
 
-	if _, err := w.Write([]byte(msg.String())); err != nil {
-		return fmt.Errorf("smtp write: %w", err)
+	if _, err := w.Write(msg); err != nil {
+		return fail(fmt.Errorf("smtp write: %w", err))
 	}
 
 	if err := w.Close(); err != nil {
-		return fmt.Errorf("smtp close: %w", err)
+		return fail(fmt.Errorf("smtp close: %w", err))
+	}
+
+	// The message was accepted once DATA completes; a failed QUIT doesn't
+	// change that.
+	for i := range results {
+		if results[i].Outcome == Accepted {
+			results[i].Code = 250
+		}
 	}
+	client.Quit()
 
-	return client.Quit()
+	return results
 }
 
-func boolStr(b bool) string {
-	if b {
-		return "Yes"
+// classify maps an SMTP error replying to RCPT to an outcome. Only permanent
+// (5xx) replies are rejections; network errors and everything else may
+// succeed on retry.
+func classify(err error) (Outcome, int) {
+	var te *textproto.Error
+	if errors.As(err, &te) {
+		if te.Code >= 500 && te.Code < 600 {
+			return Rejected, te.Code
+		}
+		return Deferred, te.Code
 	}
-	return "No"
+	return Deferred, 0
 }
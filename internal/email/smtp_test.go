@@ -1,8 +1,21 @@
 package email
 
 import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
 )
 
 func TestConfigFromEnv(t *testing.T) {
@@ -72,11 +85,250 @@ func TestConfigFromEnv(t *testing.T) {
 	})
 }
 
-func TestBoolStr(t *testing.T) {
-	if boolStr(true) != "Yes" {
-		t.Error("expected Yes for true")
+func TestMessage(t *testing.T) {
+	cfg := Config{FromAddr: "eat@example.com", Recipients: []string{"a@b.com", "c@d.com"}}
+	eat := &fastschema.EAT{EventTitle: "M5.0-Wellington-2026-01-01", Version: 2, Status: "confirmed"}
+
+	b, err := Message(cfg, eat, []byte("%PDF-1.4"), nil, nil, []string{"c@d.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(b)
+
+	if !strings.Contains(msg, "To: c@d.com\r\n") {
+		t.Error("expected message addressed to c@d.com only")
+	}
+	if !strings.Contains(msg, "Subject: EAT: M5.0-Wellington-2026-01-01 (Version 2) - confirmed\r\n") {
+		t.Error("unexpected subject")
+	}
+	if !strings.Contains(msg, `filename=M5.0-Wellington-2026-01-01_v2.pdf`) {
+		t.Error("expected PDF attachment")
+	}
+}
+
+func TestMessagePDFFilename(t *testing.T) {
+	eat := &fastschema.EAT{EventTitle: `M5.0-Ōtaki "Kāpiti"-2026-01-01`, Version: 1, Status: "confirmed"}
+
+	b, err := Message(Config{FromAddr: "eat@example.com"}, eat, []byte("%PDF-1.4"), nil, nil, []string{"a@b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mixed.NextPart()
+		if err == io.EOF {
+			t.Fatal("expected a PDF part")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Header.Get("Content-Type") != "application/pdf" {
+			continue
+		}
+
+		cd := p.Header.Get("Content-Disposition")
+		for _, r := range cd {
+			if r > unicode.MaxASCII {
+				t.Errorf("expected an ASCII header, got %s", cd)
+				break
+			}
+		}
+		if name := p.FileName(); name != eat.EventTitle+"_v1.pdf" {
+			t.Errorf("unexpected filename %q from %s", name, cd)
+		}
+		return
+	}
+}
+
+// fakeSMTP runs a minimal SMTP server for one connection. RCPT replies are
+// looked up in replies by address and other replies by command, such as
+// "MAIL", defaulting to success. AUTH is offered if it has a reply.
+func fakeSMTP(t *testing.T, replies map[string]string) (host, port string, received chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveFakeSMTP(t, ln, replies)
+}
+
+// serveFakeSMTP runs the fake SMTP server on ln.
+func serveFakeSMTP(t *testing.T, ln net.Listener, replies map[string]string) (host, port string, received chan string) {
+	t.Helper()
+	t.Cleanup(func() { ln.Close() })
+
+	received = make(chan string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			if reply, ok := replies[cmd]; ok && cmd != "EHLO" {
+				tp.PrintfLine("%s", reply)
+				continue
+			}
+			switch cmd {
+			case "EHLO", "HELO":
+				if _, ok := replies["AUTH"]; ok {
+					tp.PrintfLine("250-fake")
+					tp.PrintfLine("250 AUTH PLAIN")
+				} else {
+					tp.PrintfLine("250 fake")
+				}
+			case "MAIL":
+				tp.PrintfLine("250 2.1.0 OK")
+			case "RCPT":
+				addr := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+				if reply, ok := replies[addr]; ok {
+					tp.PrintfLine("%s", reply)
+				} else {
+					tp.PrintfLine("250 2.1.5 OK")
+				}
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- string(data)
+				tp.PrintfLine("250 2.0.0 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("502 unknown")
+			}
+		}
+	}()
+
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port, received
+}
+
+func TestSend(t *testing.T) {
+	host, port, received := fakeSMTP(t, map[string]string{
+		"bad@example.com":  "550 5.1.1 no such user",
+		"full@example.com": "452 4.2.2 mailbox full",
+	})
+	cfg := Config{Host: host, Port: port, FromAddr: "eat@example.com"}
+	to := []string{"bad@example.com", "ok@example.com", "full@example.com"}
+
+	results := Send(cfg, to, []byte("Subject: test\r\n\r\nhello\r\n"))
+
+	expected := []struct {
+		outcome Outcome
+		code    int
+	}{
+		{Rejected, 550},
+		{Accepted, 250},
+		{Deferred, 452},
+	}
+	for i, e := range expected {
+		if results[i].Recipient != to[i] || results[i].Outcome != e.outcome || results[i].Code != e.code {
+			t.Errorf("unexpected result for %s: %+v", to[i], results[i])
+		}
+	}
+
+	select {
+	case msg := <-received:
+		if !strings.Contains(msg, "hello") {
+			t.Errorf("unexpected message: %s", msg)
+		}
+	default:
+		t.Error("message not delivered to the accepted recipient")
+	}
+
+	if err := Err(results); err == nil || strings.Contains(err.Error(), "ok@example.com") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSendSessionRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		replies  map[string]string
+		username string
+		code     int
+	}{
+		{"auth", map[string]string{"AUTH": "535 5.7.8 authentication failed"}, "eat", 535},
+		{"mail", map[string]string{"MAIL": "550 5.7.1 sender not allowed"}, "", 550},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, _ := fakeSMTP(t, tt.replies)
+			to := []string{"a@example.com", "b@example.com"}
+
+			results := (&SMTP{Host: host, Port: port, Username: tt.username, Password: "secret"}).Send("eat@example.com", to, []byte("msg"))
+
+			for i, r := range results {
+				if r.Recipient != to[i] || r.Outcome != Deferred || r.Code != tt.code || !strings.Contains(r.Message, strconv.Itoa(tt.code)) {
+					t.Errorf("expected %s deferred with %d, got %+v", to[i], tt.code, r)
+				}
+			}
+		})
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	results := Send(Config{Host: host, Port: port}, []string{"a@example.com"}, nil)
+	if results[0].Outcome != Deferred || results[0].Code != 0 {
+		t.Errorf("expected deferred with no code, got %+v", results[0])
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	// The server accepts the connection but never greets.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	start := time.Now()
+	results := (&SMTP{Host: host, Port: port, Timeout: 100 * time.Millisecond}).Send("eat@example.com", []string{"a@example.com"}, []byte("msg"))
+	if results[0].Outcome != Deferred {
+		t.Errorf("expected deferred, got %+v", results[0])
 	}
-	if boolStr(false) != "No" {
-		t.Error("expected No for false")
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected the send to time out, took %v", d)
 	}
 }
@@ -0,0 +1,129 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	texttemplate "text/template"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/feed"
+	"github.com/GeoNet/nema-mar-portal/internal/format"
+)
+
+// Template file names. Operators can override either by putting a file of the
+// same name in EMAIL_TEMPLATE_DIR.
+const (
+	textTemplateName = "eat.txt"
+	htmlTemplateName = "eat.html"
+)
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// Templates renders the text and HTML bodies of the EAT email.
+type Templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// TemplateData is passed to the email templates.
+type TemplateData struct {
+	EAT          *fastschema.EAT
+	DashboardURL string            // link to this version on the dashboard, empty if PUBLIC_URL isn't set
+	Attached     []fastschema.File // attachments sent with the email
+	Linked       []fastschema.File // attachments that couldn't be sent, URL is their dashboard link or empty without PUBLIC_URL
+	Changes      []string          // changes since the previous version, one per line
+}
+
+// defaultTemplates are the built in templates, used when Config.Templates is nil.
+var defaultTemplates = mustLoadTemplates("")
+
+// LoadTemplates parses the email templates. Templates found in dir replace the
+// built in ones; dir may be empty to use only the built in templates.
+func LoadTemplates(dir string) (*Templates, error) {
+	textSrc, err := templateSource(dir, textTemplateName)
+	if err != nil {
+		return nil, err
+	}
+	htmlSrc, err := templateSource(dir, htmlTemplateName)
+	if err != nil {
+		return nil, err
+	}
+
+	var t Templates
+	if t.text, err = texttemplate.New(textTemplateName).Funcs(format.Funcs()).Parse(textSrc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", textTemplateName, err)
+	}
+	if t.html, err = htmltemplate.New(htmlTemplateName).Funcs(format.Funcs()).Parse(htmlSrc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", htmlTemplateName, err)
+	}
+
+	return &t, nil
+}
+
+func mustLoadTemplates(dir string) *Templates {
+	t, err := LoadTemplates(dir)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// templateSource returns the named template from dir if it's there, otherwise
+// the built in one.
+func templateSource(dir, name string) (string, error) {
+	if dir != "" {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(b), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	b, err := builtinTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Render returns the text and HTML bodies of the email for eat, its
+// attachments and the changes since the previous version.
+func (t *Templates) Render(cfg Config, eat *fastschema.EAT, attachments []Attachment, changes []string) (text, html []byte, err error) {
+	data := TemplateData{EAT: eat, Changes: changes}
+	if cfg.PublicURL != "" {
+		data.DashboardURL = feed.DashboardURL(cfg.PublicURL, eat)
+	}
+	for _, a := range attachments {
+		if a.Data != nil {
+			data.Attached = append(data.Attached, a.File)
+		} else {
+			// The file's own URL is on the FastSchema sidecar, which
+			// recipients can't reach, so it's linked through the dashboard.
+			f := a.File
+			f.URL = ""
+			if cfg.PublicURL != "" {
+				f.URL = feed.AttachmentURL(cfg.PublicURL, eat, f)
+			}
+			data.Linked = append(data.Linked, f)
+		}
+	}
+
+	var tb, hb bytes.Buffer
+	if err := t.text.Execute(&tb, data); err != nil {
+		return nil, nil, fmt.Errorf("rendering %s: %w", textTemplateName, err)
+	}
+	if err := t.html.Execute(&hb, data); err != nil {
+		return nil, nil, fmt.Errorf("rendering %s: %w", htmlTemplateName, err)
+	}
+
+	return tb.Bytes(), hb.Bytes(), nil
+}
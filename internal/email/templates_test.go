@@ -0,0 +1,239 @@
+package email
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// parts parses msg and returns the text and HTML bodies, and the attachment
+// content types.
+func parts(t *testing.T, msg []byte) (text, html string, attachments []string) {
+	t.Helper()
+
+	m, err := mail.ReadMessage(strings.NewReader(string(msg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("expected multipart/mixed, got %s (%v)", mediaType, err)
+	}
+
+	mixed := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mixed.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mediaType, params, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if mediaType != "multipart/alternative" {
+			attachments = append(attachments, mediaType)
+			continue
+		}
+
+		alt := multipart.NewReader(p, params["boundary"])
+		for {
+			ap, err := alt.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// NextPart decodes quoted-printable
+			b, err := io.ReadAll(ap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := strings.ReplaceAll(string(b), "\r\n", "\n")
+			switch ct := ap.Header.Get("Content-Type"); {
+			case strings.HasPrefix(ct, "text/plain"):
+				text = body
+			case strings.HasPrefix(ct, "text/html"):
+				html = body
+			}
+		}
+	}
+
+	return text, html, attachments
+}
+
+func TestMessageAlternatives(t *testing.T) {
+	cfg := Config{FromAddr: "eat@example.com", PublicURL: "https://example.com"}
+	eat := &fastschema.EAT{
+		EventID:           12,
+		EventTitle:        "M7.1-Kermadec Islands-2026-01-15",
+		Version:           3,
+		Status:            "confirmed",
+		Location:          "Kermadec Islands",
+		Magnitude:         7.1,
+		BeachMarineThreat: true,
+		EventComments:     "Stay out of the water & away from Ōtaki beach <now>.",
+	}
+
+	msg, err := Message(cfg, eat, []byte("%PDF-1.4"), nil, nil, []string{"a@b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, html, attachments := parts(t, msg)
+
+	for _, want := range []string{
+		"*** THREAT: BEACH AND MARINE ***",
+		"Magnitude: 7.1\n",
+		"Beach/Marine Threat: Yes\n",
+		"Land Threat: No\n",
+		"Ōtaki beach <now>.",
+		"https://example.com/dashboard?event=12&version=3",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected text body to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	for _, want := range []string{
+		"<strong>THREAT: Beach and Marine</strong>",
+		"Ōtaki beach &lt;now&gt;.",
+		`<a href="https://example.com/dashboard?event=12&amp;version=3">View version 3 on the dashboard</a>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected HTML body to contain %q, got:\n%s", want, html)
+		}
+	}
+
+	if len(attachments) != 1 || attachments[0] != "application/pdf" {
+		t.Errorf("expected a PDF attachment, got %v", attachments)
+	}
+}
+
+func TestMessageNoThreat(t *testing.T) {
+	eat := &fastschema.EAT{EventTitle: "M5.0-Wellington-2026-01-01", Version: 1, Status: "preliminary"}
+
+	msg, err := Message(Config{FromAddr: "eat@example.com"}, eat, nil, nil, nil, []string{"a@b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, html, attachments := parts(t, msg)
+
+	if strings.Contains(text, "THREAT") || strings.Contains(html, "THREAT") {
+		t.Error("expected no threat highlight")
+	}
+	if strings.Contains(text, "dashboard") || strings.Contains(html, "dashboard") {
+		t.Error("expected no dashboard link without a public URL")
+	}
+	if strings.Contains(text, "Changes since") || strings.Contains(html, "Changes Since") {
+		t.Error("expected no changes section without changes")
+	}
+	if len(attachments) != 0 {
+		t.Errorf("expected no attachments, got %v", attachments)
+	}
+}
+
+func TestMessageCancelled(t *testing.T) {
+	eat := &fastschema.EAT{
+		EventTitle:        "M7.1-Kermadec Islands-2026-01-15",
+		Version:           4,
+		Status:            fastschema.StatusCancelled,
+		CancelReason:      "Issued for the wrong event.",
+		BeachMarineThreat: true,
+	}
+
+	msg, err := Message(Config{FromAddr: "eat@example.com"}, eat, nil, nil, nil, []string{"a@b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(msg), "Subject: EAT CANCELLED: M7.1-Kermadec Islands-2026-01-15 (Version 4)\r\n") {
+		t.Errorf("expected a cancellation subject, got:\n%s", msg)
+	}
+
+	text, html, _ := parts(t, msg)
+	if !strings.Contains(text, "*** CANCELLED: THIS ADVISORY HAS BEEN WITHDRAWN ***\nReason: Issued for the wrong event.") {
+		t.Errorf("expected the cancellation and reason in the text body, got:\n%s", text)
+	}
+	if !strings.Contains(html, "CANCELLED: this advisory has been withdrawn") || !strings.Contains(html, "Reason: Issued for the wrong event.") {
+		t.Errorf("expected the cancellation and reason in the HTML body, got:\n%s", html)
+	}
+	if strings.Contains(text, "THREAT:") || strings.Contains(html, "THREAT:") {
+		t.Error("expected no threat highlight on a cancellation")
+	}
+}
+
+func TestMessageExercise(t *testing.T) {
+	eat := &fastschema.EAT{EventTitle: "M5.0-Wellington-2026-01-01", Version: 1, Status: "preliminary", Exercise: true}
+
+	msg, err := Message(Config{FromAddr: "eat@example.com"}, eat, nil, nil, nil, []string{"a@b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(msg), "Subject: EXERCISE EXERCISE EXERCISE - EAT: M5.0-Wellington-2026-01-01 (Version 1) - preliminary\r\n") {
+		t.Errorf("expected an exercise subject, got:\n%s", msg)
+	}
+
+	text, html, _ := parts(t, msg)
+	if !strings.HasPrefix(text, "*** EXERCISE EXERCISE EXERCISE: THIS IS A DRILL, NOT A REAL EVENT ***\n\nEmergency Advisory Text") {
+		t.Errorf("expected the text body to start with the exercise marking, got:\n%s", text)
+	}
+	if !strings.Contains(html, "EXERCISE EXERCISE EXERCISE: this is a drill, not a real event") {
+		t.Errorf("expected the exercise marking in the HTML body, got:\n%s", html)
+	}
+}
+
+func TestMessageChanges(t *testing.T) {
+	eat := &fastschema.EAT{EventTitle: "M5.0-Wellington-2026-01-01", Version: 2, Status: "confirmed"}
+	changes := []string{"Status: preliminary → confirmed", "Event comments revised"}
+
+	msg, err := Message(Config{FromAddr: "eat@example.com"}, eat, nil, nil, changes, []string{"a@b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, html, _ := parts(t, msg)
+	if !strings.Contains(text, "Changes since the previous version:\n  Status: preliminary → confirmed\n  Event comments revised\n") {
+		t.Errorf("expected the changes in the text body, got:\n%s", text)
+	}
+	if !strings.Contains(html, "<li>Status: preliminary → confirmed</li>") {
+		t.Errorf("expected the changes in the HTML body, got:\n%s", html)
+	}
+}
+
+func TestLoadTemplatesOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "eat.txt"), []byte("Custom: {{.EAT.EventTitle}} M{{formatMagnitude .EAT.Magnitude}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eat := &fastschema.EAT{EventTitle: "M5.0-Wellington-2026-01-01", Magnitude: 5}
+	text, html, err := tmpl.Render(Config{}, eat, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != "Custom: M5.0-Wellington-2026-01-01 M5.0" {
+		t.Errorf("expected overridden text template, got %q", text)
+	}
+	if !strings.Contains(string(html), "Emergency Advisory Text") {
+		t.Error("expected built in HTML template")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "eat.html"), []byte("{{.EAT.Nope"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTemplates(dir); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
@@ -0,0 +1,46 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Transport delivers a message to its recipients.
+type Transport interface {
+	// Send delivers msg from from to each recipient in to, returning a
+	// result for each recipient in the same order.
+	Send(from string, to []string, msg []byte) []Result
+}
+
+// Outcome is the result of delivering to one recipient.
+type Outcome string
+
+// Delivery outcomes. Rejected deliveries failed permanently (an SMTP 5xx
+// reply to the recipient) and shouldn't be retried; deferred ones failed
+// temporarily or for reasons that aren't the recipient's.
+const (
+	Accepted Outcome = "accepted"
+	Rejected Outcome = "rejected"
+	Deferred Outcome = "deferred"
+)
+
+// Result records the outcome of delivering to one recipient, with the SMTP
+// reply code when the server gave one.
+type Result struct {
+	Recipient string
+	Outcome   Outcome
+	Code      int
+	Message   string
+}
+
+// Err returns an error describing the recipients in results that weren't
+// accepted, or nil if all were.
+func Err(results []Result) error {
+	var errs []error
+	for _, r := range results {
+		if r.Outcome != Accepted {
+			errs = append(errs, fmt.Errorf("%s %s: %s", r.Recipient, r.Outcome, r.Message))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,213 @@
+package email
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSMTPImplicitTLS(t *testing.T) {
+	// Borrow the httptest certificate, which is valid for 127.0.0.1.
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	cert := srv.TLS.Certificates
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	srv.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, received := serveFakeSMTP(t, ln, nil)
+
+	tr := &SMTP{Host: host, Port: port, ImplicitTLS: true, TLSConfig: &tls.Config{RootCAs: roots}}
+	results := tr.Send("eat@example.com", []string{"a@example.com"}, []byte("Subject: test\r\n\r\nhello\r\n"))
+	if err := Err(results); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-received; !strings.Contains(msg, "hello") {
+		t.Errorf("unexpected message: %s", msg)
+	}
+
+	// Without the test root the server certificate isn't trusted.
+	ln, err = tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ = serveFakeSMTP(t, ln, nil)
+	results = (&SMTP{Host: host, Port: port, ImplicitTLS: true}).Send("eat@example.com", []string{"a@example.com"}, nil)
+	if results[0].Outcome != Deferred {
+		t.Errorf("expected untrusted certificate to defer, got %+v", results[0])
+	}
+}
+
+// fakeSendmail writes a script that records its arguments and input in dir
+// and exits with status.
+func fakeSendmail(t *testing.T, dir, status string) string {
+	t.Helper()
+	path := filepath.Join(dir, "sendmail")
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args") + "\ncat > " + filepath.Join(dir, "msg") + "\necho 'no such user' >&2\nexit " + status + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSendmail(t *testing.T) {
+	dir := t.TempDir()
+	tr := &Sendmail{Path: fakeSendmail(t, dir, "0")}
+
+	results := tr.Send("eat@example.com", []string{"a@example.com", "-b@example.com"}, []byte("Subject: test\r\n\r\nhello\r\n"))
+	if err := Err(results); err != nil {
+		t.Fatal(err)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if string(args) != "-i -f eat@example.com -- a@example.com -b@example.com\n" {
+		t.Errorf("unexpected sendmail arguments: %s", args)
+	}
+	msg, _ := os.ReadFile(filepath.Join(dir, "msg"))
+	if string(msg) != "Subject: test\n\nhello\n" {
+		t.Errorf("expected message with local line endings, got %q", msg)
+	}
+
+	tests := []struct {
+		status  string
+		outcome Outcome
+	}{
+		{"67", Rejected},
+		{"75", Deferred},
+	}
+	for _, tt := range tests {
+		tr := &Sendmail{Path: fakeSendmail(t, t.TempDir(), tt.status)}
+		results := tr.Send("eat@example.com", []string{"a@example.com", "b@example.com"}, nil)
+		for _, r := range results {
+			if r.Outcome != tt.outcome || !strings.Contains(r.Message, "no such user") {
+				t.Errorf("exit %s: unexpected result %+v", tt.status, r)
+			}
+		}
+	}
+
+	results = (&Sendmail{Path: filepath.Join(dir, "missing")}).Send("eat@example.com", []string{"a@example.com"}, nil)
+	if results[0].Outcome != Deferred {
+		t.Errorf("expected missing sendmail to defer, got %+v", results[0])
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	tr := &File{Dir: dir}
+
+	for i := 0; i < 2; i++ {
+		results := tr.Send("eat@example.com", []string{"a@example.com", "b@example.com"}, []byte("Subject: test\r\n\r\nhello\r\n"))
+		if err := Err(results); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "new", "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(files))
+	}
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("expected tmp to be empty, got %d files", len(tmp))
+	}
+
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Return-Path: <eat@example.com>\r\nDelivered-To: a@example.com\r\nDelivered-To: b@example.com\r\nSubject: test\r\n\r\nhello\r\n"
+	if string(b) != want {
+		t.Errorf("unexpected message:\n%q", b)
+	}
+
+	// A file where the Maildir should be can't be written to.
+	bad := filepath.Join(dir, "file")
+	if err := os.WriteFile(bad, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results := (&File{Dir: bad}).Send("eat@example.com", []string{"a@example.com"}, nil)
+	if results[0].Outcome != Deferred {
+		t.Errorf("expected deferred, got %+v", results[0])
+	}
+}
+
+func TestConfigFromEnvTransport(t *testing.T) {
+	for _, k := range []string{"SMTP_HOST", "SMTP_PORT", "SMTP_TLS", "SMTP_FROM", "SMTP_RECIPIENTS", "EMAIL_TRANSPORT", "SENDMAIL_PATH", "EMAIL_DIR"} {
+		orig := os.Getenv(k)
+		defer os.Setenv(k, orig)
+		os.Setenv(k, "")
+	}
+	os.Setenv("SMTP_FROM", "test@example.com")
+	os.Setenv("SMTP_RECIPIENTS", "a@b.com")
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+		check   func(Config) bool
+	}{
+		{"starttls", map[string]string{"SMTP_HOST": "smtp.example.com"}, false,
+			func(c Config) bool { return c.Port == "587" && !c.ImplicitTLS && c.Transport == nil }},
+		{"implicit port", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_PORT": "465"}, false,
+			func(c Config) bool { return c.ImplicitTLS }},
+		{"implicit", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_TLS": "implicit"}, false,
+			func(c Config) bool { return c.Port == "465" && c.ImplicitTLS }},
+		{"bad tls", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_TLS": "yes"}, true, nil},
+		{"sendmail", map[string]string{"EMAIL_TRANSPORT": "sendmail"}, false,
+			func(c Config) bool {
+				s, ok := c.Transport.(*Sendmail)
+				return ok && s.Path == "/usr/sbin/sendmail"
+			}},
+		{"file", map[string]string{"EMAIL_TRANSPORT": "file", "EMAIL_DIR": "/tmp/mail"}, false,
+			func(c Config) bool {
+				f, ok := c.Transport.(*File)
+				return ok && f.Dir == "/tmp/mail"
+			}},
+		{"file without dir", map[string]string{"EMAIL_TRANSPORT": "file"}, true, nil},
+		{"unknown", map[string]string{"EMAIL_TRANSPORT": "pigeon"}, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Setenv(k, "")
+			}
+			cfg, err := ConfigFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("unexpected config: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestSendWithTransport(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{FromAddr: "eat@example.com", Transport: &File{Dir: dir}}
+
+	if err := Err(Send(cfg, []string{"a@example.com"}, []byte("Subject: test\r\n\r\nhello\r\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "new", "*.eml")); len(files) != 1 {
+		t.Errorf("expected 1 message, got %d", len(files))
+	}
+}
@@ -3,14 +3,23 @@ package fastschema
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
+// ErrConflict is returned when a write violates a uniqueness constraint,
+// e.g. two EATs being published with the same event title and version.
+var ErrConflict = errors.New("conflict")
+
+// ErrTooLarge is returned when a downloaded file is bigger than allowed.
+var ErrTooLarge = errors.New("file too large")
+
 // Client communicates with the FastSchema sidecar.
 type Client struct {
 	baseURL    string
@@ -56,9 +65,13 @@ func (c *Client) Login(username, password string) error {
 	return nil
 }
 
-// ListEATs returns EATs with event_date since the given time, sorted by event_date descending.
+// publishedFilter restricts queries to EATs that have been approved and
+// published; pending and rejected EATs are only visible to the review pages.
+const publishedFilter = `"state":{"$eq":"published"}`
+
+// ListEATs returns published EATs with event_date since the given time, sorted by event_date descending.
 func (c *Client) ListEATs(since time.Time) ([]EAT, error) {
-	filter := fmt.Sprintf(`{"event_date":{"$gte":"%s"}}`, since.UTC().Format(time.RFC3339))
+	filter := fmt.Sprintf(`{"event_date":{"$gte":"%s"},%s}`, since.UTC().Format(time.RFC3339), publishedFilter)
 	u := fmt.Sprintf("%s/api/content/eat?filter=%s&sort=-event_date&limit=100",
 		c.baseURL, url.QueryEscape(filter))
 
@@ -91,9 +104,9 @@ func (c *Client) GetEAT(id int) (*EAT, error) {
 	return &resp.Data, nil
 }
 
-// GetLatestVersion returns the latest version EAT for the given event title.
-func (c *Client) GetLatestVersion(eventTitle string) (*EAT, error) {
-	filter := fmt.Sprintf(`{"event_title":{"$eq":"%s"}}`, eventTitle)
+// GetLatestVersion returns the latest published version EAT for the given event.
+func (c *Client) GetLatestVersion(eventID int) (*EAT, error) {
+	filter := fmt.Sprintf(`{"event_id":{"$eq":%d},%s}`, eventID, publishedFilter)
 	u := fmt.Sprintf("%s/api/content/eat?filter=%s&sort=-version&limit=1",
 		c.baseURL, url.QueryEscape(filter))
 
@@ -114,29 +127,180 @@ func (c *Client) GetLatestVersion(eventTitle string) (*EAT, error) {
 	return &resp.Data.Items[0], nil
 }
 
-// ListDistinctEvents returns distinct event titles from the last N days.
-func (c *Client) ListDistinctEvents(days int) ([]string, error) {
+// GetVersion returns the published EAT with the given event and version
+// number, or nil if no such version exists.
+func (c *Client) GetVersion(eventID, version int) (*EAT, error) {
+	filter := fmt.Sprintf(`{"event_id":{"$eq":%d},"version":{"$eq":%d},%s}`, eventID, version, publishedFilter)
+	u := fmt.Sprintf("%s/api/content/eat?filter=%s&limit=1",
+		c.baseURL, url.QueryEscape(filter))
+
+	body, err := c.doGet(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ListResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode list response: %w", err)
+	}
+
+	if len(resp.Data.Items) == 0 {
+		return nil, nil
+	}
+
+	return &resp.Data.Items[0], nil
+}
+
+// ListVersions returns all published versions of the given event, sorted by version ascending.
+func (c *Client) ListVersions(eventID int) ([]EAT, error) {
+	filter := fmt.Sprintf(`{"event_id":{"$eq":%d},%s}`, eventID, publishedFilter)
+	u := fmt.Sprintf("%s/api/content/eat?filter=%s&sort=version&limit=100",
+		c.baseURL, url.QueryEscape(filter))
+
+	body, err := c.doGet(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ListResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode list response: %w", err)
+	}
+
+	return resp.Data.Items, nil
+}
+
+// ListPending returns EATs awaiting approval, oldest first.
+func (c *Client) ListPending() ([]EAT, error) {
+	filter := fmt.Sprintf(`{"state":{"$eq":"%s"}}`, StatePendingApproval)
+	u := fmt.Sprintf("%s/api/content/eat?filter=%s&sort=created_at&limit=100",
+		c.baseURL, url.QueryEscape(filter))
+
+	body, err := c.doGet(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ListResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode list response: %w", err)
+	}
+
+	return resp.Data.Items, nil
+}
+
+// ListDistinctEvents returns the events with EATs from the last N days, most
+// recent first, titled and flagged as exercises by their latest published
+// version. EATs from before events were introduced are left out until they are
+// given an event on startup.
+func (c *Client) ListDistinctEvents(days int) ([]EventSummary, error) {
 	since := time.Now().UTC().AddDate(0, 0, -days)
 	eats, err := c.ListEATs(since)
 	if err != nil {
 		return nil, err
 	}
 
-	seen := make(map[string]bool)
-	var titles []string
+	index := make(map[int]int)
+	latest := make(map[int]int)
+	var events []EventSummary
 	for _, eat := range eats {
-		if !seen[eat.EventTitle] {
-			seen[eat.EventTitle] = true
-			titles = append(titles, eat.EventTitle)
+		if eat.EventID == 0 {
+			continue
+		}
+		i, ok := index[eat.EventID]
+		if !ok {
+			i = len(events)
+			index[eat.EventID] = i
+			events = append(events, EventSummary{ID: eat.EventID})
 		}
+		if eat.Version >= latest[eat.EventID] {
+			latest[eat.EventID] = eat.Version
+			events[i].Title = eat.EventTitle
+			events[i].Exercise = eat.Exercise
+		}
+	}
+
+	return events, nil
+}
+
+// GetEvent retrieves a single event by ID.
+func (c *Client) GetEvent(id int) (*Event, error) {
+	body, err := c.doGet(fmt.Sprintf("%s/api/content/event/%d", c.baseURL, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp EventResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode event response: %w", err)
 	}
 
-	return titles, nil
+	return &resp.Data, nil
 }
 
-// CreateEAT creates a new EAT record in FastSchema.
+// FindEvent returns the event with the given public ID, or nil if there is
+// none.
+func (c *Client) FindEvent(publicID string) (*Event, error) {
+	filter, err := json.Marshal(map[string]map[string]string{"public_id": {"$eq": publicID}})
+	if err != nil {
+		return nil, fmt.Errorf("marshal filter: %w", err)
+	}
+	body, err := c.doGet(fmt.Sprintf("%s/api/content/event?filter=%s&limit=1", c.baseURL, url.QueryEscape(string(filter))))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp EventListResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode event list response: %w", err)
+	}
+
+	if len(resp.Data.Items) == 0 {
+		return nil, nil
+	}
+
+	return &resp.Data.Items[0], nil
+}
+
+// CreateEvent creates a new event. It fails with ErrConflict if the public ID
+// is already taken.
+func (c *Client) CreateEvent(e *Event) (*Event, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"public_id": e.PublicID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal event: %w", err)
+	}
+
+	body, err := c.doPost(c.baseURL+"/api/content/event", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp EventResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode event response: %w", err)
+	}
+
+	return &resp.Data, nil
+}
+
+// relation is a reference to another record, as FastSchema sets relation
+// fields.
+type relation struct {
+	ID int `json:"id"`
+}
+
+// CreateEAT creates a new EAT record in FastSchema, as a version of its event.
 func (c *Client) CreateEAT(eat *EAT) (*EAT, error) {
-	payload, err := json.Marshal(eat)
+	var event *relation
+	if eat.EventID > 0 {
+		event = &relation{ID: eat.EventID}
+	}
+	payload, err := json.Marshal(struct {
+		*EAT
+		Event *relation `json:"event,omitempty"`
+	}{eat, event})
 	if err != nil {
 		return nil, fmt.Errorf("marshal eat: %w", err)
 	}
@@ -154,6 +318,226 @@ func (c *Client) CreateEAT(eat *EAT) (*EAT, error) {
 	return &resp.Data, nil
 }
 
+// UpdateEAT updates the given fields of an existing EAT record.
+func (c *Client) UpdateEAT(id int, fields map[string]interface{}) (*EAT, error) {
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("marshal eat fields: %w", err)
+	}
+
+	body, err := c.doPut(fmt.Sprintf("%s/api/content/eat/%d", c.baseURL, id), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp SingleResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode update response: %w", err)
+	}
+
+	return &resp.Data, nil
+}
+
+// ListUnassignedEATs returns EATs in any state that belong to no event: those
+// saved before events were introduced.
+func (c *Client) ListUnassignedEATs() ([]EAT, error) {
+	filter := `{"event_id":{"$null":true}}`
+	u := fmt.Sprintf("%s/api/content/eat?filter=%s&sort=id&limit=100",
+		c.baseURL, url.QueryEscape(filter))
+
+	body, err := c.doGet(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ListResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode list response: %w", err)
+	}
+
+	return resp.Data.Items, nil
+}
+
+// AssignEvent makes an existing EAT a version of the given event.
+func (c *Client) AssignEvent(eatID, eventID int) error {
+	payload, err := json.Marshal(map[string]relation{"event": {ID: eventID}})
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	_, err = c.doPut(fmt.Sprintf("%s/api/content/eat/%d", c.baseURL, eatID), "application/json", bytes.NewReader(payload))
+	return err
+}
+
+// ListDrafts returns the editor drafts owned by owner, most recently saved first.
+func (c *Client) ListDrafts(owner string) ([]Draft, error) {
+	filter, err := json.Marshal(map[string]map[string]string{"owner": {"$eq": owner}})
+	if err != nil {
+		return nil, fmt.Errorf("marshal filter: %w", err)
+	}
+	u := fmt.Sprintf("%s/api/content/draft?filter=%s&sort=-updated_at&limit=20",
+		c.baseURL, url.QueryEscape(string(filter)))
+
+	body, err := c.doGet(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp DraftListResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode draft list response: %w", err)
+	}
+
+	return resp.Data.Items, nil
+}
+
+// GetDraft returns a single draft by ID.
+func (c *Client) GetDraft(id int) (*Draft, error) {
+	body, err := c.doGet(fmt.Sprintf("%s/api/content/draft/%d", c.baseURL, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp DraftResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode draft response: %w", err)
+	}
+
+	return &resp.Data, nil
+}
+
+// SaveDraft creates the draft, or updates it when it has an ID.
+func (c *Client) SaveDraft(d *Draft) (*Draft, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"owner":       d.Owner,
+		"event_title": d.EventTitle,
+		"form":        d.Form,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal draft: %w", err)
+	}
+
+	var body []byte
+	if d.ID > 0 {
+		body, err = c.doPut(fmt.Sprintf("%s/api/content/draft/%d", c.baseURL, d.ID), "application/json", bytes.NewReader(payload))
+	} else {
+		body, err = c.doPost(c.baseURL+"/api/content/draft", "application/json", bytes.NewReader(payload))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp DraftResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode draft response: %w", err)
+	}
+
+	return &resp.Data, nil
+}
+
+// DeleteDraft deletes a draft by ID.
+func (c *Client) DeleteDraft(id int) error {
+	_, err := c.doSend("DELETE", fmt.Sprintf("%s/api/content/draft/%d", c.baseURL, id), "application/json", nil)
+	return err
+}
+
+// CreateDelivery adds a delivery job to the email outbox.
+func (c *Client) CreateDelivery(d *Delivery) (*Delivery, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"eat_id":          d.EATID,
+		"event_title":     d.EventTitle,
+		"version":         d.Version,
+		"recipient":       d.Recipient,
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"next_attempt_at": d.NextAttemptAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal delivery: %w", err)
+	}
+
+	body, err := c.doPost(c.baseURL+"/api/content/delivery", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp DeliveryResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode delivery response: %w", err)
+	}
+
+	return &resp.Data, nil
+}
+
+// GetDelivery returns a single delivery job by ID.
+func (c *Client) GetDelivery(id int) (*Delivery, error) {
+	body, err := c.doGet(fmt.Sprintf("%s/api/content/delivery/%d", c.baseURL, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp DeliveryResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode delivery response: %w", err)
+	}
+
+	return &resp.Data, nil
+}
+
+// ListDeliveries returns the delivery jobs for an EAT, by recipient.
+func (c *Client) ListDeliveries(eatID int) ([]Delivery, error) {
+	filter := fmt.Sprintf(`{"eat_id":{"$eq":%d}}`, eatID)
+	return c.listDeliveries(fmt.Sprintf("%s/api/content/delivery?filter=%s&sort=recipient&limit=500",
+		c.baseURL, url.QueryEscape(filter)))
+}
+
+// ListDueDeliveries returns pending delivery jobs due to be attempted at now,
+// oldest first.
+func (c *Client) ListDueDeliveries(now time.Time) ([]Delivery, error) {
+	filter := fmt.Sprintf(`{"status":{"$eq":"%s"},"next_attempt_at":{"$lte":"%s"}}`,
+		DeliveryPending, now.UTC().Format(time.RFC3339))
+	return c.listDeliveries(fmt.Sprintf("%s/api/content/delivery?filter=%s&sort=next_attempt_at&limit=50",
+		c.baseURL, url.QueryEscape(filter)))
+}
+
+func (c *Client) listDeliveries(u string) ([]Delivery, error) {
+	body, err := c.doGet(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp DeliveryListResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode delivery list response: %w", err)
+	}
+
+	return resp.Data.Items, nil
+}
+
+// UpdateDelivery saves the status and attempt log of a delivery job.
+func (c *Client) UpdateDelivery(d *Delivery) error {
+	fields := map[string]interface{}{
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"next_attempt_at": d.NextAttemptAt.UTC().Format(time.RFC3339),
+		"last_error":      d.LastError,
+		"smtp_code":       d.SMTPCode,
+		"log":             d.Log,
+		"sent_at":         nil,
+	}
+	if d.SentAt != nil {
+		fields["sent_at"] = d.SentAt.UTC().Format(time.RFC3339)
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("marshal delivery: %w", err)
+	}
+
+	_, err = c.doPut(fmt.Sprintf("%s/api/content/delivery/%d", c.baseURL, d.ID), "application/json", bytes.NewReader(payload))
+	return err
+}
+
 // UploadFile uploads a file to FastSchema's file manager.
 func (c *Client) UploadFile(filename string, data io.Reader) (*File, error) {
 	var buf bytes.Buffer
@@ -185,6 +569,74 @@ func (c *Client) UploadFile(filename string, data io.Reader) (*File, error) {
 	return &resp.Data, nil
 }
 
+// GetFile returns the record of an uploaded file by ID.
+func (c *Client) GetFile(id int) (*File, error) {
+	body, err := c.doGet(fmt.Sprintf("%s/api/content/file/%d", c.baseURL, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp FileResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode file response: %w", err)
+	}
+
+	return &resp.Data, nil
+}
+
+// DownloadFile fetches the content of an uploaded file from the object store.
+// It fails with ErrTooLarge, without reading the whole file, if the file is
+// bigger than max bytes.
+func (c *Client) DownloadFile(f File, max int64) ([]byte, error) {
+	u := f.URL
+	if strings.HasPrefix(u, "/") {
+		u = c.baseURL + u
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	// Files kept in an external object store are fetched without the token,
+	// which is only ever sent to FastSchema itself.
+	if c.token != "" && c.sameOrigin(req.URL) {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("download %s: %s", f.Name, resp.Status)
+	}
+	if resp.ContentLength > max {
+		return nil, fmt.Errorf("%s is %d bytes: %w", f.Name, resp.ContentLength, ErrTooLarge)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	if int64(len(b)) > max {
+		return nil, fmt.Errorf("%s is over %d bytes: %w", f.Name, max, ErrTooLarge)
+	}
+
+	return b, nil
+}
+
+// sameOrigin reports whether u has the scheme and host of the FastSchema base
+// URL.
+func (c *Client) sameOrigin(u *url.URL) bool {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, base.Scheme) && strings.EqualFold(u.Host, base.Host) && u.User == nil
+}
+
 // ApplySchema sends the schema JSON to FastSchema's admin API.
 func (c *Client) ApplySchema(schemaJSON []byte) error {
 	_, err := c.doPost(c.baseURL+"/api/schema", "application/json", bytes.NewReader(schemaJSON))
@@ -214,7 +666,7 @@ func (c *Client) doGet(url string) ([]byte, error) {
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("FastSchema error (%d): %s", resp.StatusCode, string(body))
+		return nil, responseError(resp.StatusCode, body)
 	}
 
 	return body, nil
@@ -222,7 +674,17 @@ func (c *Client) doGet(url string) ([]byte, error) {
 
 // doPost performs an authenticated POST request and returns the response body.
 func (c *Client) doPost(url, contentType string, body io.Reader) ([]byte, error) {
-	req, err := http.NewRequest("POST", url, body)
+	return c.doSend("POST", url, contentType, body)
+}
+
+// doPut performs an authenticated PUT request and returns the response body.
+func (c *Client) doPut(url, contentType string, body io.Reader) ([]byte, error) {
+	return c.doSend("PUT", url, contentType, body)
+}
+
+// doSend performs an authenticated request with a body and returns the response body.
+func (c *Client) doSend(method, url, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -244,8 +706,19 @@ func (c *Client) doPost(url, contentType string, body io.Reader) ([]byte, error)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("FastSchema error (%d): %s", resp.StatusCode, string(respBody))
+		return nil, responseError(resp.StatusCode, respBody)
 	}
 
 	return respBody, nil
 }
+
+// responseError builds the error for a non-2xx FastSchema response. Unique
+// constraint violations are reported by the database driver in the message
+// rather than with a distinct status, so both are checked.
+func responseError(code int, body []byte) error {
+	msg := strings.ToLower(string(body))
+	if code == http.StatusConflict || strings.Contains(msg, "duplicate") || strings.Contains(msg, "unique") {
+		return fmt.Errorf("FastSchema error (%d): %s: %w", code, string(body), ErrConflict)
+	}
+	return fmt.Errorf("FastSchema error (%d): %s", code, string(body))
+}
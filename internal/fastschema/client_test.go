@@ -2,8 +2,10 @@ package fastschema
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -115,7 +117,7 @@ func TestGetLatestVersion(t *testing.T) {
 	defer server.Close()
 
 	c := NewClient(server.URL)
-	eat, err := c.GetLatestVersion("M5.0-Wellington-2026-01-01")
+	eat, err := c.GetLatestVersion(5)
 	if err != nil {
 		t.Fatalf("GetLatestVersion failed: %v", err)
 	}
@@ -127,15 +129,93 @@ func TestGetLatestVersion(t *testing.T) {
 	}
 }
 
+func TestGetVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := `{"event_id":{"$eq":5},"version":{"$eq":2},"state":{"$eq":"published"}}`
+		if got := r.URL.Query().Get("filter"); got != want {
+			t.Errorf("unexpected filter: %s", got)
+		}
+
+		resp := ListResponse{
+			Data: ListData{
+				Total: 1,
+				Items: []EAT{
+					{ID: 4, EventTitle: "M5.0-Wellington-2026-01-01", Version: 2},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	eat, err := c.GetVersion(5, 2)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if eat == nil {
+		t.Fatal("expected non-nil EAT")
+	}
+	if eat.Version != 2 {
+		t.Errorf("expected version 2, got %d", eat.Version)
+	}
+}
+
+func TestGetVersion_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ListResponse{})
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	eat, err := c.GetVersion(5, 9)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if eat != nil {
+		t.Errorf("expected nil EAT, got version %d", eat.Version)
+	}
+}
+
+func TestListVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("sort"); got != "version" {
+			t.Errorf("unexpected sort: %s", got)
+		}
+
+		resp := ListResponse{
+			Data: ListData{
+				Total: 2,
+				Items: []EAT{
+					{ID: 1, EventTitle: "M5.0-Wellington-2026-01-01", Version: 1},
+					{ID: 2, EventTitle: "M5.0-Wellington-2026-01-01", Version: 2},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	eats, err := c.ListVersions(5)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(eats) != 2 {
+		t.Errorf("expected 2 versions, got %d", len(eats))
+	}
+}
+
 func TestListDistinctEvents(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		resp := ListResponse{
 			Data: ListData{
 				Total: 3,
 				Items: []EAT{
-					{EventTitle: "M5.0-Wellington-2026-01-01"},
-					{EventTitle: "M5.0-Wellington-2026-01-01"},
-					{EventTitle: "M6.0-Kaikoura-2026-01-02"},
+					{EventID: 5, EventTitle: "M5.0-Wellington-2026-01-01", Version: 1},
+					{EventID: 5, EventTitle: "M5.2-Wellington-2026-01-01", Version: 2},
+					{EventID: 6, EventTitle: "M5.0-Wellington-2026-01-01", Version: 1, Exercise: true},
+					{EventTitle: "M6.0-Kaikoura-2026-01-02", Version: 1},
 				},
 			},
 		}
@@ -148,8 +228,65 @@ func TestListDistinctEvents(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ListDistinctEvents failed: %v", err)
 	}
-	if len(events) != 2 {
-		t.Errorf("expected 2 distinct events, got %d", len(events))
+	want := []EventSummary{
+		{ID: 5, Title: "M5.2-Wellington-2026-01-01"},
+		{ID: 6, Title: "M5.0-Wellington-2026-01-01", Exercise: true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d distinct events, got %+v", len(want), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("expected %+v, got %+v", want[i], events[i])
+		}
+	}
+}
+
+func TestFindEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/content/event" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		var resp EventListResponse
+		switch got := r.URL.Query().Get("filter"); got {
+		case `{"public_id":{"$eq":"2026p038412"}}`:
+			resp.Data.Items = []Event{{ID: 5, PublicID: "2026p038412"}}
+		case `{"public_id":{"$eq":"2026p000000"}}`:
+		default:
+			t.Errorf("unexpected filter: %s", got)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	e, err := c.FindEvent("2026p038412")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e == nil || e.ID != 5 {
+		t.Errorf("expected event 5, got %+v", e)
+	}
+
+	e, err = c.FindEvent("2026p000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e != nil {
+		t.Errorf("expected no event, got %+v", e)
+	}
+}
+
+func TestCreateEvent_Conflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"duplicate key value violates unique constraint \"events_public_id_key\""}`))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL).CreateEvent(&Event{PublicID: "2026p038412"})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
 	}
 }
 
@@ -162,12 +299,20 @@ func TestCreateEAT(t *testing.T) {
 			t.Errorf("unexpected auth header: %s", r.Header.Get("Authorization"))
 		}
 
-		var eat EAT
+		var eat struct {
+			EAT
+			Event struct {
+				ID int `json:"id"`
+			} `json:"event"`
+		}
 		json.NewDecoder(r.Body).Decode(&eat)
+		if eat.Event.ID != 5 {
+			t.Errorf("expected the EAT to relate to event 5, got %d", eat.Event.ID)
+		}
 
 		eat.ID = 99
 		eat.CreatedAt = time.Now()
-		resp := SingleResponse{Data: eat}
+		resp := SingleResponse{Data: eat.EAT}
 		json.NewEncoder(w).Encode(resp)
 	}))
 	defer server.Close()
@@ -176,6 +321,7 @@ func TestCreateEAT(t *testing.T) {
 	c.token = "test-token"
 
 	eat := &EAT{
+		EventID:    5,
 		EventTitle: "M5.0-Test-2026-01-01",
 		Location:   "Test",
 		Magnitude:  5.0,
@@ -192,6 +338,160 @@ func TestCreateEAT(t *testing.T) {
 	}
 }
 
+func TestListPending(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := `{"state":{"$eq":"pending_approval"}}`
+		if got := r.URL.Query().Get("filter"); got != want {
+			t.Errorf("unexpected filter: %s", got)
+		}
+
+		resp := ListResponse{
+			Data: ListData{
+				Total: 1,
+				Items: []EAT{
+					{ID: 7, EventTitle: "M5.0-Wellington-2026-01-01", State: StatePendingApproval},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	eats, err := c.ListPending()
+	if err != nil {
+		t.Fatalf("ListPending failed: %v", err)
+	}
+	if len(eats) != 1 {
+		t.Errorf("expected 1 pending EAT, got %d", len(eats))
+	}
+}
+
+func TestUpdateEAT(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+		if r.URL.Path != "/api/content/eat/7" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+
+		var fields map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&fields)
+		if fields["state"] != StatePublished {
+			t.Errorf("unexpected state: %v", fields["state"])
+		}
+
+		json.NewEncoder(w).Encode(SingleResponse{Data: EAT{ID: 7, State: StatePublished, Version: 2}})
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	eat, err := c.UpdateEAT(7, map[string]interface{}{"state": StatePublished, "version": 2})
+	if err != nil {
+		t.Fatalf("UpdateEAT failed: %v", err)
+	}
+	if eat.Version != 2 {
+		t.Errorf("expected version 2, got %d", eat.Version)
+	}
+}
+
+func TestListDrafts(t *testing.T) {
+	const owner = `alice"},"$or":[{"owner":{"$neq":""}}],"x":{"$eq":"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/content/draft" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		// The owner is only ever compared, however it is spelt.
+		var filter map[string]map[string]string
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("filter")), &filter); err != nil || len(filter) != 1 || filter["owner"]["$eq"] != owner {
+			t.Errorf("unexpected filter: %s", r.URL.Query().Get("filter"))
+		}
+
+		var resp DraftListResponse
+		resp.Data.Items = []Draft{{ID: 1, Owner: "alice", Form: json.RawMessage(`{"mode":"new_event"}`)}}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	drafts, err := c.ListDrafts(owner)
+	if err != nil {
+		t.Fatalf("ListDrafts failed: %v", err)
+	}
+	if len(drafts) != 1 || string(drafts[0].Form) != `{"mode":"new_event"}` {
+		t.Errorf("unexpected drafts: %+v", drafts)
+	}
+}
+
+func TestSaveDraft(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path)
+
+		var d Draft
+		json.NewDecoder(r.Body).Decode(&d)
+		if d.ID == 0 {
+			d.ID = 4
+		}
+		json.NewEncoder(w).Encode(DraftResponse{Data: d})
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	d, err := c.SaveDraft(&Draft{Owner: "alice", Form: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatalf("SaveDraft failed: %v", err)
+	}
+	if _, err := c.SaveDraft(d); err != nil {
+		t.Fatalf("SaveDraft failed: %v", err)
+	}
+
+	want := []string{"POST /api/content/draft", "PUT /api/content/draft/4"}
+	if len(methods) != 2 || methods[0] != want[0] || methods[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, methods)
+	}
+}
+
+func TestUpdateEAT_Conflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"duplicate key value violates unique constraint \"eats_version_key_key\""}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	_, err := c.UpdateEAT(7, map[string]interface{}{"version_key": "M5.0-Wellington-2026-01-01#v2"})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestListDueDeliveries(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := `{"status":{"$eq":"pending"},"next_attempt_at":{"$lte":"2026-01-01T12:00:00Z"}}`
+		if got := r.URL.Query().Get("filter"); got != want {
+			t.Errorf("unexpected filter: %s", got)
+		}
+
+		var resp DeliveryListResponse
+		resp.Data.Items = []Delivery{{ID: 1, Recipient: "a@example.com", Status: DeliveryPending}}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	due, err := c.ListDueDeliveries(now)
+	if err != nil {
+		t.Fatalf("ListDueDeliveries failed: %v", err)
+	}
+	if len(due) != 1 {
+		t.Errorf("expected 1 due delivery, got %d", len(due))
+	}
+}
+
 func TestErrorResponse(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -205,3 +505,137 @@ func TestErrorResponse(t *testing.T) {
 		t.Fatal("expected error for 500 response")
 	}
 }
+
+func TestDownloadFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/files/map.png":
+			w.Write([]byte("0123456789"))
+		case "/files/stream.png":
+			// Streamed without a Content-Length
+			w.(http.Flusher).Flush()
+			w.Write([]byte("0123456789"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.token = "test-token"
+
+	b, err := c.DownloadFile(File{Name: "map.png", URL: "/files/map.png"}, 10)
+	if err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if string(b) != "0123456789" {
+		t.Errorf("unexpected content: %s", b)
+	}
+
+	if _, err := c.DownloadFile(File{Name: "map.png", URL: server.URL + "/files/map.png"}, 9); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := c.DownloadFile(File{Name: "stream.png", URL: "/files/stream.png"}, 9); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge for streamed file, got %v", err)
+	}
+	if _, err := c.DownloadFile(File{Name: "missing.png", URL: "/files/missing.png"}, 10); err == nil || errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestDownloadFileOtherHost(t *testing.T) {
+	fs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fs.Close()
+
+	var auth []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Write([]byte("content"))
+	}))
+	defer other.Close()
+
+	c := NewClient(fs.URL)
+	c.token = "test-token"
+
+	// The token isn't sent to another host, even one whose URL starts with
+	// the FastSchema base URL.
+	for _, u := range []string{
+		other.URL + "/files/map.png",
+		fs.URL + "@" + strings.TrimPrefix(other.URL, "http://") + "/files/map.png",
+	} {
+		if _, err := c.DownloadFile(File{Name: "map.png", URL: u}, 10); err != nil {
+			t.Fatalf("%s: %v", u, err)
+		}
+	}
+	for _, a := range auth {
+		if strings.Contains(a, "test-token") {
+			t.Errorf("expected no token sent to another host, got %q", a)
+		}
+	}
+	if len(auth) != 2 {
+		t.Errorf("expected 2 downloads, got %d", len(auth))
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/content/file/7" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(FileResponse{Data: File{ID: 7, Name: "map.png", URL: "/files/map.png"}})
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+
+	f, err := c.GetFile(7)
+	if err != nil {
+		t.Fatalf("GetFile failed: %v", err)
+	}
+	if f.ID != 7 || f.URL != "/files/map.png" {
+		t.Errorf("unexpected file %+v", f)
+	}
+	if _, err := c.GetFile(8); err == nil {
+		t.Error("expected an error for an unknown file")
+	}
+}
+
+func TestAssignEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/content/eat":
+			if got := r.URL.Query().Get("filter"); got != `{"event_id":{"$null":true}}` {
+				t.Errorf("unexpected filter: %s", got)
+			}
+			json.NewEncoder(w).Encode(ListResponse{Data: ListData{Total: 1, Items: []EAT{{ID: 3, EventTitle: "M5.0-Wellington-2026-01-01"}}}})
+		case r.Method == http.MethodPut && r.URL.Path == "/api/content/eat/3":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if event, _ := body["event"].(map[string]interface{}); len(body) != 1 || event["id"] != 5.0 {
+				t.Errorf("expected only the event relation set, got %v", body)
+			}
+			json.NewEncoder(w).Encode(SingleResponse{Data: EAT{ID: 3, EventID: 5}})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+
+	eats, err := c.ListUnassignedEATs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eats) != 1 || eats[0].ID != 3 {
+		t.Errorf("unexpected EATs %+v", eats)
+	}
+	if err := c.AssignEvent(3, 5); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,27 +1,123 @@
 package fastschema
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
 
 // EAT represents an Emergency Advisory Text record.
 type EAT struct {
-	ID                int       `json:"id"`
-	EventTitle        string    `json:"event_title"`
-	Location          string    `json:"location"`
-	EventDate         time.Time `json:"event_date"`
-	Magnitude         float32   `json:"magnitude"`
-	EarthquakeURL     string    `json:"earthquake_url"`
-	Version           int       `json:"version"`
-	EventComments     string    `json:"event_comments"`
-	BeachMarineThreat bool      `json:"beach_marine_threat"`
-	LandThreat        bool      `json:"land_threat"`
-	Status            string    `json:"status"` // "preliminary" or "confirmed"
-	TEPActivated      bool      `json:"tep_activated"`
-	Attachments       []File    `json:"attachments,omitempty"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID                int        `json:"id"`
+	EventID           int        `json:"event_id,omitempty"` // the Event this is a version of
+	EventTitle        string     `json:"event_title"`        // derived from this version's magnitude, location and date
+	Location          string     `json:"location"`
+	EventDate         time.Time  `json:"event_date"`
+	Magnitude         float32    `json:"magnitude"`
+	Latitude          *float64   `json:"latitude,omitempty"`  // epicentre, nil if not known
+	Longitude         *float64   `json:"longitude,omitempty"` // epicentre, nil if not known
+	Depth             *float64   `json:"depth,omitempty"`     // km, nil if not known
+	EarthquakeURL     string     `json:"earthquake_url"`
+	Version           int        `json:"version"`
+	EventComments     string     `json:"event_comments"`
+	BeachMarineThreat bool       `json:"beach_marine_threat"`
+	LandThreat        bool       `json:"land_threat"`
+	Status            string     `json:"status"`                  // see the Status constants
+	CancelReason      string     `json:"cancel_reason,omitempty"` // why the advisory was withdrawn, for cancelled EATs
+	TEPActivated      bool       `json:"tep_activated"`
+	Exercise          bool       `json:"exercise"` // a drill, never to be mistaken for a real advisory
+	Attachments       []File     `json:"attachments,omitempty"`
+	EmailAttachments  []int      `json:"email_attachments,omitempty"` // IDs of the attachments to send with the email
+	BaseVersion       int        `json:"base_version"`                // published version the edit was based on, 0 for a new event
+	VersionKey        string     `json:"version_key,omitempty"`       // unique per event and version once published, see VersionKey
+	State             string     `json:"state,omitempty"`             // see the State constants
+	SubmittedBy       string     `json:"submitted_by,omitempty"`
+	ReviewedBy        string     `json:"reviewed_by,omitempty"`
+	ReviewedAt        *time.Time `json:"reviewed_at,omitempty"`
+	ReviewComment     string     `json:"review_comment,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// EAT statuses. A cancelled EAT is the version that withdraws an advisory
+// issued in error.
+const (
+	StatusPreliminary = "preliminary"
+	StatusConfirmed   = "confirmed"
+	StatusCancelled   = "cancelled"
+)
+
+// EAT lifecycle states. An EAT is submitted by an editor as pending approval
+// and only published, with a version number, once a second user approves it.
+const (
+	StatePendingApproval = "pending_approval"
+	StatePublished       = "published"
+	StateRejected        = "rejected"
+)
+
+// IsPublished reports whether the EAT has been approved for publication. EATs
+// without a state predate the approval workflow and were published directly.
+func (e *EAT) IsPublished() bool {
+	return e.State == "" || e.State == StatePublished
+}
+
+// PublishedAt returns when the EAT was approved for publication, falling back
+// to its creation time for EATs that predate the approval workflow.
+func (e *EAT) PublishedAt() time.Time {
+	if e.ReviewedAt != nil && e.State == StatePublished {
+		return *e.ReviewedAt
+	}
+	return e.CreatedAt
+}
+
+// IsCancelled reports whether the EAT withdraws the event's advisory.
+func (e *EAT) IsCancelled() bool {
+	return e.Status == StatusCancelled
+}
+
+// HasEpicentre reports whether the epicentre coordinates are known.
+func (e *EAT) HasEpicentre() bool {
+	return e.Latitude != nil && e.Longitude != nil
+}
+
+// EmailedAttachments returns the attachments selected to be sent with the
+// email.
+func (e *EAT) EmailedAttachments() []File {
+	var files []File
+	for _, f := range e.Attachments {
+		for _, id := range e.EmailAttachments {
+			if f.ID == id {
+				files = append(files, f)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// VersionKey returns the value of the unique version_key field for an event
+// version. The schema can't express a unique constraint over two fields so
+// the pair is combined into one.
+func VersionKey(eventID, version int) string {
+	return fmt.Sprintf("event/%d#v%d", eventID, version)
+}
+
+// Event is the stable identity of an earthquake that EATs are issued for.
+// The EAT title is derived from each version's fields and can change as the
+// magnitude or location is revised; the event does not.
+type Event struct {
+	ID        int       `json:"id,omitempty"`
+	PublicID  string    `json:"public_id"` // e.g. the GeoNet public ID "2026p038412"
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EventSummary is an event with the title of its latest published version,
+// and whether it is an exercise.
+type EventSummary struct {
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	Exercise bool   `json:"exercise"`
 }
 
 // File represents an attachment stored in FastSchema's object store.
@@ -34,6 +130,52 @@ type File struct {
 	Type string `json:"type,omitempty"` // MIME type
 }
 
+// Draft is an operator's unsubmitted editor form, autosaved so it survives a
+// browser crash. Form holds the editor's field values as the browser sent them.
+type Draft struct {
+	ID         int             `json:"id,omitempty"`
+	Owner      string          `json:"owner"`
+	EventTitle string          `json:"event_title"`
+	Form       json.RawMessage `json:"form"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// Delivery is an outbox job delivering one EAT version's email to one
+// recipient. Pending deliveries are retried until they are sent, or fail
+// when the recipient is rejected.
+type Delivery struct {
+	ID            int               `json:"id,omitempty"`
+	EATID         int               `json:"eat_id"`
+	EventTitle    string            `json:"event_title"`
+	Version       int               `json:"version"`
+	Recipient     string            `json:"recipient"`
+	Status        string            `json:"status"` // see the Delivery constants
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	LastError     string            `json:"last_error,omitempty"`
+	SMTPCode      int               `json:"smtp_code,omitempty"` // reply code of the last attempt
+	Log           []DeliveryAttempt `json:"log,omitempty"`
+	SentAt        *time.Time        `json:"sent_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// Delivery statuses.
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// DeliveryAttempt records one attempt of a Delivery, or an operator resend.
+type DeliveryAttempt struct {
+	At      time.Time `json:"at"`
+	Outcome string    `json:"outcome"` // accepted, rejected, deferred or resend
+	Code    int       `json:"code,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
 // ListResponse wraps the paginated list response from FastSchema.
 type ListResponse struct {
 	Data ListData `json:"data"`
@@ -53,6 +195,45 @@ type SingleResponse struct {
 	Data EAT `json:"data"`
 }
 
+// DraftListResponse wraps a list of drafts from FastSchema.
+type DraftListResponse struct {
+	Data struct {
+		Total int     `json:"total"`
+		Items []Draft `json:"items"`
+	} `json:"data"`
+}
+
+// DraftResponse wraps a single draft response from FastSchema.
+type DraftResponse struct {
+	Data Draft `json:"data"`
+}
+
+// DeliveryListResponse wraps a list of deliveries from FastSchema.
+type DeliveryListResponse struct {
+	Data struct {
+		Total int        `json:"total"`
+		Items []Delivery `json:"items"`
+	} `json:"data"`
+}
+
+// DeliveryResponse wraps a single delivery response from FastSchema.
+type DeliveryResponse struct {
+	Data Delivery `json:"data"`
+}
+
+// EventListResponse wraps a list of events from FastSchema.
+type EventListResponse struct {
+	Data struct {
+		Total int     `json:"total"`
+		Items []Event `json:"items"`
+	} `json:"data"`
+}
+
+// EventResponse wraps a single event response from FastSchema.
+type EventResponse struct {
+	Data Event `json:"data"`
+}
+
 // FileResponse wraps a file upload response from FastSchema.
 type FileResponse struct {
 	Data File `json:"data"`
@@ -25,3 +25,20 @@ func TestFormatEventTitle(t *testing.T) {
 		}
 	}
 }
+
+func TestEmailedAttachments(t *testing.T) {
+	eat := EAT{
+		Attachments:      []File{{ID: 1, Name: "a.png"}, {ID: 2, Name: "b.pdf"}, {ID: 3, Name: "c.txt"}},
+		EmailAttachments: []int{3, 1, 4},
+	}
+
+	files := eat.EmailedAttachments()
+	if len(files) != 2 || files[0].Name != "a.png" || files[1].Name != "c.txt" {
+		t.Errorf("unexpected emailed attachments: %+v", files)
+	}
+
+	eat.EmailAttachments = nil
+	if files := eat.EmailedAttachments(); len(files) != 0 {
+		t.Errorf("expected no emailed attachments, got %+v", files)
+	}
+}
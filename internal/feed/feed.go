@@ -0,0 +1,242 @@
+// Package feed builds Atom and RSS feeds of published EATs.
+package feed
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// Media types for the feeds.
+const (
+	AtomContentType = "application/atom+xml; charset=utf-8"
+	RSSContentType  = "application/rss+xml; charset=utf-8"
+)
+
+const (
+	defaultTitle = "NEMA MAR Portal - Emergency Advisory Texts"
+	author       = "GeoNet National Geohazards Monitoring Centre"
+)
+
+// Options holds the values of a feed that don't come from the EATs.
+type Options struct {
+	Title   string // feed title, defaults to defaultTitle
+	BaseURL string // absolute URL of the app, used to build links, e.g. https://example.com
+	SelfURL string // absolute URL of the feed itself
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+	Links     []atomLink   `xml:"link"`
+	Category  atomCategory `xml:"category"`
+	Summary   string       `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	Category    string  `xml:"category"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Atom returns an Atom feed with one entry per EAT version.
+func Atom(eats []fastschema.EAT, opts Options) ([]byte, error) {
+	eats = sorted(eats)
+
+	f := atomFeed{
+		ID:      uuidURN("feed"),
+		Title:   title(opts),
+		Updated: LastModified(eats).UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: author},
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: opts.SelfURL},
+			{Rel: "alternate", Type: "text/html", Href: opts.BaseURL + "/dashboard"},
+		},
+	}
+
+	for _, e := range eats {
+		f.Entries = append(f.Entries, atomEntry{
+			ID:        EntryID(e.EventID, e.Version),
+			Title:     entryTitle(&e),
+			Updated:   e.PublishedAt().UTC().Format(time.RFC3339),
+			Published: e.PublishedAt().UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Rel: "alternate", Type: "text/html", Href: DashboardURL(opts.BaseURL, &e)}},
+			Category:  atomCategory{Term: e.Status},
+			Summary:   summary(&e),
+		})
+	}
+
+	return marshal(f)
+}
+
+// RSS returns an RSS 2.0 feed with one item per EAT version.
+func RSS(eats []fastschema.EAT, opts Options) ([]byte, error) {
+	eats = sorted(eats)
+
+	f := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       title(opts),
+			Link:        opts.BaseURL + "/dashboard",
+			Description: "Emergency Advisory Texts published by NGMC",
+		},
+	}
+	if len(eats) > 0 {
+		f.Channel.LastBuildDate = LastModified(eats).UTC().Format(time.RFC1123Z)
+	}
+
+	for _, e := range eats {
+		f.Channel.Items = append(f.Channel.Items, rssItem{
+			Title:       entryTitle(&e),
+			Link:        DashboardURL(opts.BaseURL, &e),
+			Description: summary(&e),
+			Category:    e.Status,
+			GUID:        rssGUID{Value: EntryID(e.EventID, e.Version)},
+			PubDate:     e.PublishedAt().UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	return marshal(f)
+}
+
+// EntryID returns the stable feed entry ID for an EAT version, keyed on its
+// event as the title can change between versions.
+func EntryID(eventID, version int) string {
+	return uuidURN(fmt.Sprintf("event/%d/v%d", eventID, version))
+}
+
+// LastModified returns the most recent publication time of eats, or the zero time.
+func LastModified(eats []fastschema.EAT) time.Time {
+	var t time.Time
+	for _, e := range eats {
+		if p := e.PublishedAt(); p.After(t) {
+			t = p
+		}
+	}
+	return t
+}
+
+// DashboardURL returns the dashboard link for an EAT version.
+func DashboardURL(baseURL string, eat *fastschema.EAT) string {
+	return fmt.Sprintf("%s/dashboard?event=%d&version=%d", baseURL, eat.EventID, eat.Version)
+}
+
+// AttachmentURL returns the dashboard link to download one of an EAT
+// version's attachments.
+func AttachmentURL(baseURL string, eat *fastschema.EAT, f fastschema.File) string {
+	return fmt.Sprintf("%s/dashboard/attachment?event=%d&version=%d&file=%d", baseURL, eat.EventID, eat.Version, f.ID)
+}
+
+func title(opts Options) string {
+	if opts.Title != "" {
+		return opts.Title
+	}
+	return defaultTitle
+}
+
+func entryTitle(eat *fastschema.EAT) string {
+	return fmt.Sprintf("%s (Version %d) - %s", eat.EventTitle, eat.Version, eat.Status)
+}
+
+func summary(eat *fastschema.EAT) string {
+	var s strings.Builder
+	if eat.IsCancelled() {
+		fmt.Fprintf(&s, "CANCELLED: %s\n\n", eat.CancelReason)
+	}
+	fmt.Fprintf(&s, "Location: %s\n", eat.Location)
+	fmt.Fprintf(&s, "Event Date: %s\n", eat.EventDate.UTC().Format("2006-01-02 15:04 UTC"))
+	fmt.Fprintf(&s, "Magnitude: %.1f\n", eat.Magnitude)
+	fmt.Fprintf(&s, "Beach/Marine Threat: %s\n", boolStr(eat.BeachMarineThreat))
+	fmt.Fprintf(&s, "Land Threat: %s\n", boolStr(eat.LandThreat))
+	fmt.Fprintf(&s, "TEP Activated: %s\n", boolStr(eat.TEPActivated))
+	if eat.EventComments != "" {
+		fmt.Fprintf(&s, "\n%s\n", eat.EventComments)
+	}
+	return s.String()
+}
+
+// sorted returns a copy of eats, newest first.
+func sorted(eats []fastschema.EAT) []fastschema.EAT {
+	s := make([]fastschema.EAT, len(eats))
+	copy(s, eats)
+	sort.SliceStable(s, func(i, j int) bool {
+		return s[i].PublishedAt().After(s[j].PublishedAt())
+	})
+	return s
+}
+
+// uuidURN returns a name-based (version 5 style) UUID URN for name.
+func uuidURN(name string) string {
+	h := sha1.Sum([]byte("nema-mar-portal:eat:" + name))
+	h[6] = (h[6] & 0x0f) | 0x50
+	h[8] = (h[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	b, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal feed: %w", err)
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+func boolStr(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
@@ -0,0 +1,117 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+var testEATs = []fastschema.EAT{
+	{
+		ID:         1,
+		EventID:    4,
+		EventTitle: "M5.0-Wellington-2026-01-15",
+		Location:   "Wellington",
+		EventDate:  time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC),
+		Magnitude:  5.0,
+		Version:    1,
+		Status:     "preliminary",
+		CreatedAt:  time.Date(2026, 1, 15, 10, 40, 0, 0, time.UTC),
+	},
+	{
+		ID:                2,
+		EventID:           4,
+		EventTitle:        "M5.0-Wellington-2026-01-15",
+		Location:          "Wellington",
+		EventDate:         time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC),
+		Magnitude:         5.0,
+		Version:           2,
+		Status:            "confirmed",
+		BeachMarineThreat: true,
+		CreatedAt:         time.Date(2026, 1, 15, 11, 0, 0, 0, time.UTC),
+	},
+}
+
+func TestAtom(t *testing.T) {
+	b, err := Atom(testEATs, Options{BaseURL: "https://example.com", SelfURL: "https://example.com/feed/atom"})
+	if err != nil {
+		t.Fatalf("Atom failed: %v", err)
+	}
+
+	var f atomFeed
+	if err := xml.Unmarshal(b, &f); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if len(f.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(f.Entries))
+	}
+	// Newest first.
+	if f.Entries[0].ID != EntryID(4, 2) {
+		t.Errorf("unexpected first entry ID: %s", f.Entries[0].ID)
+	}
+	if f.Updated != "2026-01-15T11:00:00Z" {
+		t.Errorf("unexpected updated: %s", f.Updated)
+	}
+	want := "https://example.com/dashboard?event=4&version=2"
+	if f.Entries[0].Links[0].Href != want {
+		t.Errorf("unexpected link: %s", f.Entries[0].Links[0].Href)
+	}
+}
+
+func TestRSS(t *testing.T) {
+	b, err := RSS(testEATs, Options{BaseURL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("RSS failed: %v", err)
+	}
+
+	var f rssFeed
+	if err := xml.Unmarshal(b, &f); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if f.Version != "2.0" {
+		t.Errorf("unexpected version: %s", f.Version)
+	}
+	if len(f.Channel.Items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(f.Channel.Items))
+	}
+	if !strings.Contains(f.Channel.Items[0].Description, "Beach/Marine Threat: Yes") {
+		t.Errorf("unexpected description: %s", f.Channel.Items[0].Description)
+	}
+	if f.Channel.Items[1].GUID.Value != EntryID(4, 1) {
+		t.Errorf("unexpected guid: %s", f.Channel.Items[1].GUID.Value)
+	}
+}
+
+func TestEntryID(t *testing.T) {
+	a := EntryID(4, 1)
+	if a != EntryID(4, 1) {
+		t.Error("entry ID is not stable")
+	}
+	if a == EntryID(4, 2) {
+		t.Error("entry IDs for different versions should differ")
+	}
+	if a == EntryID(5, 1) {
+		t.Error("entry IDs for different events should differ")
+	}
+	if !strings.HasPrefix(a, "urn:uuid:") || len(a) != len("urn:uuid:")+36 {
+		t.Errorf("unexpected entry ID format: %s", a)
+	}
+}
+
+func TestSummaryCancelled(t *testing.T) {
+	eat := testEATs[1]
+	eat.Status = fastschema.StatusCancelled
+	eat.CancelReason = "Issued for the wrong event."
+
+	if s := summary(&eat); !strings.HasPrefix(s, "CANCELLED: Issued for the wrong event.\n\nLocation: Wellington\n") {
+		t.Errorf("expected the summary to lead with the cancellation, got:\n%s", s)
+	}
+	if s := summary(&testEATs[1]); strings.Contains(s, "CANCELLED") {
+		t.Errorf("unexpected cancellation in summary:\n%s", s)
+	}
+}
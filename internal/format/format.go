@@ -0,0 +1,65 @@
+// Package format formats EAT fields for display, so the dashboard, portal and
+// email show them the same way.
+package format
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateInput formats t for a datetime-local form input.
+func DateInput(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04")
+}
+
+// Date formats t for display.
+func Date(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+// Magnitude formats an earthquake magnitude to one decimal place.
+func Magnitude(m float32) string {
+	return fmt.Sprintf("%.1f", m)
+}
+
+// Epicentre formats a latitude and longitude in degrees north or south and
+// east or west, to about a kilometre.
+func Epicentre(lat, lon float64) string {
+	ns, ew := "N", "E"
+	if lat < 0 {
+		ns, lat = "S", -lat
+	}
+	if lon > 180 {
+		lon -= 360
+	}
+	if lon < 0 {
+		ew, lon = "W", -lon
+	}
+	return fmt.Sprintf("%.2f°%s %.2f°%s", lat, ns, lon, ew)
+}
+
+// Depth formats an earthquake depth in km.
+func Depth(km float64) string {
+	return fmt.Sprintf("%.0f km", km)
+}
+
+// YesNo formats a threat or activation flag.
+func YesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+// Funcs returns the formatting functions for use in html/template and
+// text/template templates.
+func Funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"formatDate":        DateInput,
+		"formatDateDisplay": Date,
+		"formatMagnitude":   Magnitude,
+		"formatEpicentre":   Epicentre,
+		"formatDepth":       Depth,
+		"boolYesNo":         YesNo,
+	}
+}
@@ -0,0 +1,36 @@
+package format
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	nz := time.FixedZone("NZDT", 13*3600)
+	d := time.Date(2026, 1, 15, 15, 4, 0, 0, nz)
+
+	if got := DateInput(d); got != "2026-01-15T02:04" {
+		t.Errorf("DateInput: got %s", got)
+	}
+	if got := Date(d); got != "2026-01-15 02:04 UTC" {
+		t.Errorf("Date: got %s", got)
+	}
+	if got := Magnitude(5); got != "5.0" {
+		t.Errorf("Magnitude: got %s", got)
+	}
+	if got := Epicentre(-41.28664, 174.77557); got != "41.29°S 174.78°E" {
+		t.Errorf("Epicentre: got %s", got)
+	}
+	if got := Epicentre(-29.5, -177.5); got != "29.50°S 177.50°W" {
+		t.Errorf("Epicentre west of the antimeridian: got %s", got)
+	}
+	if got := Epicentre(10, 183.5); got != "10.00°N 176.50°W" {
+		t.Errorf("Epicentre past 180°E: got %s", got)
+	}
+	if got := Depth(33.4); got != "33 km" {
+		t.Errorf("Depth: got %s", got)
+	}
+	if YesNo(true) != "Yes" || YesNo(false) != "No" {
+		t.Error("YesNo: expected Yes and No")
+	}
+}
@@ -0,0 +1,209 @@
+// Package maprender draws static epicentre maps on a simplified outline of the
+// New Zealand coastline: SVG for web pages and PNG for the PDF. Maps are drawn
+// server side so they show the event without any third party map service.
+package maprender
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Default map size in pixels.
+const (
+	DefaultWidth  = 500
+	DefaultHeight = 500
+)
+
+// padding is the margin in degrees kept around the epicentre when the map is
+// extended to include it.
+const padding = 3.0
+
+// nzBounds is the extent of the map for epicentres in and near New Zealand.
+var nzBounds = bounds{minLat: -48, maxLat: -34, minLon: 166, maxLon: 179}
+
+// Map colours.
+var (
+	seaColor       = color.RGBA{0xdd, 0xec, 0xf6, 0xff}
+	landColor      = color.RGBA{0xec, 0xe8, 0xdc, 0xff}
+	coastColor     = color.RGBA{0x7a, 0x7a, 0x7a, 0xff}
+	gridColor      = color.RGBA{0xc2, 0xd4, 0xe2, 0xff}
+	epicentreColor = color.RGBA{0xd3, 0x1f, 0x1f, 0xff}
+	haloColor      = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// Point is a WGS 84 location in decimal degrees.
+type Point struct {
+	Lat, Lon float64
+}
+
+// Options control the size and labelling of a map.
+type Options struct {
+	Width, Height int    // pixels, DefaultWidth by DefaultHeight if zero
+	Label         string // drawn beside the epicentre in the SVG, e.g. "M7.1"
+}
+
+//go:embed nz-coastline.geojson
+var coastlineJSON []byte
+
+// coastline is the land polygons, with longitudes in [0, 360) so the
+// Chatham Islands don't wrap around the antimeridian.
+var coastline = mustParseCoastline(coastlineJSON)
+
+// mustParseCoastline reads the polygon outer rings from a GeoJSON feature
+// collection.
+func mustParseCoastline(b []byte) [][]Point {
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Type        string         `json:"type"`
+				Coordinates [][][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(b, &fc); err != nil {
+		panic(fmt.Sprintf("maprender: parse coastline: %v", err))
+	}
+
+	var polygons [][]Point
+	for _, f := range fc.Features {
+		if f.Geometry.Type != "Polygon" || len(f.Geometry.Coordinates) == 0 {
+			panic(fmt.Sprintf("maprender: unsupported coastline geometry %s", f.Geometry.Type))
+		}
+		var ring []Point
+		for _, c := range f.Geometry.Coordinates[0] {
+			ring = append(ring, Point{Lat: c[1], Lon: normLon(c[0])})
+		}
+		polygons = append(polygons, ring)
+	}
+	return polygons
+}
+
+// normLon returns lon in [0, 360).
+func normLon(lon float64) float64 {
+	lon = math.Mod(lon, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon
+}
+
+// Valid reports whether p is a latitude and longitude on the Earth.
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 360 &&
+		!math.IsNaN(p.Lat) && !math.IsNaN(p.Lon)
+}
+
+type bounds struct {
+	minLat, maxLat, minLon, maxLon float64
+}
+
+// projection maps latitude and longitude to pixels with an equirectangular
+// projection, scaled for the middle latitude so shapes aren't stretched.
+type projection struct {
+	b     bounds
+	scale float64 // pixels per degree of latitude
+	k     float64 // cos of the middle latitude
+	w, h  float64
+}
+
+// newProjection fits New Zealand and the epicentre into a w by h map.
+func newProjection(epicentre Point, w, h int) projection {
+	b := nzBounds
+	lon := normLon(epicentre.Lon)
+	b.minLat = math.Max(-90, math.Min(b.minLat, epicentre.Lat-padding))
+	b.maxLat = math.Min(90, math.Max(b.maxLat, epicentre.Lat+padding))
+	b.minLon = math.Min(b.minLon, lon-padding)
+	b.maxLon = math.Max(b.maxLon, lon+padding)
+
+	p := projection{w: float64(w), h: float64(h)}
+	p.k = math.Cos((b.minLat + b.maxLat) / 2 * math.Pi / 180)
+	p.k = math.Max(p.k, 0.2)
+
+	// Grow the shorter side so the map fills the image without distortion.
+	lonSpan := (b.maxLon - b.minLon) * p.k
+	latSpan := b.maxLat - b.minLat
+	p.scale = math.Min(p.w/lonSpan, p.h/latSpan)
+	if extra := p.w/p.scale - lonSpan; extra > 0 {
+		b.minLon -= extra / p.k / 2
+		b.maxLon += extra / p.k / 2
+	}
+	if extra := p.h/p.scale - latSpan; extra > 0 {
+		b.minLat -= extra / 2
+		b.maxLat += extra / 2
+	}
+	p.b = b
+
+	return p
+}
+
+// xy returns the pixel position of a point, from the top left corner.
+func (p projection) xy(pt Point) (x, y float64) {
+	x = (normLon(pt.Lon) - p.b.minLon) * p.k * p.scale
+	y = (p.b.maxLat - pt.Lat) * p.scale
+	return x, y
+}
+
+// gridStep returns the graticule spacing in degrees for the map extent.
+func (p projection) gridStep() float64 {
+	switch span := p.b.maxLat - p.b.minLat; {
+	case span > 60:
+		return 20
+	case span > 25:
+		return 10
+	}
+	return 5
+}
+
+// canvas is drawn on by draw, in pixels.
+type canvas interface {
+	rect(w, h float64, fill color.RGBA)
+	line(x1, y1, x2, y2 float64, stroke color.RGBA)
+	polygon(xs, ys []float64, fill, stroke color.RGBA)
+	circle(x, y, r float64, fill color.RGBA)
+	label(x, y float64, text string)
+}
+
+// draw draws the map on c.
+func draw(c canvas, p projection, epicentre Point, label string) {
+	c.rect(p.w, p.h, seaColor)
+
+	step := p.gridStep()
+	for lat := math.Ceil(p.b.minLat/step) * step; lat <= p.b.maxLat; lat += step {
+		_, y := p.xy(Point{Lat: lat, Lon: p.b.minLon})
+		c.line(0, y, p.w, y, gridColor)
+	}
+	for lon := math.Ceil(p.b.minLon/step) * step; lon <= p.b.maxLon; lon += step {
+		x, _ := p.xy(Point{Lat: p.b.maxLat, Lon: lon})
+		c.line(x, 0, x, p.h, gridColor)
+	}
+
+	for _, ring := range coastline {
+		xs := make([]float64, len(ring))
+		ys := make([]float64, len(ring))
+		for i, pt := range ring {
+			xs[i], ys[i] = p.xy(pt)
+		}
+		c.polygon(xs, ys, landColor, coastColor)
+	}
+
+	x, y := p.xy(epicentre)
+	c.circle(x, y, 9, haloColor)
+	c.circle(x, y, 7, epicentreColor)
+	if label != "" {
+		c.label(x+12, y+5, label)
+	}
+}
+
+func (o Options) size() (w, h int) {
+	w, h = o.Width, o.Height
+	if w <= 0 {
+		w = DefaultWidth
+	}
+	if h <= 0 {
+		h = DefaultHeight
+	}
+	return w, h
+}
@@ -0,0 +1,131 @@
+package maprender
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCoastline(t *testing.T) {
+	if len(coastline) != 4 {
+		t.Fatalf("expected 4 islands, got %d", len(coastline))
+	}
+	for _, ring := range coastline {
+		for _, pt := range ring {
+			if pt.Lat < -48 || pt.Lat > -34 || pt.Lon < 166 || pt.Lon > 184 {
+				t.Fatalf("coastline point %v outside New Zealand", pt)
+			}
+		}
+	}
+}
+
+func TestProjectionIncludesEpicentre(t *testing.T) {
+	for _, epicentre := range []Point{
+		{Lat: -41.29, Lon: 174.78},  // Wellington
+		{Lat: -29.5, Lon: -177.5},   // Kermadec Islands
+		{Lat: -21.1, Lon: -175.2},   // Tonga
+		{Lat: -45.0, Lon: 160.0},    // Tasman Sea
+		{Lat: -54.5, Lon: 158.9},    // Macquarie Island
+		{Lat: -43.95, Lon: -176.55}, // Chatham Islands
+		{Lat: -35.0, Lon: -72.0},    // Chile
+	} {
+		p := newProjection(epicentre, 400, 300)
+		x, y := p.xy(epicentre)
+		if x < 0 || x > 400 || y < 0 || y > 300 {
+			t.Errorf("epicentre %v drawn outside the map at %.0f,%.0f", epicentre, x, y)
+		}
+		// Wellington should always be in view too.
+		x, y = p.xy(Point{Lat: -41.29, Lon: 174.78})
+		if x < 0 || x > 400 || y < 0 || y > 300 {
+			t.Errorf("map for %v doesn't include New Zealand", epicentre)
+		}
+		// The same distance north-south and east-west at the middle
+		// latitude should be the same number of pixels.
+		mid := (p.b.minLat + p.b.maxLat) / 2
+		x1, _ := p.xy(Point{Lat: mid, Lon: 170})
+		x2, _ := p.xy(Point{Lat: mid, Lon: 171})
+		_, y1 := p.xy(Point{Lat: mid, Lon: 170})
+		_, y2 := p.xy(Point{Lat: mid + math.Cos(mid*math.Pi/180), Lon: 170})
+		if math.Abs((x2-x1)-(y1-y2)) > 0.5 && math.Cos(mid*math.Pi/180) > 0.2 {
+			t.Errorf("map for %v is distorted: %.1f px east-west, %.1f north-south", epicentre, x2-x1, y1-y2)
+		}
+	}
+}
+
+func TestPNG(t *testing.T) {
+	wellington := Point{Lat: -41.29, Lon: 174.78}
+	b, err := PNG(wellington, Options{Width: 300, Height: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := img.Bounds().Size(); s.X != 300 || s.Y != 200 {
+		t.Fatalf("expected a 300x200 image, got %v", s)
+	}
+
+	p := newProjection(wellington, 300, 200)
+	at := func(pt Point) color.RGBA {
+		x, y := p.xy(pt)
+		return color.RGBAModel.Convert(img.At(int(x), int(y))).(color.RGBA)
+	}
+
+	if c := at(wellington); c != epicentreColor {
+		t.Errorf("expected the epicentre marker at Wellington, got %v", c)
+	}
+	if c := at(Point{Lat: -39.0, Lon: 175.8}); c != landColor {
+		t.Errorf("expected land at Lake Taupō, got %v", c)
+	}
+	if c := at(Point{Lat: -44.0, Lon: 170.0}); c != landColor {
+		t.Errorf("expected land in Canterbury, got %v", c)
+	}
+	if c := at(Point{Lat: -38.5, Lon: 172.0}); c != seaColor {
+		t.Errorf("expected sea in the Tasman, got %v", c)
+	}
+
+	if _, err := PNG(Point{Lat: 91, Lon: 0}, Options{}); err == nil {
+		t.Error("expected error for an invalid epicentre")
+	}
+}
+
+func TestSVG(t *testing.T) {
+	b, err := SVG(Point{Lat: -29.5, Lon: -177.5}, Options{Label: "M7.1 <Kermadec & Tonga>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var svg struct {
+		Width    int    `xml:"width,attr"`
+		Height   int    `xml:"height,attr"`
+		Title    string `xml:"title"`
+		Polygons []struct {
+			Points string `xml:"points,attr"`
+		} `xml:"polygon"`
+		Circles []struct {
+			Fill string `xml:"fill,attr"`
+		} `xml:"circle"`
+		Text string `xml:"text"`
+	}
+	if err := xml.Unmarshal(b, &svg); err != nil {
+		t.Fatalf("invalid SVG: %v\n%s", err, b)
+	}
+
+	if svg.Width != DefaultWidth || svg.Height != DefaultHeight {
+		t.Errorf("expected the default size, got %dx%d", svg.Width, svg.Height)
+	}
+	if len(svg.Polygons) != 4 {
+		t.Errorf("expected 4 islands, got %d", len(svg.Polygons))
+	}
+	if len(svg.Circles) != 2 || svg.Circles[1].Fill != "#d31f1f" {
+		t.Errorf("expected the epicentre marker, got %+v", svg.Circles)
+	}
+	if svg.Text != "M7.1 <Kermadec & Tonga>" || !strings.Contains(svg.Title, "M7.1") {
+		t.Errorf("expected the escaped label, got text %q, title %q", svg.Text, svg.Title)
+	}
+}
@@ -0,0 +1,107 @@
+package maprender
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+)
+
+// PNG draws the epicentre on the New Zealand coastline as a PNG image. The
+// label isn't drawn; whatever shows the image should caption it.
+func PNG(epicentre Point, opts Options) ([]byte, error) {
+	if !epicentre.Valid() {
+		return nil, fmt.Errorf("invalid epicentre %v", epicentre)
+	}
+
+	w, h := opts.size()
+	c := &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, w, h))}
+	draw(c, newProjection(epicentre, w, h), epicentre, "")
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, fmt.Errorf("encode map: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pngCanvas rasterises the map without anti-aliasing, which is plenty for a
+// locator map.
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func (c *pngCanvas) rect(w, h float64, fill color.RGBA) {
+	b := c.img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c.img.SetRGBA(x, y, fill)
+		}
+	}
+}
+
+// line draws a one pixel wide line by stepping along its longer axis.
+func (c *pngCanvas) line(x1, y1, x2, y2 float64, stroke color.RGBA) {
+	n := int(math.Ceil(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))))
+	if n == 0 {
+		c.set(x1, y1, stroke)
+		return
+	}
+	for i := 0; i <= n; i++ {
+		t := float64(i) / float64(n)
+		c.set(x1+(x2-x1)*t, y1+(y2-y1)*t, stroke)
+	}
+}
+
+// polygon fills with the even-odd rule, sampling at pixel centres, then
+// strokes the outline.
+func (c *pngCanvas) polygon(xs, ys []float64, fill, stroke color.RGBA) {
+	b := c.img.Bounds()
+	var crossings []float64
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		y := float64(py) + 0.5
+		crossings = crossings[:0]
+		for i := range xs {
+			j := (i + 1) % len(xs)
+			if (ys[i] <= y) != (ys[j] <= y) {
+				crossings = append(crossings, xs[i]+(y-ys[i])/(ys[j]-ys[i])*(xs[j]-xs[i]))
+			}
+		}
+		sort.Float64s(crossings)
+		for k := 0; k+1 < len(crossings); k += 2 {
+			start := int(math.Max(math.Ceil(crossings[k]-0.5), float64(b.Min.X)))
+			end := int(math.Min(math.Floor(crossings[k+1]-0.5), float64(b.Max.X-1)))
+			for px := start; px <= end; px++ {
+				c.img.SetRGBA(px, py, fill)
+			}
+		}
+	}
+
+	for i := range xs {
+		j := (i + 1) % len(xs)
+		c.line(xs[i], ys[i], xs[j], ys[j], stroke)
+	}
+}
+
+func (c *pngCanvas) circle(x, y, r float64, fill color.RGBA) {
+	for py := int(y - r); py <= int(y+r); py++ {
+		for px := int(x - r); px <= int(x+r); px++ {
+			dx, dy := float64(px)+0.5-x, float64(py)+0.5-y
+			if dx*dx+dy*dy <= r*r {
+				c.set(float64(px), float64(py), fill)
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) label(x, y float64, text string) {}
+
+func (c *pngCanvas) set(x, y float64, col color.RGBA) {
+	px, py := int(math.Floor(x)), int(math.Floor(y))
+	if (image.Point{X: px, Y: py}).In(c.img.Bounds()) {
+		c.img.SetRGBA(px, py, col)
+	}
+}
@@ -0,0 +1,65 @@
+package maprender
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// SVG draws the epicentre on the New Zealand coastline as an SVG document.
+func SVG(epicentre Point, opts Options) ([]byte, error) {
+	if !epicentre.Valid() {
+		return nil, fmt.Errorf("invalid epicentre %v", epicentre)
+	}
+
+	w, h := opts.size()
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img">`+"\n", w, h, w, h)
+	c.buf.WriteString("<title>Epicentre")
+	if opts.Label != "" {
+		c.buf.WriteString(" of ")
+		xml.EscapeText(&c.buf, []byte(opts.Label))
+	}
+	c.buf.WriteString("</title>\n")
+	draw(c, newProjection(epicentre, w, h), epicentre, opts.Label)
+	c.buf.WriteString("</svg>\n")
+
+	return c.buf.Bytes(), nil
+}
+
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func (c *svgCanvas) rect(w, h float64, fill color.RGBA) {
+	fmt.Fprintf(&c.buf, `<rect width="%.0f" height="%.0f" fill="%s"/>`+"\n", w, h, hex(fill))
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2 float64, stroke color.RGBA) {
+	fmt.Fprintf(&c.buf, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="1"/>`+"\n", x1, y1, x2, y2, hex(stroke))
+}
+
+func (c *svgCanvas) polygon(xs, ys []float64, fill, stroke color.RGBA) {
+	pts := make([]string, len(xs))
+	for i := range xs {
+		pts[i] = fmt.Sprintf("%.1f,%.1f", xs[i], ys[i])
+	}
+	fmt.Fprintf(&c.buf, `<polygon points="%s" fill="%s" stroke="%s" stroke-width="1"/>`+"\n", strings.Join(pts, " "), hex(fill), hex(stroke))
+}
+
+func (c *svgCanvas) circle(x, y, r float64, fill color.RGBA) {
+	fmt.Fprintf(&c.buf, `<circle cx="%.1f" cy="%.1f" r="%.0f" fill="%s"/>`+"\n", x, y, r, hex(fill))
+}
+
+func (c *svgCanvas) label(x, y float64, text string) {
+	fmt.Fprintf(&c.buf, `<text x="%.1f" y="%.1f" font-family="sans-serif" font-size="14" font-weight="bold" fill="%s" stroke="%s" stroke-width="3" paint-order="stroke">`,
+		x, y, hex(epicentreColor), hex(haloColor))
+	xml.EscapeText(&c.buf, []byte(text))
+	c.buf.WriteString("</text>\n")
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,171 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/oidc/oidctest"
+)
+
+func newProvider(t *testing.T, idp *oidctest.IdP) *Provider {
+	t.Helper()
+	p, err := Discover(context.Background(), Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+	})
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	return p
+}
+
+func TestDiscover(t *testing.T) {
+	idp := oidctest.NewIdP("client", "secret")
+	defer idp.Close()
+
+	p := newProvider(t, idp)
+	if p.tokenURL != idp.URL+"/token" {
+		t.Errorf("unexpected token URL: %s", p.tokenURL)
+	}
+
+	_, err := Discover(context.Background(), Config{Issuer: idp.Issuer() + "/other", ClientID: "client"})
+	if err == nil {
+		t.Error("expected error for issuer mismatch")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := oidctest.NewIdP("client", "secret")
+	defer idp.Close()
+
+	p := newProvider(t, idp)
+	u, err := url.Parse(p.AuthCodeURL("https://app/auth/callback", "st", "no", "ch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	for k, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             "client",
+		"redirect_uri":          "https://app/auth/callback",
+		"state":                 "st",
+		"nonce":                 "no",
+		"code_challenge":        "ch",
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if !strings.Contains(q.Get("scope"), "openid") {
+		t.Errorf("scope missing openid: %s", q.Get("scope"))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	idp := oidctest.NewIdP("client", "secret")
+	defer idp.Close()
+	idp.Groups = []string{"ngmc"}
+
+	p := newProvider(t, idp)
+
+	tests := []struct {
+		name    string
+		modify  func(map[string]interface{})
+		token   func(string) string
+		wantErr bool
+	}{
+		{name: "valid"},
+		{name: "audience list", modify: func(c map[string]interface{}) { c["aud"] = []string{"other", "client"} }},
+		{name: "wrong audience", modify: func(c map[string]interface{}) { c["aud"] = "other" }, wantErr: true},
+		{name: "wrong issuer", modify: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, wantErr: true},
+		{name: "expired", modify: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, wantErr: true},
+		{name: "wrong nonce", modify: func(c map[string]interface{}) { c["nonce"] = "other" }, wantErr: true},
+		{name: "tampered", token: func(s string) string {
+			parts := strings.Split(s, ".")
+			return parts[0] + "." + parts[1] + "x." + parts[2]
+		}, wantErr: true},
+		{name: "malformed", token: func(string) string { return "abc" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.Claims("nonce-1")
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			raw := idp.SignToken(claims)
+			if tt.token != nil {
+				raw = tt.token(raw)
+			}
+
+			tok, err := p.Verify(context.Background(), raw, "nonce-1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tok.Username() != "user@example.com" {
+				t.Errorf("unexpected username: %s", tok.Username())
+			}
+			if g := tok.Strings("groups"); len(g) != 1 || g[0] != "ngmc" {
+				t.Errorf("unexpected groups: %v", g)
+			}
+		})
+	}
+}
+
+func TestExchange(t *testing.T) {
+	idp := oidctest.NewIdP("client", "secret")
+	defer idp.Close()
+
+	p := newProvider(t, idp)
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run the authorization request against the IdP to obtain a code.
+	redirect := "https://app/auth/callback"
+	c := idp.Client()
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := c.Get(p.AuthCodeURL(redirect, "st", "nonce-1", challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := loc.Query().Get("code")
+
+	raw, err := p.Exchange(context.Background(), redirect, code, "wrong-verifier")
+	if err == nil {
+		t.Fatal("expected error for wrong PKCE verifier")
+	}
+
+	// Codes are single use, so get a fresh one.
+	resp, err = c.Get(p.AuthCodeURL(redirect, "st", "nonce-1", challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, _ = url.Parse(resp.Header.Get("Location"))
+
+	raw, err = p.Exchange(context.Background(), redirect, loc.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if _, err := p.Verify(context.Background(), raw, "nonce-1"); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package outbox delivers EAT emails from a persisted queue of delivery jobs,
+// retrying deferred deliveries with exponential backoff until each recipient
+// is accepted or rejected.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/email"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// Backoff limits.
+const (
+	MinBackoff = 30 * time.Second
+	MaxBackoff = time.Hour
+)
+
+// DefaultInterval is how often the worker polls for due jobs when it isn't woken.
+const DefaultInterval = 30 * time.Second
+
+// Store persists delivery jobs. *fastschema.Client implements it.
+type Store interface {
+	ListDueDeliveries(now time.Time) ([]fastschema.Delivery, error)
+	UpdateDelivery(d *fastschema.Delivery) error
+}
+
+// Worker attempts due delivery jobs. Jobs live in the Store so they survive
+// restarts. With more than one instance running, an attempt may occasionally
+// be made by two workers at once; a duplicate email is preferred to none.
+type Worker struct {
+	Store    Store
+	Send     func(d *fastschema.Delivery) email.Result // delivers one job
+	Interval time.Duration                             // poll interval, DefaultInterval if zero
+	Now      func() time.Time                          // time.Now if nil
+
+	wake chan struct{}
+}
+
+// NewWorker returns a Worker that uses send to deliver jobs from store.
+func NewWorker(store Store, send func(d *fastschema.Delivery) email.Result) *Worker {
+	return &Worker{
+		Store: store,
+		Send:  send,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Wake makes a running worker check for due jobs now, e.g. after enqueueing.
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes due jobs until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	interval := w.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.RunOnce(); err != nil {
+			log.Printf("outbox: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// RunOnce attempts every job that is due. Outcomes are recorded on the job and
+// deferred jobs rescheduled; the returned error reports problems with the Store.
+func (w *Worker) RunOnce() error {
+	now := w.now()
+
+	due, err := w.Store.ListDueDeliveries(now)
+	if err != nil {
+		return fmt.Errorf("list due deliveries: %w", err)
+	}
+
+	var errs []error
+	for i := range due {
+		d := &due[i]
+		d.Attempts++
+		record(d, w.Send(d), w.now())
+
+		if err := w.Store.UpdateDelivery(d); err != nil {
+			errs = append(errs, fmt.Errorf("update delivery %d: %w", d.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// maxLog is the number of attempts kept in a job's log.
+const maxLog = 20
+
+// record applies the result of an attempt at t to d: accepted jobs are sent,
+// rejected ones failed, and deferred ones are rescheduled after a backoff.
+func record(d *fastschema.Delivery, r email.Result, t time.Time) {
+	d.SMTPCode = r.Code
+	appendLog(d, fastschema.DeliveryAttempt{At: t.UTC(), Outcome: string(r.Outcome), Code: r.Code, Message: r.Message})
+
+	switch r.Outcome {
+	case email.Accepted:
+		d.Status = fastschema.DeliverySent
+		d.SentAt = &t
+		d.LastError = ""
+	case email.Rejected:
+		d.Status = fastschema.DeliveryFailed
+		d.LastError = r.Message
+	default:
+		d.Status = fastschema.DeliveryPending
+		d.LastError = r.Message
+		d.NextAttemptAt = t.Add(Backoff(d.Attempts))
+	}
+}
+
+// Resend makes d due again at t, whatever its status, noting in its log who
+// asked for it.
+func Resend(d *fastschema.Delivery, by string, t time.Time) {
+	d.Status = fastschema.DeliveryPending
+	d.NextAttemptAt = t
+	d.SentAt = nil
+	appendLog(d, fastschema.DeliveryAttempt{At: t.UTC(), Outcome: OutcomeResend, Message: "requested by " + by})
+}
+
+// OutcomeResend marks an operator resend in a delivery's log.
+const OutcomeResend = "resend"
+
+func appendLog(d *fastschema.Delivery, a fastschema.DeliveryAttempt) {
+	d.Log = append(d.Log, a)
+	if len(d.Log) > maxLog {
+		d.Log = d.Log[len(d.Log)-maxLog:]
+	}
+}
+
+func (w *Worker) now() time.Time {
+	if w.Now != nil {
+		return w.Now()
+	}
+	return time.Now()
+}
+
+// Backoff returns the delay before the next attempt of a job that has failed
+// attempts times: MinBackoff doubling each attempt, up to MaxBackoff.
+func Backoff(attempts int) time.Duration {
+	d := MinBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,199 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/email"
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+// memStore is an in-memory Store.
+type memStore struct {
+	mu         sync.Mutex
+	deliveries map[int]fastschema.Delivery
+}
+
+func newMemStore(ds ...fastschema.Delivery) *memStore {
+	s := &memStore{deliveries: make(map[int]fastschema.Delivery)}
+	for _, d := range ds {
+		s.deliveries[d.ID] = d
+	}
+	return s
+}
+
+func (s *memStore) ListDueDeliveries(now time.Time) ([]fastschema.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []fastschema.Delivery
+	for _, d := range s.deliveries {
+		if d.Status == fastschema.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (s *memStore) UpdateDelivery(d *fastschema.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries[d.ID] = *d
+	return nil
+}
+
+func (s *memStore) get(id int) fastschema.Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deliveries[id]
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.expected {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.expected)
+		}
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemStore(
+		fastschema.Delivery{ID: 1, Recipient: "ok@example.com", Status: fastschema.DeliveryPending, NextAttemptAt: now},
+		fastschema.Delivery{ID: 2, Recipient: "down@example.com", Status: fastschema.DeliveryPending, NextAttemptAt: now, Attempts: 2},
+		fastschema.Delivery{ID: 3, Recipient: "later@example.com", Status: fastschema.DeliveryPending, NextAttemptAt: now.Add(time.Minute)},
+		fastschema.Delivery{ID: 4, Recipient: "bad@example.com", Status: fastschema.DeliveryPending, NextAttemptAt: now},
+	)
+
+	var sent []string
+	w := NewWorker(store, func(d *fastschema.Delivery) email.Result {
+		sent = append(sent, d.Recipient)
+		switch d.Recipient {
+		case "down@example.com":
+			return email.Result{Outcome: email.Deferred, Code: 421, Message: "421 service not available"}
+		case "bad@example.com":
+			return email.Result{Outcome: email.Rejected, Code: 550, Message: "550 no such user"}
+		}
+		return email.Result{Outcome: email.Accepted, Code: 250}
+	})
+	w.Now = func() time.Time { return now }
+
+	if err := w.RunOnce(); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	if len(sent) != 3 {
+		t.Errorf("expected 3 attempts, got %v", sent)
+	}
+
+	ok := store.get(1)
+	if ok.Status != fastschema.DeliverySent || ok.SentAt == nil || ok.Attempts != 1 || ok.SMTPCode != 250 {
+		t.Errorf("expected delivery 1 sent, got %+v", ok)
+	}
+
+	down := store.get(2)
+	if down.Status != fastschema.DeliveryPending {
+		t.Errorf("expected delivery 2 pending, got %s", down.Status)
+	}
+	if down.Attempts != 3 || down.LastError != "421 service not available" || down.SMTPCode != 421 {
+		t.Errorf("unexpected delivery 2: %+v", down)
+	}
+	if len(down.Log) != 1 || down.Log[0].Outcome != "deferred" {
+		t.Errorf("unexpected delivery 2 log: %+v", down.Log)
+	}
+	if want := now.Add(2 * time.Minute); !down.NextAttemptAt.Equal(want) {
+		t.Errorf("expected next attempt at %s, got %s", want, down.NextAttemptAt)
+	}
+
+	if later := store.get(3); later.Attempts != 0 {
+		t.Errorf("delivery 3 was attempted before it was due")
+	}
+
+	// Rejected recipients aren't retried.
+	bad := store.get(4)
+	if bad.Status != fastschema.DeliveryFailed || bad.SMTPCode != 550 {
+		t.Errorf("expected delivery 4 failed, got %+v", bad)
+	}
+	w.Now = func() time.Time { return now.Add(24 * time.Hour) }
+	sent = nil
+	if err := w.RunOnce(); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	for _, r := range sent {
+		if r == "bad@example.com" {
+			t.Error("rejected delivery was retried")
+		}
+	}
+}
+
+func TestResend(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	sentAt := now.Add(-time.Hour)
+	d := fastschema.Delivery{Status: fastschema.DeliverySent, SentAt: &sentAt}
+
+	Resend(&d, "editor", now)
+
+	if d.Status != fastschema.DeliveryPending || !d.NextAttemptAt.Equal(now) || d.SentAt != nil {
+		t.Errorf("expected delivery due now, got %+v", d)
+	}
+	if len(d.Log) != 1 || d.Log[0].Outcome != OutcomeResend || d.Log[0].Message != "requested by editor" {
+		t.Errorf("unexpected log: %+v", d.Log)
+	}
+}
+
+func TestRunRetriesUntilSent(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemStore(fastschema.Delivery{ID: 1, Status: fastschema.DeliveryPending, NextAttemptAt: now})
+
+	var mu sync.Mutex
+	failures := 3
+	w := NewWorker(store, func(d *fastschema.Delivery) email.Result {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			return email.Result{Outcome: email.Deferred, Message: "dial smtp: connection refused"}
+		}
+		return email.Result{Outcome: email.Accepted, Code: 250}
+	})
+	// Each poll happens an hour later so backed off jobs are always due.
+	w.Now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(time.Hour)
+		return now
+	}
+	w.Interval = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go w.Run(ctx)
+
+	for store.get(1).Status != fastschema.DeliverySent {
+		select {
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for delivery")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if d := store.get(1); d.Attempts != 4 {
+		t.Errorf("expected 4 attempts, got %d", d.Attempts)
+	}
+}
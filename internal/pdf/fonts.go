@@ -0,0 +1,45 @@
+package pdf
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// The PDF core fonts only cover Windows-1252, which has no te reo Māori
+// macrons, so the PDF embeds the DejaVu fonts instead (see fonts/LICENSE).
+// fpdf subsets them to the glyphs used.
+const (
+	fontFamily = "DejaVuSans"
+	monoFamily = "DejaVuSansMono"
+)
+
+//go:embed fonts/*.ttf
+var fontFiles embed.FS
+
+// fonts lists the embedded font files by family and style.
+var fonts = []struct {
+	family, style, file string
+}{
+	{fontFamily, "", "DejaVuSansCondensed.ttf"},
+	{fontFamily, "B", "DejaVuSansCondensed-Bold.ttf"},
+	{fontFamily, "I", "DejaVuSansCondensed-Oblique.ttf"},
+	{monoFamily, "", "DejaVuSansMono.ttf"},
+}
+
+// newPDF returns an A4 document with the UTF-8 fonts registered.
+func newPDF() (*fpdf.Fpdf, error) {
+	p := fpdf.New("P", "mm", "A4", "")
+	for _, f := range fonts {
+		b, err := fontFiles.ReadFile("fonts/" + f.file)
+		if err != nil {
+			return nil, err
+		}
+		p.AddUTF8FontFromBytes(f.family, f.style, b)
+	}
+	if err := p.Error(); err != nil {
+		return nil, fmt.Errorf("pdf fonts: %w", err)
+	}
+	return p, nil
+}
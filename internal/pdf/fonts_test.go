@@ -0,0 +1,160 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// showText matches the text fpdf draws with Cell and MultiCell.
+var showText = regexp.MustCompile(`BT [\d.-]+ [\d.-]+ Td \(((?:\\.|[^\\)])*)\)Tj ET`)
+
+// text extracts the strings drawn in a PDF, in order. The UTF-8 fonts are
+// written as UTF-16BE strings.
+func text(t *testing.T, b []byte) []string {
+	t.Helper()
+
+	var lines []string
+	for _, m := range showText.FindAllStringSubmatch(content(t, b), -1) {
+		s := unescape(m[1])
+		if len(s)%2 != 0 {
+			t.Fatalf("odd length UTF-16 string %q", s)
+		}
+		u := make([]uint16, len(s)/2)
+		for i := range u {
+			u[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+		}
+		lines = append(lines, string(utf16.Decode(u)))
+	}
+	return lines
+}
+
+// unescape decodes the escapes in a PDF literal string.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseUint(s[i:j], 8, 8)
+			b.WriteByte(byte(n))
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func TestGenerateEATPDFGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		eat   *fastschema.EAT
+		files map[string]string
+	}{
+		{
+			name: "macrons",
+			eat: &fastschema.EAT{
+				EventTitle:    "M6.2-Ōtaki-2026-03-02",
+				Location:      "Ōtaki, Kāpiti Coast",
+				EventDate:     time.Date(2026, 3, 2, 4, 15, 0, 0, time.UTC),
+				Magnitude:     6.2,
+				Version:       1,
+				Status:        "preliminary",
+				EventComments: "Strong shaking felt from Te Whanganui-a-Tara to Kāpiti.\nMove to high ground in Paekākāriki, Waikanae and Ōtaki.\nVowels: ā ē ī ō ū Ā Ē Ī Ō Ū",
+				Attachments: []fastschema.File{
+					{Name: "rārangi.txt", Type: "text/plain"},
+				},
+			},
+			files: map[string]string{
+				"rārangi.txt": "Ngā tai: Kāpiti 0.4 m\nŌtaki 0.5 m",
+			},
+		},
+		{
+			name: "non-latin",
+			eat: &fastschema.EAT{
+				EventTitle:    "M7.8-Tonga-2026-05-20",
+				Location:      "Tonga Trench, near Nukuʻalofa",
+				EventDate:     time.Date(2026, 5, 20, 22, 0, 0, 0, time.UTC),
+				Magnitude:     7.8,
+				Version:       3,
+				Status:        "confirmed",
+				EventComments: "Sāmoa: Tusi faʻasalalau\nΣεισμός — Землетрясение\n“Quoted” – dash … ellipsis",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetch := func(f fastschema.File, max int64) ([]byte, error) {
+				s, ok := tt.files[f.Name]
+				if !ok {
+					return nil, errors.New("not found")
+				}
+				return []byte(s), nil
+			}
+
+			b, err := GenerateEATPDF(tt.eat, Options{Fetch: fetch})
+			if err != nil {
+				t.Fatalf("GenerateEATPDF failed: %v", err)
+			}
+			if bytes.Contains(b, []byte("/BaseFont /Helvetica")) {
+				t.Error("expected only the embedded UTF-8 fonts to be used")
+			}
+
+			got := strings.Join(text(t, b), "\n") + "\n"
+
+			golden(t, tt.name, got)
+		})
+	}
+}
+
+// golden compares got with testdata/<name>.golden, rewriting the file when
+// the -update flag is set.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("PDF text doesn't match %s (run go test -update to regenerate):\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
@@ -2,33 +2,124 @@ package pdf
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+	"github.com/GeoNet/nema-mar-portal/internal/format"
+	"github.com/GeoNet/nema-mar-portal/internal/maprender"
 	"github.com/go-pdf/fpdf"
 )
 
-// GenerateEATPDF creates a PDF representation of an EAT.
-func GenerateEATPDF(eat *fastschema.EAT) ([]byte, error) {
-	p := fpdf.New("P", "mm", "A4", "")
-	p.SetMargins(15, 15, 15)
-	p.AddPage()
+// Limits on the attachments embedded in the PDF. Bigger attachments are only
+// listed by name.
+const (
+	maxImageSize = 10 << 20
+	maxTextSize  = 256 << 10
+)
+
+// Fetch downloads the content of an attachment, failing if it is bigger than
+// max bytes. (*fastschema.Client).DownloadFile satisfies it.
+type Fetch func(f fastschema.File, max int64) ([]byte, error)
+
+// Options control how GenerateEATPDF renders an EAT.
+type Options struct {
+	Layout *Layout // branding, DefaultLayout if nil
+	Fetch  Fetch   // downloads attachments to embed, nil to list every attachment
+
+	// Changes summarises what changed since the previous version, a line
+	// each, or is empty to leave the section out.
+	Changes []string
+}
+
+// Page geometry in mm. The header and footer are drawn in the top and bottom
+// margins on every page.
+const (
+	margin       = 15.0
+	headerHeight = 36.0
+	footerHeight = 25.0
+)
+
+const (
+	pageCount = "{nb}" // replaced with the number of pages
+	logoName  = "logo"
+
+	exerciseMarking   = "EXERCISE EXERCISE EXERCISE" // marking of an exercise EAT if the layout has none
+	exerciseWatermark = "EXERCISE"
+)
+
+// GenerateEATPDF creates a PDF representation of an EAT in the branded
+// layout. Image and text attachments are fetched with opts.Fetch and embedded
+// after the comments so the PDF is a self-contained record of the advisory;
+// other attachments, and any that can't be fetched or decoded, are listed by
+// name.
+func GenerateEATPDF(eat *fastschema.EAT, opts Options) ([]byte, error) {
+	layout := opts.Layout
+	if layout == nil {
+		layout = DefaultLayout()
+	}
+	if eat.Exercise && layout.Marking == "" {
+		l := *layout
+		l.Marking = exerciseMarking
+		layout = &l
+	}
 
-	// Title
-	p.SetFont("Helvetica", "B", 18)
-	p.Cell(0, 10, "Emergency Advisory Text")
-	p.Ln(15)
+	p, err := newPDF()
+	if err != nil {
+		return nil, err
+	}
+	p.SetMargins(margin, headerHeight, margin)
+	p.SetAutoPageBreak(true, footerHeight)
+	p.AliasNbPages(pageCount)
+	if layout.logo != nil {
+		p.RegisterImageOptionsReader(logoName, fpdf.ImageOptions{ImageType: layout.logoType}, bytes.NewReader(layout.logo))
+		if err := p.Error(); err != nil {
+			return nil, fmt.Errorf("pdf logo: %w", err)
+		}
+	}
+	p.SetHeaderFuncMode(func() {
+		if eat.Exercise {
+			watermark(p, layout)
+		}
+		header(p, layout)
+	}, true)
+	p.SetFooterFunc(func() { footer(p, layout, eat) })
+	p.AddPage()
 
 	// Event title
-	p.SetFont("Helvetica", "B", 14)
-	p.Cell(0, 8, eat.EventTitle)
-	p.Ln(12)
+	p.SetFont(fontFamily, "B", 16)
+	p.MultiCell(0, 8, eat.EventTitle, "", "L", false)
+	p.SetFont(fontFamily, "", 10)
+	p.MultiCell(0, 5, versionLine(eat), "", "L", false)
+	p.Ln(4)
+
+	statusBox(p, layout, eat)
+	p.Ln(6)
+
+	// The reason can be long, so it's set like the comments rather than as a
+	// field.
+	if eat.IsCancelled() {
+		p.SetFont(fontFamily, "B", 11)
+		p.Cell(0, 6, "Reason for Cancellation:")
+		p.Ln(7)
+		p.SetFont(fontFamily, "", 10)
+		p.MultiCell(0, 5, eat.CancelReason, "", "L", false)
+		p.Ln(5)
+	}
 
-	// Version and status
-	p.SetFont("Helvetica", "", 11)
-	p.Cell(0, 6, fmt.Sprintf("Version: %d | Status: %s", eat.Version, eat.Status))
-	p.Ln(10)
+	if len(opts.Changes) > 0 {
+		p.SetFont(fontFamily, "B", 11)
+		p.Cell(0, 6, fmt.Sprintf("Changes Since Version %d:", eat.Version-1))
+		p.Ln(7)
+		p.SetFont(fontFamily, "", 10)
+		for _, c := range opts.Changes {
+			p.MultiCell(0, 5, "- "+c, "", "L", false)
+		}
+		p.Ln(5)
+	}
 
 	addField(p, "Location", eat.Location)
 	addField(p, "Event Date (UTC)", eat.EventDate.UTC().Format(time.RFC3339))
@@ -39,35 +130,47 @@ func GenerateEATPDF(eat *fastschema.EAT) ([]byte, error) {
 	addField(p, "Beach/Marine Threat", boolStr(eat.BeachMarineThreat))
 	addField(p, "Land Threat", boolStr(eat.LandThreat))
 	addField(p, "TEP Activated", boolStr(eat.TEPActivated))
+	if eat.HasEpicentre() {
+		addField(p, "Epicentre", format.Epicentre(*eat.Latitude, *eat.Longitude))
+	}
+	if eat.Depth != nil {
+		addField(p, "Depth", format.Depth(*eat.Depth))
+	}
+	if eat.HasEpicentre() {
+		if err := addMap(p, eat); err != nil {
+			return nil, err
+		}
+	}
 
 	// Event comments
 	if eat.EventComments != "" {
 		p.Ln(5)
-		p.SetFont("Helvetica", "B", 11)
+		p.SetFont(fontFamily, "B", 11)
 		p.Cell(0, 6, "Event Comments:")
 		p.Ln(7)
-		p.SetFont("Helvetica", "", 10)
+		p.SetFont(fontFamily, "", 10)
 		p.MultiCell(0, 5, eat.EventComments, "", "L", false)
 	}
 
-	// Attachments list
-	if len(eat.Attachments) > 0 {
+	// Attachments: images and text embedded, the rest listed
+	var listed []fastschema.File
+	for i, a := range eat.Attachments {
+		if !embedAttachment(p, opts.Fetch, a, i) {
+			listed = append(listed, a)
+		}
+	}
+	if len(listed) > 0 {
 		p.Ln(5)
-		p.SetFont("Helvetica", "B", 11)
+		p.SetFont(fontFamily, "B", 11)
 		p.Cell(0, 6, "Attachments:")
 		p.Ln(7)
-		p.SetFont("Helvetica", "", 10)
-		for _, a := range eat.Attachments {
+		p.SetFont(fontFamily, "", 10)
+		for _, a := range listed {
 			p.Cell(0, 5, fmt.Sprintf("- %s (%s)", a.Name, a.Type))
 			p.Ln(6)
 		}
 	}
 
-	// Footer
-	p.Ln(10)
-	p.SetFont("Helvetica", "I", 8)
-	p.Cell(0, 4, fmt.Sprintf("Generated: %s", time.Now().UTC().Format(time.RFC3339)))
-
 	var buf bytes.Buffer
 	if err := p.Output(&buf); err != nil {
 		return nil, fmt.Errorf("pdf output: %w", err)
@@ -76,10 +179,275 @@ func GenerateEATPDF(eat *fastschema.EAT) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// header draws the marking, logo, agency and title at the top of a page.
+func header(p *fpdf.Fpdf, l *Layout) {
+	pageW, _ := p.GetPageSize()
+
+	if l.Marking != "" {
+		setTextColor(p, l.MarkingColor)
+		p.SetFont(fontFamily, "B", 10)
+		p.SetXY(margin, 5)
+		p.CellFormat(pageW-2*margin, 5, l.Marking, "", 0, "C", false, 0, "")
+	}
+
+	x := margin
+	if l.logo != nil {
+		const logoH = 16.0
+		p.ImageOptions(logoName, margin, 11, 0, logoH, false, fpdf.ImageOptions{ImageType: l.logoType}, 0, "")
+		w, h := p.GetImageInfo(logoName).Extent()
+		x += w*logoH/h + 4
+	}
+
+	setTextColor(p, l.Accent)
+	p.SetFont(fontFamily, "B", 10)
+	p.SetXY(x, 11)
+	p.CellFormat(pageW-margin-x, 6, l.Agency, "", 2, "L", false, 0, "")
+	p.SetFont(fontFamily, "B", 18)
+	p.CellFormat(pageW-margin-x, 10, l.Title, "", 0, "L", false, 0, "")
+
+	setDrawColor(p, l.Accent)
+	p.SetLineWidth(0.8)
+	p.Line(margin, 30, pageW-margin, 30)
+
+	p.SetTextColor(0, 0, 0)
+	p.SetLineWidth(0.2)
+}
+
+// watermark draws a faint diagonal EXERCISE across the page, under the
+// content, so no page of an exercise can be taken for a real advisory.
+func watermark(p *fpdf.Fpdf, l *Layout) {
+	pageW, pageH := p.GetPageSize()
+
+	p.SetFont(fontFamily, "B", 96)
+	w := p.GetStringWidth(exerciseWatermark)
+
+	p.TransformBegin()
+	p.TransformRotate(45, pageW/2, pageH/2)
+	p.SetAlpha(0.15, "Normal")
+	setTextColor(p, l.MarkingColor)
+	p.SetXY(pageW/2-w/2, pageH/2-15)
+	p.CellFormat(w, 30, exerciseWatermark, "", 0, "C", false, 0, "")
+	p.SetAlpha(1, "Normal")
+	p.TransformEnd()
+
+	p.SetTextColor(0, 0, 0)
+}
+
+// footer draws the version, page number, contact and marking at the bottom
+// of a page.
+func footer(p *fpdf.Fpdf, l *Layout, eat *fastschema.EAT) {
+	pageW, pageH := p.GetPageSize()
+	w := pageW - 2*margin
+
+	setDrawColor(p, l.Accent)
+	p.Line(margin, pageH-footerHeight+5, pageW-margin, pageH-footerHeight+5)
+
+	p.SetTextColor(80, 80, 80)
+	p.SetFont(fontFamily, "", 8)
+	p.SetXY(margin, pageH-footerHeight+6)
+	p.CellFormat(w, 4, footerLine(eat), "", 0, "L", false, 0, "")
+	p.SetX(margin)
+	p.CellFormat(w, 4, fmt.Sprintf("Page %d of %s", p.PageNo(), pageCount), "", 2, "R", false, 0, "")
+	if l.Contact != "" {
+		p.SetX(margin)
+		p.CellFormat(w, 4, l.Contact, "", 2, "L", false, 0, "")
+	}
+
+	if l.Marking != "" {
+		setTextColor(p, l.MarkingColor)
+		p.SetFont(fontFamily, "B", 10)
+		p.SetXY(margin, pageH-9)
+		p.CellFormat(w, 5, l.Marking, "", 0, "C", false, 0, "")
+	}
+
+	p.SetTextColor(0, 0, 0)
+}
+
+// statusBox draws the status and threat summary, filled with the colour of
+// the most severe threat. A cancelled EAT has its own colour.
+func statusBox(p *fpdf.Fpdf, l *Layout, eat *fastschema.EAT) {
+	if eat.IsCancelled() {
+		setFillColor(p, l.Colors.Cancelled)
+	} else {
+		setFillColor(p, l.statusColor(eat.LandThreat, eat.BeachMarineThreat))
+	}
+	p.SetTextColor(255, 255, 255)
+
+	p.SetFont(fontFamily, "B", 14)
+	p.CellFormat(0, 9, strings.ToUpper(eat.Status), "", 2, "C", true, 0, "")
+	p.SetFont(fontFamily, "B", 11)
+	p.CellFormat(0, 7, threatLine(eat), "", 1, "C", true, 0, "")
+
+	p.SetTextColor(0, 0, 0)
+}
+
+// threatLine summarises the threats, worded as in the email.
+func threatLine(eat *fastschema.EAT) string {
+	if eat.IsCancelled() {
+		return "THIS ADVISORY HAS BEEN WITHDRAWN"
+	}
+	var threats []string
+	if eat.BeachMarineThreat {
+		threats = append(threats, "BEACH AND MARINE")
+	}
+	if eat.LandThreat {
+		threats = append(threats, "LAND")
+	}
+	if len(threats) == 0 {
+		return "NO THREAT"
+	}
+	return "THREAT: " + strings.Join(threats, " AND ")
+}
+
+// versionLine describes the version, when it was issued and what it replaces.
+func versionLine(eat *fastschema.EAT) string {
+	parts := []string{fmt.Sprintf("Version %d", eat.Version)}
+	if t := eat.PublishedAt(); !t.IsZero() {
+		parts = append(parts, "Issued "+format.Date(t))
+	}
+	if eat.Version > 1 {
+		parts = append(parts, fmt.Sprintf("Supersedes version %d", eat.Version-1))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// footerLine identifies the EAT version on every page.
+func footerLine(eat *fastschema.EAT) string {
+	s := fmt.Sprintf("%s · Version %d", eat.EventTitle, eat.Version)
+	if eat.Version > 1 {
+		s += fmt.Sprintf(" (supersedes version %d)", eat.Version-1)
+	}
+	return s
+}
+
+// setTextColor, setDrawColor and setFillColor take the #rrggbb colours of the
+// layout, which are validated when it's parsed.
+func setTextColor(p *fpdf.Fpdf, c string) {
+	r, g, b, _ := parseColor(c)
+	p.SetTextColor(r, g, b)
+}
+
+func setDrawColor(p *fpdf.Fpdf, c string) {
+	r, g, b, _ := parseColor(c)
+	p.SetDrawColor(r, g, b)
+}
+
+func setFillColor(p *fpdf.Fpdf, c string) {
+	r, g, b, _ := parseColor(c)
+	p.SetFillColor(r, g, b)
+}
+
+// imageTypes maps the image content types fpdf can embed to its image types.
+var imageTypes = map[string]string{
+	"image/png":  "PNG",
+	"image/jpeg": "JPG",
+	"image/gif":  "GIF",
+}
+
+// embedAttachment fetches a and adds it to the PDF, scaled to fit the page
+// for images or inline for text. It reports whether a was embedded.
+func embedAttachment(p *fpdf.Fpdf, fetch Fetch, a fastschema.File, i int) bool {
+	if fetch == nil {
+		return false
+	}
+
+	switch {
+	case imageTypes[a.Type] != "":
+		data, err := fetch(a, maxImageSize)
+		if err != nil {
+			return false
+		}
+		// Trust the content over the declared type, fpdf can't decode a
+		// mislabelled image.
+		imageType := imageTypes[http.DetectContentType(data)]
+		if imageType == "" {
+			return false
+		}
+		return addImage(p, fmt.Sprintf("attachment-%d", i), imageType, data, a.Name, 0)
+	case strings.HasPrefix(a.Type, "text/"):
+		data, err := fetch(a, maxTextSize)
+		if err != nil {
+			return false
+		}
+		addText(p, a.Name, string(data))
+		return true
+	}
+
+	return false
+}
+
+// addImage draws an image scaled to fit the page, and no wider than maxW mm if
+// that's set, below the current position. It starts a new page if the image
+// doesn't fit in what's left of this one, and adds caption underneath.
+func addImage(p *fpdf.Fpdf, name, imageType string, data []byte, caption string, maxW float64) bool {
+	opts := fpdf.ImageOptions{ImageType: imageType, ReadDpi: true}
+	info := p.RegisterImageOptionsReader(name, opts, bytes.NewReader(data))
+	if p.Err() {
+		// fpdf errors are sticky, clear it so the rest of the PDF renders.
+		p.ClearError()
+		return false
+	}
+
+	pageW, pageH := p.GetPageSize()
+	left, top, right, bottom := p.GetMargins()
+	const captionH = 10
+	contentW := pageW - left - right
+	if maxW <= 0 || maxW > contentW {
+		maxW = contentW
+	}
+	maxH := pageH - top - bottom - captionH
+
+	w, h := info.Extent()
+	if w > maxW {
+		w, h = maxW, h*maxW/w
+	}
+	if h > maxH {
+		w, h = w*maxH/h, maxH
+	}
+
+	p.Ln(5)
+	if p.GetY()+h+captionH > pageH-bottom {
+		p.AddPage()
+	}
+	p.ImageOptions(name, left+(contentW-w)/2, p.GetY(), w, h, false, opts, 0, "")
+	p.SetY(p.GetY() + h + 2)
+	p.SetFont(fontFamily, "I", 9)
+	p.CellFormat(0, 5, caption, "", 1, "C", false, 0, "")
+
+	return true
+}
+
+// mapWidth is the width of the epicentre map in mm.
+const mapWidth = 110
+
+// addMap draws the epicentre on the New Zealand coastline.
+func addMap(p *fpdf.Fpdf, eat *fastschema.EAT) error {
+	epicentre := maprender.Point{Lat: *eat.Latitude, Lon: *eat.Longitude}
+	png, err := maprender.PNG(epicentre, maprender.Options{Width: 600, Height: 480})
+	if err != nil {
+		return fmt.Errorf("epicentre map: %w", err)
+	}
+	caption := "Epicentre " + format.Epicentre(epicentre.Lat, epicentre.Lon)
+	if !addImage(p, "epicentre-map", "PNG", png, caption, mapWidth) {
+		return errors.New("epicentre map: not a valid PNG")
+	}
+	return nil
+}
+
+// addText adds the content of a text attachment under its name.
+func addText(p *fpdf.Fpdf, name, text string) {
+	p.Ln(5)
+	p.SetFont(fontFamily, "B", 11)
+	p.Cell(0, 6, name+":")
+	p.Ln(7)
+	p.SetFont(monoFamily, "", 9)
+	p.MultiCell(0, 4, strings.ReplaceAll(text, "\r\n", "\n"), "", "L", false)
+}
+
 func addField(p *fpdf.Fpdf, label, value string) {
-	p.SetFont("Helvetica", "B", 10)
+	p.SetFont(fontFamily, "B", 10)
 	p.Cell(50, 6, label+":")
-	p.SetFont("Helvetica", "", 10)
+	p.SetFont(fontFamily, "", 10)
 	p.Cell(0, 6, value)
 	p.Ln(7)
 }
@@ -1,6 +1,19 @@
 package pdf
 
 import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,7 +40,7 @@ func TestGenerateEATPDF(t *testing.T) {
 		},
 	}
 
-	pdfBytes, err := GenerateEATPDF(eat)
+	pdfBytes, err := GenerateEATPDF(eat, Options{})
 	if err != nil {
 		t.Fatalf("GenerateEATPDF failed: %v", err)
 	}
@@ -51,7 +64,7 @@ func TestGenerateEATPDF_MinimalData(t *testing.T) {
 		Status:     "confirmed",
 	}
 
-	pdfBytes, err := GenerateEATPDF(eat)
+	pdfBytes, err := GenerateEATPDF(eat, Options{})
 	if err != nil {
 		t.Fatalf("GenerateEATPDF failed: %v", err)
 	}
@@ -60,3 +73,293 @@ func TestGenerateEATPDF_MinimalData(t *testing.T) {
 		t.Fatal("expected non-empty PDF bytes")
 	}
 }
+
+// content returns the decompressed streams of a PDF, enough to search for the
+// drawing operators and text fpdf writes.
+func content(t *testing.T, b []byte) string {
+	t.Helper()
+
+	var out strings.Builder
+	for _, m := range regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllSubmatch(b, -1) {
+		r, err := zlib.NewReader(bytes.NewReader(m[1]))
+		if err != nil {
+			out.Write(m[1])
+			continue
+		}
+		d, err := io.ReadAll(r)
+		if err != nil {
+			continue
+		}
+		out.Write(d)
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
+
+func encodeImage(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.White, color.RGBA{R: 200, A: 255}})
+	for x := 0; x < w; x++ {
+		img.SetColorIndex(x, x%h, 1)
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerateEATPDFEmbedsAttachments(t *testing.T) {
+	files := map[string][]byte{
+		"map.png":       encodeImage(t, "png", 3000, 200),
+		"photo.jpg":     encodeImage(t, "jpeg", 40, 30),
+		"tide.gif":      encodeImage(t, "gif", 50, 50),
+		"readings.txt":  []byte("Gauge readings\r\nNapier 0.3 m"),
+		"mislabel.png":  []byte("not an image"),
+		"notfound.png":  nil,
+		"too-large.txt": nil,
+		"report.pdf":    []byte("%PDF-1.4"),
+	}
+
+	var fetched []string
+	fetch := func(f fastschema.File, max int64) ([]byte, error) {
+		fetched = append(fetched, f.Name)
+		switch f.Name {
+		case "notfound.png":
+			return nil, errors.New("404 Not Found")
+		case "too-large.txt":
+			return nil, fastschema.ErrTooLarge
+		}
+		if int64(len(files[f.Name])) > max {
+			return nil, fastschema.ErrTooLarge
+		}
+		return files[f.Name], nil
+	}
+
+	eat := &fastschema.EAT{
+		EventTitle:    "M7.1-Kermadec Islands-2026-01-15",
+		Version:       2,
+		Status:        "confirmed",
+		EventComments: "See the attached map.",
+		Attachments: []fastschema.File{
+			{Name: "map.png", Type: "image/png"},
+			{Name: "photo.jpg", Type: "image/jpeg"},
+			{Name: "tide.gif", Type: "image/gif"},
+			{Name: "readings.txt", Type: "text/plain"},
+			{Name: "mislabel.png", Type: "image/png"},
+			{Name: "notfound.png", Type: "image/png"},
+			{Name: "too-large.txt", Type: "text/plain"},
+			{Name: "report.pdf", Type: "application/pdf"},
+		},
+	}
+
+	b, err := GenerateEATPDF(eat, Options{Fetch: fetch})
+	if err != nil {
+		t.Fatalf("GenerateEATPDF failed: %v", err)
+	}
+
+	if n := bytes.Count(b, []byte("/Subtype /Image")); n != 3 {
+		t.Errorf("expected 3 embedded images, got %d", n)
+	}
+	if slices.Contains(fetched, "report.pdf") {
+		t.Error("expected the PDF attachment not to be fetched")
+	}
+
+	c := content(t, b)
+
+	// Images are scaled to the width between the margins: 180mm in points.
+	const maxW = 180 * 72 / 25.4
+	draws := regexp.MustCompile(`q ([\d.]+) 0 0 ([\d.]+) [\d.-]+ [\d.-]+ cm /I`).FindAllStringSubmatch(c, -1)
+	if len(draws) != 3 {
+		t.Fatalf("expected 3 images drawn, got %d", len(draws))
+	}
+	w, _ := strconv.ParseFloat(draws[0][1], 64)
+	h, _ := strconv.ParseFloat(draws[0][2], 64)
+	if w > maxW+0.01 || w < maxW-0.01 {
+		t.Errorf("expected the wide map scaled to %.2fpt, got %.2f", maxW, w)
+	}
+	if r := w / h; r < 14.9 || r > 15.1 {
+		t.Errorf("expected the map's aspect ratio kept, got %.2f", r)
+	}
+
+	lines := text(t, b)
+	for _, want := range []string{
+		"map.png",
+		"photo.jpg",
+		"tide.gif",
+		"readings.txt:",
+		"Gauge readings",
+		"Napier 0.3 m",
+		"- mislabel.png (image/png)",
+		"- notfound.png (image/png)",
+		"- too-large.txt (text/plain)",
+		"- report.pdf (application/pdf)",
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("expected PDF text to contain %q", want)
+		}
+	}
+	for _, notWant := range []string{"- map.png (image/png)", "- readings.txt (text/plain)"} {
+		if slices.Contains(lines, notWant) {
+			t.Errorf("expected embedded attachment not to be listed: %q", notWant)
+		}
+	}
+}
+
+func TestGenerateEATPDFEpicentreMap(t *testing.T) {
+	lat, lon, depth := -41.28664, 174.77557, 33.0
+	eat := &fastschema.EAT{
+		EventTitle: "M5.0-Wellington-2026-01-15",
+		Location:   "Wellington",
+		Magnitude:  5.0,
+		Version:    1,
+		Status:     "preliminary",
+		Latitude:   &lat,
+		Longitude:  &lon,
+		Depth:      &depth,
+	}
+
+	b, err := GenerateEATPDF(eat, Options{})
+	if err != nil {
+		t.Fatalf("GenerateEATPDF failed: %v", err)
+	}
+	if n := bytes.Count(b, []byte("/Subtype /Image")); n != 1 {
+		t.Errorf("expected the map embedded, got %d images", n)
+	}
+
+	lines := text(t, b)
+	for _, want := range []string{"Epicentre:", "41.29°S 174.78°E", "Depth:", "33 km", "Epicentre 41.29°S 174.78°E"} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("expected PDF text to contain %q, got %q", want, lines)
+		}
+	}
+
+	// Without coordinates there's no map.
+	eat.Latitude, eat.Longitude = nil, nil
+	if b, err = GenerateEATPDF(eat, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("/Subtype /Image")) {
+		t.Error("expected no map without an epicentre")
+	}
+}
+
+func TestGenerateEATPDFCancelled(t *testing.T) {
+	eat := &fastschema.EAT{
+		EventTitle:   "M5.0-Wellington-2026-01-15",
+		Location:     "Wellington",
+		Magnitude:    5.0,
+		Version:      2,
+		Status:       fastschema.StatusCancelled,
+		CancelReason: "Issued for the wrong event.",
+		LandThreat:   true,
+	}
+
+	b, err := GenerateEATPDF(eat, Options{})
+	if err != nil {
+		t.Fatalf("GenerateEATPDF failed: %v", err)
+	}
+
+	lines := text(t, b)
+	for _, want := range []string{"CANCELLED", "THIS ADVISORY HAS BEEN WITHDRAWN", "Reason for Cancellation:", "Issued for the wrong event."} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("expected PDF text to contain %q, got %q", want, lines)
+		}
+	}
+	if slices.Contains(lines, "THREAT: LAND") {
+		t.Error("expected no threat line on a cancellation")
+	}
+
+	// The status box is filled with the cancelled colour, #424242 (a grey, so
+	// written with the g operator), not the land threat colour.
+	if c := content(t, b); !strings.Contains(c, "0.259 g") || strings.Contains(c, "0.718 0.110 0.110 rg") {
+		t.Error("expected the status box in the cancelled colour")
+	}
+}
+
+func TestGenerateEATPDFExercise(t *testing.T) {
+	eat := &fastschema.EAT{
+		EventTitle: "M5.0-Wellington-2026-01-15",
+		Location:   "Wellington",
+		Magnitude:  5.0,
+		Version:    1,
+		Status:     "preliminary",
+		Exercise:   true,
+	}
+
+	b, err := GenerateEATPDF(eat, Options{})
+	if err != nil {
+		t.Fatalf("GenerateEATPDF failed: %v", err)
+	}
+
+	lines := text(t, b)
+	for _, want := range []string{"EXERCISE", "EXERCISE EXERCISE EXERCISE"} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("expected PDF text to contain %q, got %q", want, lines)
+		}
+	}
+	if !strings.Contains(content(t, b), " cm") {
+		t.Error("expected the watermark to be rotated")
+	}
+
+	// A layout's own marking is kept.
+	l := DefaultLayout()
+	l.Marking = "RESTRICTED"
+	if b, err = GenerateEATPDF(eat, Options{Layout: l}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := text(t, b); !slices.Contains(lines, "RESTRICTED") || slices.Contains(lines, "EXERCISE EXERCISE EXERCISE") {
+		t.Errorf("expected the layout marking, got %q", lines)
+	}
+	if l.Marking != "RESTRICTED" {
+		t.Error("expected the layout to be unchanged")
+	}
+
+	eat.Exercise = false
+	if b, err = GenerateEATPDF(eat, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := text(t, b); slices.Contains(lines, "EXERCISE") {
+		t.Errorf("expected no watermark, got %q", lines)
+	}
+}
+
+func TestGenerateEATPDFChanges(t *testing.T) {
+	eat := &fastschema.EAT{
+		EventTitle: "M5.0-Wellington-2026-01-15",
+		Location:   "Wellington",
+		Magnitude:  5.0,
+		Version:    4,
+		Status:     "confirmed",
+	}
+
+	b, err := GenerateEATPDF(eat, Options{Changes: []string{"Status: preliminary → confirmed", "Land Threat: No → Yes"}})
+	if err != nil {
+		t.Fatalf("GenerateEATPDF failed: %v", err)
+	}
+
+	lines := text(t, b)
+	for _, want := range []string{"Changes Since Version 3:", "- Status: preliminary → confirmed", "- Land Threat: No → Yes"} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("expected PDF text to contain %q, got %q", want, lines)
+		}
+	}
+
+	if b, err = GenerateEATPDF(eat, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(text(t, b), "Changes Since Version 3:") {
+		t.Error("expected no changes section without changes")
+	}
+}
@@ -0,0 +1,135 @@
+package pdf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Layout is the branding of the EAT PDF. It's loaded from a JSON template
+// config so comms can adjust it without a code change.
+type Layout struct {
+	Agency       string `json:"agency"`         // header line naming the issuing agency
+	Title        string `json:"title"`          // document title under the agency
+	Logo         string `json:"logo,omitempty"` // PNG or JPEG file, relative to the config file
+	Accent       string `json:"accent"`         // #rrggbb colour of the header rule and headings
+	Contact      string `json:"contact,omitempty"`
+	Colors       Colors `json:"colors"`
+	Marking      string `json:"marking,omitempty"`       // classification or "EXERCISE" shown on every page, empty for none
+	MarkingColor string `json:"marking_color,omitempty"` // #rrggbb colour of the marking
+
+	logo     []byte
+	logoType string
+}
+
+// Colors are the #rrggbb fill colours of the status box, by the most severe
+// threat in the EAT, or Cancelled for a cancelled EAT.
+type Colors struct {
+	LandThreat        string `json:"land_threat"`
+	BeachMarineThreat string `json:"beach_marine_threat"`
+	NoThreat          string `json:"no_threat"`
+	Cancelled         string `json:"cancelled"`
+}
+
+// DefaultLayout returns the layout used when no template config is set.
+func DefaultLayout() *Layout {
+	return &Layout{
+		Agency: "GeoNet | National Geohazards Monitoring Centre",
+		Title:  "Emergency Advisory Text",
+		Accent: "#1f3864",
+		Colors: Colors{
+			LandThreat:        "#b71c1c",
+			BeachMarineThreat: "#e65100",
+			NoThreat:          "#2e7d32",
+			Cancelled:         "#424242",
+		},
+		MarkingColor: "#b71c1c",
+	}
+}
+
+// LoadLayout reads a layout from a JSON file. Fields left out keep their
+// DefaultLayout values.
+func LoadLayout(path string) (*Layout, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLayout(b, filepath.Dir(path))
+}
+
+// ParseLayout parses and validates a JSON layout. The logo is read relative
+// to dir.
+func ParseLayout(b []byte, dir string) (*Layout, error) {
+	l := DefaultLayout()
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(l); err != nil {
+		return nil, fmt.Errorf("parse layout: %w", err)
+	}
+
+	if l.Title == "" {
+		return nil, errors.New("layout has no title")
+	}
+	for name, c := range map[string]string{
+		"accent":                     l.Accent,
+		"marking_color":              l.MarkingColor,
+		"colors.land_threat":         l.Colors.LandThreat,
+		"colors.beach_marine_threat": l.Colors.BeachMarineThreat,
+		"colors.no_threat":           l.Colors.NoThreat,
+		"colors.cancelled":           l.Colors.Cancelled,
+	} {
+		if _, _, _, err := parseColor(c); err != nil {
+			return nil, fmt.Errorf("layout %s: %w", name, err)
+		}
+	}
+
+	if l.Logo != "" {
+		logo := l.Logo
+		if !filepath.IsAbs(logo) {
+			logo = filepath.Join(dir, logo)
+		}
+		data, err := os.ReadFile(logo)
+		if err != nil {
+			return nil, fmt.Errorf("layout logo: %w", err)
+		}
+		switch http.DetectContentType(data) {
+		case "image/png":
+			l.logoType = "PNG"
+		case "image/jpeg":
+			l.logoType = "JPG"
+		default:
+			return nil, fmt.Errorf("layout logo %s is not a PNG or JPEG", l.Logo)
+		}
+		l.logo = data
+	}
+
+	return l, nil
+}
+
+// statusColor returns the status box colour for the most severe threat.
+func (l *Layout) statusColor(land, beachMarine bool) string {
+	switch {
+	case land:
+		return l.Colors.LandThreat
+	case beachMarine:
+		return l.Colors.BeachMarineThreat
+	}
+	return l.Colors.NoThreat
+}
+
+// parseColor parses a #rrggbb colour.
+func parseColor(s string) (r, g, b int, err error) {
+	if len(s) != 7 || s[0] != '#' {
+		return 0, 0, 0, fmt.Errorf("invalid colour %q, expected #rrggbb", s)
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid colour %q, expected #rrggbb", s)
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff), nil
+}
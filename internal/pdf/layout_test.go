@@ -0,0 +1,121 @@
+package pdf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GeoNet/nema-mar-portal/internal/fastschema"
+)
+
+func TestLoadLayout(t *testing.T) {
+	l, err := LoadLayout(filepath.Join("testdata", "layout.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Marking != "EXERCISE EXERCISE EXERCISE" {
+		t.Errorf("unexpected marking %q", l.Marking)
+	}
+	if l.logoType != "PNG" || len(l.logo) == 0 {
+		t.Errorf("expected the PNG logo to be loaded relative to the config, got %q", l.logoType)
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	l, err := ParseLayout([]byte(`{"agency": "NEMA", "colors": {"no_threat": "#00ff00"}}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := DefaultLayout()
+	if l.Agency != "NEMA" || l.Title != def.Title || l.Colors.NoThreat != "#00ff00" || l.Colors.LandThreat != def.Colors.LandThreat {
+		t.Errorf("expected fields left out to keep their defaults, got %+v", l)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "logo.gif"), []byte("GIF89a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, config := range map[string]string{
+		"unknown field":  `{"agencies": "NEMA"}`,
+		"no title":       `{"title": ""}`,
+		"invalid colour": `{"colors": {"land_threat": "red"}}`,
+		"short colour":   `{"accent": "#fff"}`,
+		"missing logo":   `{"logo": "nope.png"}`,
+		"gif logo":       `{"logo": "logo.gif"}`,
+	} {
+		if _, err := ParseLayout([]byte(config), dir); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestStatusColor(t *testing.T) {
+	l := DefaultLayout()
+	for _, tt := range []struct {
+		land, beachMarine bool
+		want              string
+	}{
+		{true, true, l.Colors.LandThreat},
+		{true, false, l.Colors.LandThreat},
+		{false, true, l.Colors.BeachMarineThreat},
+		{false, false, l.Colors.NoThreat},
+	} {
+		if got := l.statusColor(tt.land, tt.beachMarine); got != tt.want {
+			t.Errorf("statusColor(%t, %t) = %s, want %s", tt.land, tt.beachMarine, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateEATPDFBranded(t *testing.T) {
+	layout, err := LoadLayout(filepath.Join("testdata", "layout.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	published := time.Date(2026, 1, 15, 11, 5, 0, 0, time.UTC)
+	eat := &fastschema.EAT{
+		EventTitle:        "M7.1-Kermadec Islands-2026-01-15",
+		Location:          "Kermadec Islands",
+		EventDate:         time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC),
+		Magnitude:         7.1,
+		Version:           3,
+		Status:            "confirmed",
+		BeachMarineThreat: true,
+		State:             fastschema.StatePublished,
+		ReviewedAt:        &published,
+		// Long enough to run onto a second page.
+		EventComments: strings.Repeat("Stay out of the water and away from beaches and shores.\n", 50),
+	}
+
+	b, err := GenerateEATPDF(eat, Options{Layout: layout})
+	if err != nil {
+		t.Fatalf("GenerateEATPDF failed: %v", err)
+	}
+	if n := bytes.Count(b, []byte("/Subtype /Image")); n != 1 {
+		t.Errorf("expected the logo embedded once, got %d images", n)
+	}
+
+	lines := text(t, b)
+	var markings int
+	for _, l := range lines {
+		if l == layout.Marking {
+			markings++
+		}
+	}
+	if markings != 4 {
+		t.Errorf("expected the marking at the top and bottom of both pages, got %d", markings)
+	}
+
+	// The comments make the golden file long, keep only the layout.
+	var kept []string
+	for _, l := range lines {
+		if !strings.HasPrefix(l, "Stay out of the water") {
+			kept = append(kept, l)
+		}
+	}
+	golden(t, "branded", strings.Join(kept, "\n")+"\n")
+}
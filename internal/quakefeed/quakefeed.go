@@ -0,0 +1,137 @@
+// Package quakefeed reads recent earthquakes from a GeoNet style quake API
+// (https://api.geonet.org.nz/quake), so new EATs can be prefilled from the
+// event instead of retyped.
+package quakefeed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the GeoNet API.
+const DefaultURL = "https://api.geonet.org.nz"
+
+// quakeURL is the GeoNet web page of a quake, by public ID.
+const quakeURL = "https://www.geonet.org.nz/earthquake/"
+
+// Quake is an earthquake from the feed.
+type Quake struct {
+	PublicID  string    `json:"public_id"`
+	Time      time.Time `json:"time"`
+	Magnitude float64   `json:"magnitude"`
+	Depth     float64   `json:"depth"` // km
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Locality  string    `json:"locality"` // e.g. "20 km north-west of Wellington"
+	MMI       int       `json:"mmi"`
+	Quality   string    `json:"quality"` // best, preliminary, automatic or deleted
+}
+
+// Place returns the place the locality is relative to, e.g. "Wellington" for
+// "20 km north-west of Wellington", for use as the EAT location.
+func (q Quake) Place() string {
+	if i := strings.LastIndex(q.Locality, " of "); i >= 0 {
+		return strings.TrimSpace(q.Locality[i+len(" of "):])
+	}
+	return strings.TrimSpace(q.Locality)
+}
+
+// URL returns the GeoNet web page of the quake.
+func (q Quake) URL() string {
+	return quakeURL + url.PathEscape(q.PublicID)
+}
+
+// Client reads the quake API.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the quake API at baseURL, e.g. DefaultURL.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// feature is a quake in the GeoJSON response.
+type feature struct {
+	Geometry struct {
+		Coordinates []float64 `json:"coordinates"` // longitude, latitude
+	} `json:"geometry"`
+	Properties struct {
+		PublicID  string    `json:"publicID"`
+		Time      time.Time `json:"time"`
+		Depth     float64   `json:"depth"`
+		Magnitude float64   `json:"magnitude"`
+		Locality  string    `json:"locality"`
+		MMI       int       `json:"mmi"`
+		Quality   string    `json:"quality"`
+	} `json:"properties"`
+}
+
+// Recent returns the most recent quakes with a modelled intensity of at least
+// mmi (-1 to 8), newest first. Deleted quakes are left out.
+func (c *Client) Recent(mmi int) ([]Quake, error) {
+	if mmi < -1 || mmi > 8 {
+		return nil, fmt.Errorf("invalid MMI %d, must be -1 to 8", mmi)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/quake?MMI="+strconv.Itoa(mmi), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.geo+json;version=2")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("quake feed request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("quake feed (%d): %s", resp.StatusCode, string(b))
+	}
+
+	var fc struct {
+		Features []feature `json:"features"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("decode quake feed: %w", err)
+	}
+
+	quakes := make([]Quake, 0, len(fc.Features))
+	for _, f := range fc.Features {
+		p := f.Properties
+		if p.Quality == "deleted" || len(f.Geometry.Coordinates) < 2 {
+			continue
+		}
+		quakes = append(quakes, Quake{
+			PublicID:  p.PublicID,
+			Time:      p.Time.UTC(),
+			Magnitude: p.Magnitude,
+			Depth:     p.Depth,
+			Latitude:  f.Geometry.Coordinates[1],
+			Longitude: f.Geometry.Coordinates[0],
+			Locality:  p.Locality,
+			MMI:       p.MMI,
+			Quality:   p.Quality,
+		})
+	}
+
+	// The API lists the newest first, but don't depend on it.
+	sort.SliceStable(quakes, func(i, j int) bool { return quakes[i].Time.After(quakes[j].Time) })
+
+	return quakes, nil
+}
@@ -0,0 +1,129 @@
+package quakefeed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stub serves testdata/quake.json like the GeoNet quake API.
+func stub(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("testdata", "quake.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/quake" {
+			http.NotFound(w, r)
+			return
+		}
+		if mmi := r.URL.Query().Get("MMI"); mmi != "3" {
+			t.Errorf("expected MMI 3, got %q", mmi)
+		}
+		if a := r.Header.Get("Accept"); a != "application/vnd.geo+json;version=2" {
+			t.Errorf("unexpected Accept header %q", a)
+		}
+		w.Header().Set("Content-Type", "application/vnd.geo+json;version=2")
+		w.Write(b)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestRecent(t *testing.T) {
+	s := stub(t)
+
+	quakes, err := NewClient(s.URL + "/").Recent(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(quakes) != 2 {
+		t.Fatalf("expected 2 quakes without the deleted one, got %d", len(quakes))
+	}
+
+	q := quakes[0]
+	if q.PublicID != "2026p038412" || q.Magnitude != 5.43 || q.Depth != 22.4 || q.MMI != 5 || q.Quality != "best" {
+		t.Errorf("unexpected quake %+v", q)
+	}
+	if q.Latitude != -41.2865 || q.Longitude != 174.7787 {
+		t.Errorf("expected latitude from the second coordinate, got %v, %v", q.Latitude, q.Longitude)
+	}
+	if want := time.Date(2026, 1, 15, 10, 30, 12, 345e6, time.UTC); !q.Time.Equal(want) {
+		t.Errorf("expected time %v, got %v", want, q.Time)
+	}
+	if q.URL() != "https://www.geonet.org.nz/earthquake/2026p038412" {
+		t.Errorf("unexpected URL %s", q.URL())
+	}
+}
+
+func TestRecentOrder(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type":"FeatureCollection","features":[
+			{"geometry":{"coordinates":[174.7,-41.3]},"properties":{"publicID":"2026p000002","time":"2026-01-15T09:00:00Z","quality":"best"}},
+			{"geometry":{"coordinates":[176.1,-38.7]},"properties":{"publicID":"2026p000003","time":"2026-01-15T11:00:00Z","quality":"best"}},
+			{"geometry":{"coordinates":[172.6,-43.5]},"properties":{"publicID":"2026p000001","time":"2026-01-15T08:00:00Z","quality":"best"}}
+		]}`))
+	}))
+	defer s.Close()
+
+	quakes, err := NewClient(s.URL).Recent(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, q := range quakes {
+		ids = append(ids, q.PublicID)
+	}
+	if got := strings.Join(ids, ","); got != "2026p000003,2026p000002,2026p000001" {
+		t.Errorf("expected newest first, got %s", got)
+	}
+}
+
+func TestRecentErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("MMI") == "4" {
+			w.Write([]byte("<html>"))
+			return
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	c := NewClient(s.URL)
+	if _, err := c.Recent(3); err == nil {
+		t.Error("expected error for unavailable feed")
+	}
+	if _, err := c.Recent(4); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+	if _, err := c.Recent(9); err == nil {
+		t.Error("expected error for invalid MMI")
+	}
+}
+
+func TestPlace(t *testing.T) {
+	tests := []struct {
+		locality, place string
+	}{
+		{"10 km north-west of Wellington", "Wellington"},
+		{"20 km east of Cape Turnagain", "Cape Turnagain"},
+		{"Kermadec Islands", "Kermadec Islands"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := (Quake{Locality: tt.locality}).Place(); got != tt.place {
+			t.Errorf("%q: expected %q, got %q", tt.locality, tt.place, got)
+		}
+	}
+}
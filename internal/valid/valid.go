@@ -18,6 +18,12 @@ func Query(v url.Values) error {
 		}
 	}
 
+	if event := v.Get("event"); event != "" {
+		if e, err := strconv.Atoi(event); err != nil || e < 1 {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid event: %s", event)}
+		}
+	}
+
 	if days := v.Get("days"); days != "" {
 		d, err := strconv.Atoi(days)
 		if err != nil || d < 1 || d > 90 {
@@ -32,6 +38,49 @@ func Query(v url.Values) error {
 		}
 	}
 
+	for _, name := range []string{"from", "to", "file"} {
+		if version := v.Get(name); version != "" {
+			n, err := strconv.Atoi(version)
+			if err != nil || n < 1 {
+				return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid %s: %s", name, version)}
+			}
+		}
+	}
+
+	if lat := v.Get("lat"); lat != "" {
+		l, err := strconv.ParseFloat(lat, 64)
+		if err != nil || !(l >= -90 && l <= 90) {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid lat: %s (must be -90 to 90)", lat)}
+		}
+	}
+
+	if lon := v.Get("lon"); lon != "" {
+		l, err := strconv.ParseFloat(lon, 64)
+		if err != nil || !(l >= -180 && l <= 180) {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid lon: %s (must be -180 to 180)", lon)}
+		}
+	}
+
+	if mag := v.Get("mag"); mag != "" {
+		m, err := strconv.ParseFloat(mag, 32)
+		if err != nil || !(m >= 0 && m <= 10) {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid mag: %s (must be 0-10)", mag)}
+		}
+	}
+
+	if mmi := v.Get("mmi"); mmi != "" {
+		m, err := strconv.Atoi(mmi)
+		if err != nil || m < -1 || m > 8 {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid mmi: %s (must be -1 to 8)", mmi)}
+		}
+	}
+
+	if exercise := v.Get("exercise"); exercise != "" {
+		if _, err := strconv.ParseBool(exercise); err != nil {
+			return weft.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid exercise: %s (must be true or false)", exercise)}
+		}
+	}
+
 	return nil
 }
 
@@ -26,6 +26,16 @@ func TestQuery(t *testing.T) {
 			values:  url.Values{"id": {"abc"}},
 			wantErr: true,
 		},
+		{
+			name:    "valid event",
+			values:  url.Values{"event": {"12"}},
+			wantErr: false,
+		},
+		{
+			name:    "event title instead of event",
+			values:  url.Values{"event": {"M5.0-Wellington-2026-01-01"}},
+			wantErr: true,
+		},
 		{
 			name:    "valid days",
 			values:  url.Values{"days": {"7"}},
@@ -61,6 +71,66 @@ func TestQuery(t *testing.T) {
 			values:  url.Values{"version": {"-1"}},
 			wantErr: true,
 		},
+		{
+			name:    "valid epicentre",
+			values:  url.Values{"lat": {"-41.29"}, "lon": {"-177.5"}, "mag": {"7.1"}},
+			wantErr: false,
+		},
+		{
+			name:    "lat out of range",
+			values:  url.Values{"lat": {"-91"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid lon",
+			values:  url.Values{"lon": {"east"}},
+			wantErr: true,
+		},
+		{
+			name:    "lon out of range",
+			values:  url.Values{"lon": {"183.5"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid mag",
+			values:  url.Values{"mag": {"NaN"}},
+			wantErr: true,
+		},
+		{
+			name:    "valid mmi",
+			values:  url.Values{"mmi": {"-1"}},
+			wantErr: false,
+		},
+		{
+			name:    "mmi out of range",
+			values:  url.Values{"mmi": {"9"}},
+			wantErr: true,
+		},
+		{
+			name:    "valid versions",
+			values:  url.Values{"from": {"3"}, "to": {"4"}},
+			wantErr: false,
+		},
+		{
+			name:    "invalid from",
+			values:  url.Values{"from": {"0"}, "to": {"4"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid file",
+			values:  url.Values{"file": {"map.png"}},
+			wantErr: true,
+		},
+		{
+			name:    "valid exercise",
+			values:  url.Values{"exercise": {"true"}},
+			wantErr: false,
+		},
+		{
+			name:    "invalid exercise",
+			values:  url.Values{"exercise": {"drill"}},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
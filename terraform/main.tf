@@ -98,6 +98,39 @@ resource "aws_ssm_parameter" "fs_admin_pass" {
   }
 }
 
+# Basic auth users, see AUTH_USERS in env.list. The placeholder configures no
+# users, leaving OIDC as the only login.
+resource "aws_ssm_parameter" "auth_users" {
+  name  = "/${local.name_prefix}/auth-users"
+  type  = "SecureString"
+  value = ","
+
+  lifecycle {
+    ignore_changes = [value]
+  }
+}
+
+resource "aws_ssm_parameter" "oidc_client_secret" {
+  name  = "/${local.name_prefix}/oidc-client-secret"
+  type  = "SecureString"
+  value = "placeholder"
+
+  lifecycle {
+    ignore_changes = [value]
+  }
+}
+
+# At least 32 characters, required when oidc_issuer is set.
+resource "aws_ssm_parameter" "session_key" {
+  name  = "/${local.name_prefix}/session-key"
+  type  = "SecureString"
+  value = "placeholder"
+
+  lifecycle {
+    ignore_changes = [value]
+  }
+}
+
 # --- IAM Roles ---
 
 resource "aws_iam_role" "task_role" {
@@ -203,18 +236,26 @@ resource "aws_ecs_task_definition" "nema_mar_portal" {
       cpu       = 128
       portMappings = [{ containerPort = 8080, hostPort = 0, protocol = "tcp" }]
       environment = [
-        { name = "FASTSCHEMA_URL", value = "http://localhost:8000" },
-        { name = "SMTP_HOST",      value = var.smtp_host },
-        { name = "SMTP_PORT",      value = var.smtp_port },
-        { name = "SMTP_FROM",      value = var.smtp_from },
-        { name = "DDOG_API_KEY",   value = var.ddog_api_key },
+        { name = "FASTSCHEMA_URL",    value = "http://localhost:8000" },
+        { name = "PUBLIC_URL",        value = var.public_url },
+        { name = "SMTP_HOST",         value = var.smtp_host },
+        { name = "SMTP_PORT",         value = var.smtp_port },
+        { name = "SMTP_FROM",         value = var.smtp_from },
+        { name = "OIDC_ISSUER",       value = var.oidc_issuer },
+        { name = "OIDC_CLIENT_ID",    value = var.oidc_client_id },
+        { name = "OIDC_GROUPS_CLAIM", value = var.oidc_groups_claim },
+        { name = "OIDC_GROUP_ROLES",  value = var.oidc_group_roles },
+        { name = "DDOG_API_KEY",      value = var.ddog_api_key },
       ]
       secrets = [
-        { name = "SMTP_USERNAME",   valueFrom = aws_ssm_parameter.smtp_username.arn },
-        { name = "SMTP_PASSWORD",   valueFrom = aws_ssm_parameter.smtp_password.arn },
-        { name = "SMTP_RECIPIENTS", valueFrom = aws_ssm_parameter.smtp_recipients.arn },
-        { name = "FS_ADMIN_USER",   valueFrom = aws_ssm_parameter.fs_admin_user.arn },
-        { name = "FS_ADMIN_PASS",   valueFrom = aws_ssm_parameter.fs_admin_pass.arn },
+        { name = "SMTP_USERNAME",      valueFrom = aws_ssm_parameter.smtp_username.arn },
+        { name = "SMTP_PASSWORD",      valueFrom = aws_ssm_parameter.smtp_password.arn },
+        { name = "SMTP_RECIPIENTS",    valueFrom = aws_ssm_parameter.smtp_recipients.arn },
+        { name = "FS_ADMIN_USER",      valueFrom = aws_ssm_parameter.fs_admin_user.arn },
+        { name = "FS_ADMIN_PASS",      valueFrom = aws_ssm_parameter.fs_admin_pass.arn },
+        { name = "AUTH_USERS",         valueFrom = aws_ssm_parameter.auth_users.arn },
+        { name = "OIDC_CLIENT_SECRET", valueFrom = aws_ssm_parameter.oidc_client_secret.arn },
+        { name = "SESSION_KEY",        valueFrom = aws_ssm_parameter.session_key.arn },
       ]
       dependsOn = [{ containerName = "fastschema", condition = "HEALTHY" }]
       logConfiguration = {
@@ -99,6 +99,36 @@ variable "smtp_from" {
   default     = ""
 }
 
+variable "public_url" {
+  description = "Externally visible base URL of the portal, used for the OIDC redirect and feed and email links"
+  type        = string
+  default     = ""
+}
+
+variable "oidc_issuer" {
+  description = "OpenID Connect issuer URL for staff login, OIDC is disabled if empty"
+  type        = string
+  default     = ""
+}
+
+variable "oidc_client_id" {
+  description = "OpenID Connect client ID"
+  type        = string
+  default     = ""
+}
+
+variable "oidc_groups_claim" {
+  description = "ID token claim listing the user's groups"
+  type        = string
+  default     = "groups"
+}
+
+variable "oidc_group_roles" {
+  description = "Provider groups mapped to roles, e.g. ngmc-duty=editor,ngmc-senior=editor|approver,nema=reader"
+  type        = string
+  default     = ""
+}
+
 variable "ddog_api_key" {
   description = "DataDog API key"
   type        = string